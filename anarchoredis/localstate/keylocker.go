@@ -5,119 +5,338 @@
 package localstate
 
 import (
-	"bytes"
 	"context"
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"log/slog"
-	"strings"
 	"time"
 
-	"github.com/awinterman/anarchoredis/protocol"
 	"github.com/dgraph-io/badger/v4"
 	badgerpb "github.com/dgraph-io/badger/v4/pb"
 )
 
+// LockManager locks keys of the numbered redis databases for their owners, until they are released or expire, and
+// lets others wait for them.
+type LockManager interface {
+	// LockKeys locks keys for owner with a new fencing token, or fails with ErrLocked if any is already held.
+	LockKeys(owner, database string, keys []string) (Lock, error)
+	// AcquireKeys locks keys for owner, waiting for those already held.
+	AcquireKeys(ctx context.Context, owner, database string, keys []string) (Lock, error)
+	// UnlockKeys releases keys still held with lock, or fails with ErrNotOwner if any has been taken since.
+	UnlockKeys(lock Lock, database string, keys []string) error
+	// ReleaseKeys releases keys whoever holds them.
+	ReleaseKeys(database string, keys []string) error
+	// AwaitUnlocked waits until keys are no longer locked, and returns those whose locks expired.
+	AwaitUnlocked(ctx context.Context, database string, keys []string) (expired []string, err error)
+	// AwaitReleased waits until keys are no longer locked with lock, and returns those whose locks expired.
+	AwaitReleased(ctx context.Context, lock Lock, database string, keys []string) (expired []string, err error)
+}
+
+// Store is a LockManager that keeps its locks in a Badger DB, and waits for them by subscribing to their changes.
 type Store struct {
-	DB      *badger.DB
-	Log     *slog.Logger
+	DB  *badger.DB
+	Log *slog.Logger
+	// LockTTL is how long a lock is held if it is never released, or forever if zero.
 	LockTTL time.Duration
 }
 
-var sentinel = []byte("OK")
+// Lock is a lock taken on keys by its owner.
+type Lock struct {
+	Owner string
+	// Token increases with every lock taken, so that a lock that expired and was taken again is told apart from the
+	// one before it.
+	Token uint64
+}
+
+var (
+	// ErrLocked is returned when taking a lock that is held by another owner.
+	ErrLocked = errors.New("key is locked")
+	// ErrNotOwner is returned when unlocking a lock that is held by another owner, or with another token.
+	ErrNotOwner = errors.New("lock is not held")
+)
+
 var keyprefix = "anarcho:key:"
 
-// UnlockKeys removes locks for the given keys by deleting them from the database with the specified prefix.
-// Returns an error if any operation fails during the unlock process.
-func (b Store) UnlockKeys(keys []string) error {
-	return b.DB.Update(func(txn *badger.Txn) error {
+// tokenKey holds the last fencing token handed out.
+var tokenKey = []byte("anarcho:token")
+
+// recheck is how often AwaitUnlocked looks at the locks it waits on again, in case a release was missed while it
+// subscribed to them.
+const recheck = 100 * time.Millisecond
+
+// lockKey is the name of the lock on key in the numbered redis database, since the same key in different databases
+// is a different key.
+func lockKey(database, key string) []byte {
+	return []byte(keyprefix + database + ":" + key)
+}
+
+// encode encodes the lock as the value of its keys: the token, followed by the owner.
+func (l Lock) encode() []byte {
+	return append(binary.BigEndian.AppendUint64(nil, l.Token), l.Owner...)
+}
+
+func decodeLock(b []byte) (Lock, error) {
+	if len(b) < 8 {
+		return Lock{}, fmt.Errorf("invalid lock %q", b)
+	}
+	return Lock{Owner: string(b[8:]), Token: binary.BigEndian.Uint64(b)}, nil
+}
+
+// update runs fn in a read-write transaction, retrying if it conflicts with another.
+func (b Store) update(fn func(txn *badger.Txn) error) error {
+	for {
+		err := b.DB.Update(fn)
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+	}
+}
+
+// holder returns the lock held on key, if any.
+func holder(txn *badger.Txn, lock []byte) (Lock, error) {
+	item, err := txn.Get(lock)
+	if err != nil {
+		return Lock{}, err
+	}
+	var held Lock
+	err = item.Value(func(val []byte) error {
+		held, err = decodeLock(val)
+		return err
+	})
+	return held, err
+}
+
+// LockKeys locks the given keys of the numbered redis database for owner, with a specified TTL and a new fencing
+// token. It fails with ErrLocked, taking none of the locks, if any is already held, even by owner.
+func (b Store) LockKeys(owner, database string, keys []string) (Lock, error) {
+	var lock Lock
+	err := b.update(func(txn *badger.Txn) error {
 		for _, key := range keys {
-			err := txn.Delete([]byte(keyprefix + key))
+			held, err := holder(txn, lockKey(database, key))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			return fmt.Errorf("%w: %q is held by %q", ErrLocked, key, held.Owner)
+		}
+
+		token, err := nextToken(txn)
+		if err != nil {
+			return err
+		}
+		lock = Lock{Owner: owner, Token: token}
+		for _, key := range keys {
+			b.Log.Debug("lock created", "key", key, "owner", owner, "token", token)
+			entry := badger.NewEntry(lockKey(database, key), lock.encode())
+			if b.LockTTL > 0 {
+				entry = entry.WithTTL(b.LockTTL)
+			}
+			err := txn.SetEntry(entry)
 			if err != nil {
 				return err
 			}
-			b.Log.Debug("freeing", "key", key)
 		}
 		return nil
 	})
+	return lock, err
 }
 
-// AwaitUnlocked waits until all keys involved in the provided command are no longer locked in the database.
-// It checks for locks and subscribes to listen for unlock events if any keys are locked.
-// Returns nil when all locks are released or an error if there is an issue during the process.
-func (b Store) AwaitUnlocked(ctx context.Context, cmd *protocol.Command) error {
-	keys, err := cmd.Keys()
-	if err != nil {
-		return err
+// nextToken hands out the next fencing token, which is kept in the store so that tokens keep increasing across
+// restarts.
+func nextToken(txn *badger.Txn) (uint64, error) {
+	var token uint64
+	item, err := txn.Get(tokenKey)
+	if err == nil {
+		err = item.Value(func(val []byte) error {
+			if len(val) != 8 {
+				return fmt.Errorf("invalid token %q", val)
+			}
+			token = binary.BigEndian.Uint64(val)
+			return nil
+		})
+	}
+	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, err
+	}
+	token++
+	return token, txn.Set(tokenKey, binary.BigEndian.AppendUint64(nil, token))
+}
+
+// AcquireKeys locks the given keys of the database for owner like LockKeys, but waits for the locks already held to
+// be released or to expire rather than failing.
+func (b Store) AcquireKeys(ctx context.Context, owner, database string, keys []string) (Lock, error) {
+	for {
+		lock, err := b.LockKeys(owner, database, keys)
+		if !errors.Is(err, ErrLocked) {
+			return lock, err
+		}
+		_, err = b.AwaitUnlocked(ctx, database, keys)
+		if err != nil {
+			return Lock{}, err
+		}
 	}
-	var waitFor []badgerpb.Match
-	var waitForSet = map[string]struct{}{}
-	err = b.DB.View(func(txn *badger.Txn) error {
+}
+
+// UnlockKeys removes the given locks of the database, if they are still held with lock, by deleting them from the
+// store. Locks that have expired are skipped, and ErrNotOwner is returned if any has been taken by another owner
+// or again since.
+func (b Store) UnlockKeys(lock Lock, database string, keys []string) error {
+	var notHeld []string
+	err := b.update(func(txn *badger.Txn) error {
+		notHeld = nil
 		for _, key := range keys {
-			_, err := txn.Get([]byte(keyprefix + key))
+			held, err := holder(txn, lockKey(database, key))
 			if errors.Is(err, badger.ErrKeyNotFound) {
-				b.Log.Debug("no lock", "key", key)
 				continue
 			} else if err != nil {
 				return err
 			}
-			b.Log.Debug("locked", "key", key)
-
-			waitFor = append(waitFor, badgerpb.Match{Prefix: []byte(key)})
-
-			waitForSet[key] = struct{}{}
+			if held != lock {
+				notHeld = append(notHeld, key)
+				continue
+			}
+			err = txn.Delete(lockKey(database, key))
+			if err != nil {
+				return err
+			}
+			b.Log.Debug("freeing", "key", key, "owner", lock.Owner, "token", lock.Token)
 		}
 		return nil
 	})
-	if err != nil {
-		return nil
+	if err == nil && len(notHeld) > 0 {
+		err = fmt.Errorf("%w by %q with token %d: %q", ErrNotOwner, lock.Owner, lock.Token, notHeld)
 	}
+	return err
+}
 
-	if len(waitFor) == 0 {
+// ReleaseKeys removes the locks on the given keys of the database whoever holds them, once a write to them has been
+// committed.
+func (b Store) ReleaseKeys(database string, keys []string) error {
+	return b.update(func(txn *badger.Txn) error {
+		for _, key := range keys {
+			err := txn.Delete(lockKey(database, key))
+			if err != nil {
+				return err
+			}
+			b.Log.Debug("freeing", "key", key)
+		}
 		return nil
-	}
-
-	errDone := errors.New("done")
+	})
+}
 
-	err = b.DB.Subscribe(ctx, func(kv *badger.KVList) error {
-		for _, k := range kv.GetKv() {
-			key := strings.TrimPrefix(string(k.Key), keyprefix)
+// AwaitUnlocked waits until all the given keys of the numbered redis database are no longer locked.
+// It checks for locks and subscribes to listen for unlock events if any keys are locked.
+// It returns the keys whose locks expired rather than being released, or an error if there is an issue during the
+// process.
+func (b Store) AwaitUnlocked(ctx context.Context, database string, keys []string) (expired []string, err error) {
+	return b.await(ctx, nil, database, keys)
+}
 
-			if !bytes.Equal(k.GetValue(), sentinel) {
-				b.Log.Debug("lock removed", "key", key)
+// AwaitReleased waits until lock is no longer held on any of the given keys of the database, even if another has been
+// taken on them since. It returns the keys whose lock expired rather than being released.
+func (b Store) AwaitReleased(ctx context.Context, lock Lock, database string, keys []string) (expired []string, err error) {
+	return b.await(ctx, &lock, database, keys)
+}
 
-				delete(waitForSet, key)
-			}
+// await waits until the given keys are no longer locked with lock, or at all if lock is nil.
+func (b Store) await(ctx context.Context, lock *Lock, database string, keys []string) (expired []string, err error) {
+	// waiting are the keys by the name of their lock, and expires when the locks expire, in unix seconds, or zero.
+	waiting := map[string]string{}
+	expires := map[string]uint64{}
+	for _, key := range keys {
+		waiting[string(lockKey(database, key))] = key
+	}
+	held := func(val []byte) bool {
+		if len(val) == 0 {
+			// deleted locks have no value.
+			return false
 		}
-		if len(waitForSet) > 0 {
-			return nil
+		if lock == nil {
+			return true
+		}
+		current, err := decodeLock(val)
+		return err == nil && current == *lock
+	}
+	// gone stops waiting on a lock that is no longer held, and tells whether it expired.
+	gone := func(name string) {
+		key := waiting[name]
+		if at := expires[name]; at != 0 && at <= uint64(time.Now().Unix()) {
+			b.Log.Debug("lock expired", "key", key)
+			expired = append(expired, key)
 		} else {
-			return errDone
+			b.Log.Debug("lock removed", "key", key)
 		}
-	}, waitFor)
-
-	if errors.Is(err, errDone) {
-		return nil
+		delete(waiting, name)
+		delete(expires, name)
 	}
 
-	return err
-}
+	for {
+		err := b.DB.View(func(txn *badger.Txn) error {
+			for name := range waiting {
+				item, err := txn.Get([]byte(name))
+				if errors.Is(err, badger.ErrKeyNotFound) {
+					gone(name)
+					continue
+				} else if err != nil {
+					return err
+				}
+				val, err := item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				if !held(val) {
+					gone(name)
+					continue
+				}
+				b.Log.Debug("locked", "key", waiting[name])
+				expires[name] = item.ExpiresAt()
+			}
+			return nil
+		})
+		if err != nil {
+			return expired, err
+		}
+		if len(waiting) == 0 {
+			return expired, nil
+		}
 
-// LockKeys locks the keys involved in the given command with a specified TTL in the database.
-// Returns an error if any operation fails during the locking process.
-func (b Store) LockKeys(cmd *protocol.Command) error {
-	keys, err := cmd.Keys()
-	if err != nil {
-		return err
-	}
+		// wait for the locks to be released, until the first expires.
+		deadline := time.Now().Add(recheck)
+		var waitFor []badgerpb.Match
+		for name := range waiting {
+			if at := expires[name]; at != 0 && time.Unix(int64(at), 0).Before(deadline) {
+				deadline = time.Unix(int64(at), 0)
+			}
+			waitFor = append(waitFor, badgerpb.Match{Prefix: []byte(name)})
+		}
 
-	return b.DB.Update(func(txn *badger.Txn) error {
-		for _, key := range keys {
-			b.Log.Debug("lock created", "key", key)
-			err := txn.SetEntry(badger.NewEntry([]byte(key), sentinel).WithTTL(b.LockTTL))
-			if err != nil {
-				return err
+		errDone := errors.New("done")
+		subCtx, cancel := context.WithDeadline(ctx, deadline)
+		err = b.DB.Subscribe(subCtx, func(kv *badger.KVList) error {
+			for _, k := range kv.GetKv() {
+				name := string(k.Key)
+				// the prefix of a lock matches the locks of longer keys too.
+				if _, ok := waiting[name]; ok && !held(k.GetValue()) {
+					gone(name)
+				}
+			}
+			if len(waiting) > 0 {
+				return nil
+			} else {
+				return errDone
 			}
+		}, waitFor)
+		cancel()
+
+		switch {
+		case errors.Is(err, errDone):
+			return expired, nil
+		case ctx.Err() != nil:
+			return expired, context.Cause(ctx)
+		case err != nil && !errors.Is(err, context.DeadlineExceeded):
+			return expired, err
 		}
-		return nil
-	})
+	}
 }
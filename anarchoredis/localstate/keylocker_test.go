@@ -0,0 +1,184 @@
+package localstate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"gotest.tools/v3/assert"
+)
+
+// managers are the LockManager implementations, by name, made with a lock TTL.
+var managers = []struct {
+	name string
+	new  func(t testing.TB, ttl time.Duration) LockManager
+}{
+	{"badger", func(t testing.TB, ttl time.Duration) LockManager {
+		db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+		assert.NilError(t, err)
+		t.Cleanup(func() { _ = db.Close() })
+		return Store{DB: db, Log: slog.New(slog.NewTextHandler(io.Discard, nil)), LockTTL: ttl}
+	}},
+	{"memory", func(t testing.TB, ttl time.Duration) LockManager {
+		return &Memory{LockTTL: ttl}
+	}},
+}
+
+// TestLockManager_Ownership tests that locks are exclusive, and only unlocked with the token they were taken with.
+func TestLockManager_Ownership(t *testing.T) {
+	for _, m := range managers {
+		t.Run(m.name, func(t *testing.T) {
+			s := m.new(t, 0)
+
+			first, err := s.LockKeys("a", "0", []string{"x", "y"})
+			assert.NilError(t, err)
+			_, err = s.LockKeys("b", "0", []string{"y", "z"})
+			assert.ErrorIs(t, err, ErrLocked)
+			_, err = s.LockKeys("b", "1", []string{"y"})
+			assert.NilError(t, err, "the same key in another database is another lock")
+
+			_, err = s.LockKeys("a", "0", []string{"y"})
+			assert.ErrorIs(t, err, ErrLocked, "not even the owner takes a lock twice")
+
+			assert.ErrorIs(t, s.UnlockKeys(Lock{Owner: "b", Token: first.Token}, "0", []string{"x"}), ErrNotOwner)
+			assert.NilError(t, s.UnlockKeys(first, "0", []string{"y"}))
+			second, err := s.LockKeys("b", "0", []string{"y"})
+			assert.NilError(t, err)
+			assert.Assert(t, second.Token > first.Token)
+			assert.ErrorIs(t, s.UnlockKeys(first, "0", []string{"x", "y"}), ErrNotOwner, "y was taken again")
+			assert.NilError(t, s.UnlockKeys(second, "0", []string{"y"}))
+
+			// z was never taken by b, since y was locked.
+			third, err := s.LockKeys("b", "0", []string{"x", "y", "z"})
+			assert.NilError(t, err)
+			assert.Assert(t, third.Token > second.Token)
+		})
+	}
+}
+
+// TestLockManager_AwaitUnlocked tests that waiters are woken once the locks are released.
+func TestLockManager_AwaitUnlocked(t *testing.T) {
+	for _, m := range managers {
+		t.Run(m.name, func(t *testing.T) {
+			s := m.new(t, 0)
+			ctx := context.Background()
+
+			expired, err := s.AwaitUnlocked(ctx, "0", []string{"x"})
+			assert.NilError(t, err)
+			assert.Assert(t, expired == nil)
+
+			_, err = s.LockKeys("a", "0", []string{"x"})
+			assert.NilError(t, err)
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				_ = s.ReleaseKeys("0", []string{"x"})
+			}()
+			expired, err = s.AwaitUnlocked(ctx, "0", []string{"x"})
+			assert.NilError(t, err)
+			assert.Assert(t, expired == nil)
+
+			// a lock taken again after it was released doesn't hold up the waiter on the first.
+			first, err := s.LockKeys("a", "0", []string{"x"})
+			assert.NilError(t, err)
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				_ = s.UnlockKeys(first, "0", []string{"x"})
+				_, _ = s.LockKeys("b", "0", []string{"x"})
+			}()
+			expired, err = s.AwaitReleased(ctx, first, "0", []string{"x"})
+			assert.NilError(t, err)
+			assert.Assert(t, expired == nil)
+
+			timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+			defer cancel()
+			_, err = s.LockKeys("a", "0", []string{"y"})
+			assert.NilError(t, err)
+			_, err = s.AwaitUnlocked(timeout, "0", []string{"y"})
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+		})
+	}
+}
+
+// TestLockManager_Expired tests that waiters are told that locks expired rather than being released.
+func TestLockManager_Expired(t *testing.T) {
+	for _, m := range managers {
+		t.Run(m.name, func(t *testing.T) {
+			s := m.new(t, time.Second)
+			ctx := context.Background()
+
+			lock, err := s.LockKeys("a", "0", []string{"x", "y"})
+			assert.NilError(t, err)
+			assert.NilError(t, s.ReleaseKeys("0", []string{"y"}))
+			expired, err := s.AwaitReleased(ctx, lock, "0", []string{"x", "y"})
+			assert.NilError(t, err)
+			assert.DeepEqual(t, expired, []string{"x"})
+
+			_, err = s.LockKeys("b", "0", []string{"x"})
+			assert.NilError(t, err, "an expired lock is taken again")
+		})
+	}
+}
+
+// TestLockManager_AcquireKeys tests that acquiring a held lock waits until it is released.
+func TestLockManager_AcquireKeys(t *testing.T) {
+	for _, m := range managers {
+		t.Run(m.name, func(t *testing.T) {
+			s := m.new(t, 0)
+			ctx := context.Background()
+
+			first, err := s.LockKeys("a", "0", []string{"x"})
+			assert.NilError(t, err)
+
+			acquired := make(chan Lock)
+			go func() {
+				lock, err := s.AcquireKeys(ctx, "b", "0", []string{"x", "y"})
+				assert.Check(t, err)
+				acquired <- lock
+			}()
+			select {
+			case <-acquired:
+				t.Fatal("acquired a held lock")
+			case <-time.After(20 * time.Millisecond):
+			}
+
+			assert.NilError(t, s.UnlockKeys(first, "0", []string{"x"}))
+			lock := <-acquired
+			assert.Equal(t, lock.Owner, "b")
+			assert.Assert(t, lock.Token > first.Token)
+		})
+	}
+}
+
+// BenchmarkLockManager measures acquiring and unlocking a key by parallel owners, contending for one key, or spread
+// over many.
+func BenchmarkLockManager(b *testing.B) {
+	for _, m := range managers {
+		for _, keys := range []int{1, 16, 1024} {
+			b.Run(fmt.Sprintf("%s/keys=%d", m.name, keys), func(b *testing.B) {
+				s := m.new(b, time.Minute)
+				ctx := context.Background()
+				b.RunParallel(func(pb *testing.PB) {
+					owner := fmt.Sprint(rand.Int())
+					for pb.Next() {
+						key := []string{fmt.Sprint(rand.IntN(keys))}
+						lock, err := s.AcquireKeys(ctx, owner, "0", key)
+						if err != nil {
+							b.Error(err)
+							return
+						}
+						err = s.UnlockKeys(lock, "0", key)
+						if err != nil {
+							b.Error(err)
+							return
+						}
+					}
+				})
+			})
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package localstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// memoryShards is the number of shards the locks of a Memory are spread over.
+const memoryShards = 64
+
+// Memory is a LockManager that keeps its locks in memory, for when they needn't outlive the process. Its locks are
+// spread over shards, each guarded by its own mutex, and each lock has a queue of the waiters to wake once it is
+// released or expires. The zero value is ready to use.
+type Memory struct {
+	// LockTTL is how long a lock is held if it is never released, or forever if zero.
+	LockTTL time.Duration
+
+	shards [memoryShards]memoryShard
+	// tokens is the last fencing token handed out.
+	tokens atomic.Uint64
+}
+
+type memoryShard struct {
+	mu    sync.Mutex
+	locks map[string]*memoryLock
+	// sweepAt is the number of locks at which the shard is swept of expired ones.
+	sweepAt int
+}
+
+// memoryLock is a lock held on a key.
+type memoryLock struct {
+	Lock
+	// expires is when the lock expires, or zero if never.
+	expires time.Time
+	// waiters are woken, in the order they queued, when the lock is released or expires.
+	waiters []chan struct{}
+}
+
+// expired says whether the lock has expired by now.
+func (l *memoryLock) expired(now time.Time) bool {
+	return !l.expires.IsZero() && !now.Before(l.expires)
+}
+
+// wake wakes the waiters of a lock that is no longer held.
+func (l *memoryLock) wake() {
+	for _, ch := range l.waiters {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	l.waiters = nil
+}
+
+// lockName is the name of the lock on key in the database, like lockKey.
+func lockName(database, key string) string {
+	return database + ":" + key
+}
+
+// shard returns the shard of the named lock.
+func (m *Memory) shard(name string) *memoryShard {
+	return &m.shards[shardIndex(name)]
+}
+
+func shardIndex(name string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	return int(h.Sum32() % memoryShards)
+}
+
+// lockShards locks the shards of the given keys in order, so that locking several keys can't deadlock, and returns
+// the names of their locks and a func to unlock the shards.
+func (m *Memory) lockShards(database string, keys []string) ([]string, func()) {
+	names := make([]string, len(keys))
+	shards := make([]int, len(keys))
+	for i, key := range keys {
+		names[i] = lockName(database, key)
+		shards[i] = shardIndex(names[i])
+	}
+	slices.Sort(shards)
+	shards = slices.Compact(shards)
+	for _, i := range shards {
+		m.shards[i].mu.Lock()
+	}
+	return names, func() {
+		for _, i := range shards {
+			m.shards[i].mu.Unlock()
+		}
+	}
+}
+
+// held returns the lock held on name, if any, removing it if it has expired. The shard of name must be locked.
+func (s *memoryShard) held(name string, now time.Time) *memoryLock {
+	l := s.locks[name]
+	if l != nil && l.expired(now) {
+		s.remove(name)
+		return nil
+	}
+	return l
+}
+
+// remove releases the lock on name, waking its waiters. The shard must be locked.
+func (s *memoryShard) remove(name string) {
+	l := s.locks[name]
+	if l == nil {
+		return
+	}
+	delete(s.locks, name)
+	l.wake()
+}
+
+// sweep removes the expired locks of the shard once it has grown, so that locks which are never touched again after
+// they expire don't pile up. The shard must be locked.
+func (s *memoryShard) sweep(now time.Time) {
+	if len(s.locks) < s.sweepAt {
+		return
+	}
+	for name, l := range s.locks {
+		if l.expired(now) {
+			s.remove(name)
+		}
+	}
+	s.sweepAt = max(2*len(s.locks), memoryShards)
+}
+
+// LockKeys locks the given keys of the numbered redis database for owner like Store.LockKeys.
+func (m *Memory) LockKeys(owner, database string, keys []string) (Lock, error) {
+	names, unlock := m.lockShards(database, keys)
+	defer unlock()
+
+	now := time.Now()
+	for i, name := range names {
+		if held := m.shard(name).held(name, now); held != nil {
+			return Lock{}, fmt.Errorf("%w: %q is held by %q", ErrLocked, keys[i], held.Owner)
+		}
+	}
+
+	lock := Lock{Owner: owner, Token: m.tokens.Add(1)}
+	var expires time.Time
+	if m.LockTTL > 0 {
+		expires = now.Add(m.LockTTL)
+	}
+	for _, name := range names {
+		s := m.shard(name)
+		if s.locks == nil {
+			s.locks = map[string]*memoryLock{}
+		}
+		s.sweep(now)
+		s.locks[name] = &memoryLock{Lock: lock, expires: expires}
+	}
+	return lock, nil
+}
+
+// AcquireKeys locks the given keys of the database for owner like LockKeys, but waits for the locks already held to
+// be released or to expire rather than failing.
+func (m *Memory) AcquireKeys(ctx context.Context, owner, database string, keys []string) (Lock, error) {
+	for {
+		lock, err := m.LockKeys(owner, database, keys)
+		if !errors.Is(err, ErrLocked) {
+			return lock, err
+		}
+		_, err = m.AwaitUnlocked(ctx, database, keys)
+		if err != nil {
+			return Lock{}, err
+		}
+	}
+}
+
+// UnlockKeys removes the given locks of the database if they are still held with lock, like Store.UnlockKeys.
+func (m *Memory) UnlockKeys(lock Lock, database string, keys []string) error {
+	names, unlock := m.lockShards(database, keys)
+	defer unlock()
+
+	var notHeld []string
+	now := time.Now()
+	for i, name := range names {
+		s := m.shard(name)
+		held := s.held(name, now)
+		if held == nil {
+			continue
+		}
+		if held.Lock != lock {
+			notHeld = append(notHeld, keys[i])
+			continue
+		}
+		s.remove(name)
+	}
+	if len(notHeld) > 0 {
+		return fmt.Errorf("%w by %q with token %d: %q", ErrNotOwner, lock.Owner, lock.Token, notHeld)
+	}
+	return nil
+}
+
+// ReleaseKeys removes the locks on the given keys of the database whoever holds them.
+func (m *Memory) ReleaseKeys(database string, keys []string) error {
+	names, unlock := m.lockShards(database, keys)
+	defer unlock()
+	for _, name := range names {
+		m.shard(name).remove(name)
+	}
+	return nil
+}
+
+// AwaitUnlocked waits until all the given keys of the numbered redis database are no longer locked, and returns the
+// keys whose locks expired rather than being released.
+func (m *Memory) AwaitUnlocked(ctx context.Context, database string, keys []string) (expired []string, err error) {
+	return m.await(ctx, nil, database, keys)
+}
+
+// AwaitReleased waits until lock is no longer held on any of the given keys of the database, and returns the keys
+// whose lock expired rather than being released.
+func (m *Memory) AwaitReleased(ctx context.Context, lock Lock, database string, keys []string) (expired []string, err error) {
+	return m.await(ctx, &lock, database, keys)
+}
+
+// await waits until the given keys are no longer locked with lock, or at all if lock is nil, queueing on each lock
+// it waits for.
+func (m *Memory) await(ctx context.Context, lock *Lock, database string, keys []string) (expired []string, err error) {
+	wake := make(chan struct{}, 1)
+	// queued are the locks waited on by their name, and keys the keys by the name of their lock.
+	queued := map[string]*memoryLock{}
+	waiting := map[string]string{}
+	for _, key := range keys {
+		waiting[lockName(database, key)] = key
+	}
+	defer func() {
+		for name, l := range queued {
+			s := m.shard(name)
+			s.mu.Lock()
+			l.waiters = slices.DeleteFunc(l.waiters, func(ch chan struct{}) bool { return ch == wake })
+			s.mu.Unlock()
+		}
+	}()
+
+	for {
+		// next is when the first of the locks waited on expires.
+		var next time.Time
+		now := time.Now()
+		for name, key := range waiting {
+			s := m.shard(name)
+			s.mu.Lock()
+			current := s.locks[name]
+			if current != nil && current.expired(now) {
+				s.remove(name)
+			}
+			switch {
+			case current == nil || lock != nil && current.Lock != *lock:
+				// the lock waited on is gone, either released, or expired and maybe taken again.
+				if l := queued[name]; l != nil && l.expired(now) {
+					expired = append(expired, key)
+				}
+			case current.expired(now):
+				expired = append(expired, key)
+			default:
+				if queued[name] != current {
+					current.waiters = append(current.waiters, wake)
+					queued[name] = current
+				}
+				if !current.expires.IsZero() && (next.IsZero() || current.expires.Before(next)) {
+					next = current.expires
+				}
+				s.mu.Unlock()
+				continue
+			}
+			s.mu.Unlock()
+			delete(waiting, name)
+			delete(queued, name)
+		}
+		if len(waiting) == 0 {
+			return expired, nil
+		}
+
+		var expiry <-chan time.Time
+		var timer *time.Timer
+		if !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			expiry = timer.C
+		}
+		select {
+		case <-wake:
+		case <-expiry:
+		case <-ctx.Done():
+			err = context.Cause(ctx)
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return expired, err
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package anarchoredis
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/awinterman/anarchoredis/protocol"
+)
+
+// poolCheckAfter is how long a connection can sit idle in the pool before it is pinged on its way out.
+const poolCheckAfter = time.Second
+
+// upstreamPool is a bounded set of connections to the upstream server shared by client sessions, so that many
+// short-lived clients multiplex onto a few connections instead of each dialing its own.
+type upstreamPool struct {
+	dial func(ctx context.Context) (net.Conn, error)
+	// idleTimeout is how long a connection is kept unused before it is closed, or forever if zero.
+	idleTimeout time.Duration
+	// slots holds a token for every connection taken from the pool, bounding their number. It is nil if the pool is
+	// unbounded.
+	slots chan struct{}
+
+	mu sync.Mutex
+	// idle are the connections returned to the pool, the most recently used last.
+	idle []*upstreamConn
+	// generation is incremented by reset, and connections dialed in an earlier one aren't reused.
+	generation int
+}
+
+// upstreamConn is a connection taken from an upstreamPool.
+type upstreamConn struct {
+	*protocol.Conn
+	conn net.Conn
+	// used is when the connection was last returned to the pool.
+	used time.Time
+	// generation is that of the pool when the connection was dialed.
+	generation int
+}
+
+// newUpstreamPool returns a pool of at most size connections made with dial, or of any number if size is zero.
+func newUpstreamPool(size int, idleTimeout time.Duration, dial func(ctx context.Context) (net.Conn, error)) *upstreamPool {
+	p := &upstreamPool{dial: dial, idleTimeout: idleTimeout}
+	if size > 0 {
+		p.slots = make(chan struct{}, size)
+	}
+	return p
+}
+
+// get takes a connection from the pool, dialing a new one if none is idle, and waits for one to be returned if the
+// pool is at its size.
+func (p *upstreamPool) get(ctx context.Context) (*upstreamConn, error) {
+	if p.slots != nil {
+		select {
+		case p.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		}
+	}
+
+	for c := p.pop(); c != nil; c = p.pop() {
+		if p.healthy(c) {
+			return c, nil
+		}
+		_ = c.conn.Close()
+	}
+
+	p.mu.Lock()
+	generation := p.generation
+	p.mu.Unlock()
+	conn, err := p.dial(ctx)
+	if err != nil {
+		p.free()
+		return nil, err
+	}
+	return &upstreamConn{Conn: protocol.NewConnection(conn), conn: conn, generation: generation}, nil
+}
+
+// put returns a connection to the pool. The connection must have had every reply read from it, and its state must
+// be that of a new connection.
+func (p *upstreamPool) put(c *upstreamConn) {
+	now := time.Now()
+	c.used = now
+
+	if p.stale(c) {
+		p.discard(c)
+		return
+	}
+	p.mu.Lock()
+	p.idle = append(p.idle, c)
+	var expired []*upstreamConn
+	if p.idleTimeout > 0 {
+		i := 0
+		for i < len(p.idle) && now.Sub(p.idle[i].used) > p.idleTimeout {
+			i++
+		}
+		expired = p.idle[:i:i]
+		p.idle = p.idle[i:]
+	}
+	p.mu.Unlock()
+
+	for _, c := range expired {
+		_ = c.conn.Close()
+	}
+	p.free()
+}
+
+// reset closes the idle connections, and those taken from the pool once they are returned, so that new connections
+// are dialed, e.g. to the new leader after a failover. A session pinned to a connection hangs up on its next request,
+// see session.acquire.
+func (p *upstreamPool) reset() {
+	p.mu.Lock()
+	p.generation++
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	for _, c := range idle {
+		_ = c.conn.Close()
+	}
+}
+
+// stale says whether c was dialed before the pool was last reset.
+func (p *upstreamPool) stale(c *upstreamConn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return c.generation != p.generation
+}
+
+// discard closes a connection taken from the pool that can't be reused, e.g. because it failed, or because it has
+// replies left unread.
+func (p *upstreamPool) discard(c *upstreamConn) {
+	_ = c.conn.Close()
+	p.free()
+}
+
+// pop removes the most recently used idle connection from the pool, or returns nil if there is none.
+func (p *upstreamPool) pop() *upstreamConn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.idle) == 0 {
+		return nil
+	}
+	c := p.idle[len(p.idle)-1]
+	p.idle = p.idle[:len(p.idle)-1]
+	return c
+}
+
+// free gives back the slot of a connection taken from the pool.
+func (p *upstreamPool) free() {
+	if p.slots != nil {
+		<-p.slots
+	}
+}
+
+// healthy says whether an idle connection can be reused: it hasn't been idle for longer than the idle timeout, and
+// answers PING if it has been idle for a while.
+func (p *upstreamPool) healthy(c *upstreamConn) bool {
+	idle := time.Since(c.used)
+	if p.idleTimeout > 0 && idle > p.idleTimeout {
+		return false
+	}
+	if idle < poolCheckAfter {
+		return true
+	}
+
+	err := c.conn.SetDeadline(time.Now().Add(poolCheckAfter))
+	if err != nil {
+		return false
+	}
+	pong, err := c.RoundTrip(*protocol.NewOutgoingCommand("PING"))
+	if err != nil || pong.Kind != protocol.SimpleString || pong.SimpleString != "PONG" {
+		return false
+	}
+	return c.conn.SetDeadline(time.Time{}) == nil
+}
@@ -0,0 +1,81 @@
+package anarchoredis
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// TestUpstreamPool tests that connections are reused, bounded in number, and closed when they have been idle too
+// long, fail their health check, or were dialed before a reset.
+func TestUpstreamPool(t *testing.T) {
+	dialed := 0
+	p := newUpstreamPool(2, time.Minute, func(ctx context.Context) (net.Conn, error) {
+		dialed++
+		client, server := tcpPipe(t)
+		fakeUpstream(t, server, nil)
+		return client, nil
+	})
+	ctx := context.Background()
+
+	a, err := p.get(ctx)
+	assert.NilError(t, err)
+	p.put(a)
+	b, err := p.get(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, a, b, "an idle connection is reused")
+	assert.Equal(t, dialed, 1)
+
+	c, err := p.get(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, dialed, 2)
+
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = p.get(timeout)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "the pool is at its size")
+
+	p.discard(c)
+	p.put(b)
+
+	// b is pinged since it has been idle for a while, and answers.
+	b.used = time.Now().Add(-poolCheckAfter)
+	d, err := p.get(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, d, b)
+	assert.Equal(t, dialed, 2)
+
+	// d is pinged, but has been closed.
+	p.put(d)
+	d.used = time.Now().Add(-poolCheckAfter)
+	_ = d.conn.Close()
+	e, err := p.get(ctx)
+	assert.NilError(t, err)
+	assert.Assert(t, e != d)
+	assert.Equal(t, dialed, 3)
+
+	// e has been idle for longer than the timeout.
+	p.put(e)
+	e.used = time.Now().Add(-time.Hour)
+	f, err := p.get(ctx)
+	assert.NilError(t, err)
+	assert.Assert(t, f != e)
+	assert.Equal(t, dialed, 4)
+
+	// a reset closes f, which is idle, and g once it is returned.
+	g, err := p.get(ctx)
+	assert.NilError(t, err)
+	p.put(f)
+	p.reset()
+	h, err := p.get(ctx)
+	assert.NilError(t, err)
+	assert.Assert(t, h != f)
+	p.put(g)
+	i, err := p.get(ctx)
+	assert.NilError(t, err)
+	assert.Assert(t, i != g)
+	assert.Equal(t, dialed, 7)
+}
@@ -0,0 +1,550 @@
+package anarchoredis
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/awinterman/anarchoredis/protocol"
+	"github.com/awinterman/anarchoredis/protocol/message"
+	"github.com/awinterman/anarchoredis/txn/localstate"
+	"github.com/awinterman/anarchoredis/txn/replication"
+	"github.com/dgraph-io/badger/v4"
+	"gotest.tools/v3/assert"
+)
+
+// fakeUpstream replies +OK to writes and commands without arguments, the key to reads and +PONG to PING, and
+// replicates writes to the stream, in place of a redis server. ZADD replies :0 as though the member was there with the
+// same score, and isn't replicated. BLPOP blocks forever, and so does every command after it on the same connection.
+func fakeUpstream(t testing.TB, conn net.Conn, stream *fakeStream) {
+	go func() {
+		upstream := protocol.NewConnection(conn)
+		for {
+			frame, err := upstream.ReadFrame()
+			if err != nil {
+				return
+			}
+			cmd, err := frame.Command()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if cmd.Name == "PING" {
+				_, err = upstream.Write(message.SimpleString("PONG"))
+			} else if cmd.Name == "BLPOP" {
+				_, _ = io.Copy(io.Discard, conn)
+				return
+			} else if cmd.Name == "ZADD" {
+				_, err = upstream.Write(message.Int(0))
+			} else if cmd.IsWrite() {
+				// the frame is only valid until the next read, so the replicated command is decoded from a copy.
+				msg, err := (&message.Encoder{}).Decode(bytes.NewReader(bytes.Clone(frame.Raw)))
+				if err == nil {
+					msg, err = message.Materialize(msg, -1)
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				stream.replicate(msg)
+				_, err = upstream.Write(message.SimpleString("OK"))
+			} else if len(cmd.Args) == 0 {
+				_, err = upstream.Write(message.SimpleString("OK"))
+			} else {
+				_, err = upstream.Write(message.BulkBytes(cmd.Args[0]))
+			}
+			if err == nil && upstream.RW.Reader.Buffered() == 0 {
+				err = upstream.Flush()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// fakeStream is the replication stream shared by the fake upstream servers of a transactor. Each write replicated to it
+// raises the replication offset, and is committed in order by the transactor.
+type fakeStream struct {
+	mu     sync.Mutex
+	offset atomic.Int64
+	writes chan protocol.Message
+}
+
+// newFakeStream returns a fakeStream that commits its writes to transactor until the test ends.
+func newFakeStream(t testing.TB, transactor *Transactor) *fakeStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	stream := &fakeStream{writes: make(chan protocol.Message, maxPipeline)}
+	go func() {
+		a := &applier{Transactor: transactor, database: "0"}
+		for offset := int64(1); ; offset++ {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-stream.writes:
+				err := a.apply(ctx, &msg)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				transactor.committed.advance(offset)
+			}
+		}
+	}()
+	return stream
+}
+
+// replicate adds msg to the stream, at the next offset.
+func (f *fakeStream) replicate(msg protocol.Message) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.offset.Add(1)
+	f.writes <- msg
+}
+
+// newTestTransactor returns a Transactor whose pool dials a new fakeUpstream for each connection, taking at most
+// poolSize, and which asks for the offset of their fakeStream.
+func newTestTransactor(t testing.TB, poolSize int) *Transactor {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	assert.NilError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	transactor := &Transactor{
+		keys:   &localstate.Store{DB: db, Log: slog.New(slog.NewTextHandler(io.Discard, nil)), LockTTL: time.Minute},
+		txnlog: testLog{},
+	}
+	stream := newFakeStream(t, transactor)
+	transactor.upstreams = newUpstreamPool(poolSize, 0, func(ctx context.Context) (net.Conn, error) {
+		upstreamClient, upstreamServer := tcpPipe(t)
+		fakeUpstream(t, upstreamServer, stream)
+		return upstreamClient, nil
+	})
+	serveOffset(t, transactor, &stream.offset)
+	return transactor
+}
+
+// startProxy proxies a client connection through the transactor, and returns the client end.
+func startProxy(t testing.TB, transactor *Transactor) net.Conn {
+	client, proxied := tcpPipe(t)
+	s := transactor.newSession(proxied)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = transactor.proxy(ctx, s)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		_ = client.Close()
+		<-done
+	})
+	return client
+}
+
+// tcpPipe returns both ends of a loopback tcp connection.
+func tcpPipe(t testing.TB) (net.Conn, net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	defer l.Close()
+
+	accepted := make(chan net.Conn)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			t.Error(err)
+		}
+		accepted <- conn
+	}()
+	client, err := net.Dial("tcp", l.Addr().String())
+	assert.NilError(t, err)
+	server := <-accepted
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+	return client, server
+}
+
+// pipeline writes the commands in one go, then reads as many replies.
+func pipeline(conn net.Conn, r *protocol.Conn, commands [][]byte) ([]string, error) {
+	for _, cmd := range commands {
+		_, err := conn.Write(cmd)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var replies []string
+	for range commands {
+		frame, err := r.ReadFrame()
+		if err != nil {
+			return nil, err
+		}
+		replies = append(replies, frame.Message.String())
+	}
+	return replies, nil
+}
+
+// encode encodes a command as a client would send it.
+func encode(args ...string) []byte {
+	b := &bytesWriter{}
+	_, _ = (&message.Encoder{}).Encode(*protocol.NewOutgoingCommand(args...), b)
+	return b.b
+}
+
+type bytesWriter struct{ b []byte }
+
+func (w *bytesWriter) Write(p []byte) (int, error) {
+	w.b = append(w.b, p...)
+	return len(p), nil
+}
+
+// TestProxy_Pipeline tests that the replies to pipelined requests come back in order.
+func TestProxy_Pipeline(t *testing.T) {
+	client := startProxy(t, newTestTransactor(t, 1))
+	r := protocol.NewConnection(client)
+
+	var commands [][]byte
+	var want []string
+	for i := range 100 {
+		key := fmt.Sprintf("key:%d", i)
+		if i%3 == 0 {
+			commands = append(commands, encode("SET", key, "v"))
+			want = append(want, message.SimpleString("OK").String())
+		} else {
+			commands = append(commands, encode("GET", key))
+			want = append(want, message.BulkBytes([]byte(key)).String())
+		}
+	}
+	commands = append(commands, encode("NOTACOMMAND"))
+	want = append(want, protocol.NewError(protocol.ErrNotImplemented).String())
+
+	replies, err := pipeline(client, r, commands)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, replies[:100], want[:100])
+	assert.Assert(t, replies[100][0] == '-', replies[100])
+}
+
+// TestProxy_Pool tests that clients share a pooled upstream connection between requests, except while one is pinned
+// to it by selecting a database.
+func TestProxy_Pool(t *testing.T) {
+	transactor := newTestTransactor(t, 1)
+	first, second := startProxy(t, transactor), startProxy(t, transactor)
+	r1, r2 := protocol.NewConnection(first), protocol.NewConnection(second)
+
+	for _, c := range []struct {
+		conn net.Conn
+		r    *protocol.Conn
+	}{{first, r1}, {second, r2}, {first, r1}} {
+		replies, err := pipeline(c.conn, c.r, [][]byte{encode("GET", "a")})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, replies, []string{message.BulkBytes([]byte("a")).String()})
+	}
+
+	replies, err := pipeline(first, r1, [][]byte{encode("SELECT", "1")})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, replies, []string{message.BulkBytes([]byte("1")).String()})
+
+	// the only connection is pinned to the first client, so the second waits.
+	_, err = second.Write(encode("GET", "b"))
+	assert.NilError(t, err)
+	assert.NilError(t, second.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+	_, err = r2.ReadFrame()
+	var netErr net.Error
+	assert.Assert(t, errors.As(err, &netErr) && netErr.Timeout(), err)
+
+	replies, err = pipeline(first, r1, [][]byte{encode("SELECT", "0")})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, replies, []string{message.BulkBytes([]byte("0")).String()})
+
+	assert.NilError(t, second.SetReadDeadline(time.Now().Add(time.Second)))
+	r2 = protocol.NewConnection(second)
+	frame, err := r2.ReadFrame()
+	assert.NilError(t, err)
+	assert.Equal(t, frame.Message.String(), message.BulkBytes([]byte("b")).String())
+}
+
+// TestProxy_PoolReset tests that a client pinned to an upstream connection is hung up on once the pool is reset, e.g.
+// after a failover, rather than left talking to the old upstream server.
+func TestProxy_PoolReset(t *testing.T) {
+	transactor := newTestTransactor(t, 0)
+	pinned, other := startProxy(t, transactor), startProxy(t, transactor)
+	r1, r2 := protocol.NewConnection(pinned), protocol.NewConnection(other)
+
+	_, err := pipeline(pinned, r1, [][]byte{encode("SELECT", "1")})
+	assert.NilError(t, err)
+	_, err = pipeline(other, r2, [][]byte{encode("GET", "a")})
+	assert.NilError(t, err)
+
+	transactor.upstreams.reset()
+	assert.NilError(t, pinned.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = pipeline(pinned, r1, [][]byte{encode("GET", "a")})
+	assert.ErrorIs(t, err, io.EOF)
+
+	replies, err := pipeline(other, r2, [][]byte{encode("GET", "b")})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, replies, []string{message.BulkBytes([]byte("b")).String()})
+}
+
+// BenchmarkProxy measures the throughput of SET and GET through the proxy with varying numbers of pipelined
+// requests, as with redis-benchmark -P.
+func BenchmarkProxy(b *testing.B) {
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	for _, p := range []int{1, 16, 64} {
+		b.Run(fmt.Sprintf("P=%d", p), func(b *testing.B) {
+			client := startProxy(b, newTestTransactor(b, 1))
+			r := &protocol.Conn{RW: bufio.NewReadWriter(bufio.NewReader(client), bufio.NewWriter(client))}
+
+			var commands [][]byte
+			for i := range p {
+				key := fmt.Sprintf("key:%d", i)
+				if i%2 == 0 {
+					commands = append(commands, encode("SET", key, "value"))
+				} else {
+					commands = append(commands, encode("GET", key))
+				}
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i += p {
+				_, err := pipeline(client, r, commands)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// fakeLeader makes the transactor ask for the replication offset of the upstream server from a fake, which replies
+// with the returned offset rather than that of the replicated writes.
+func fakeLeader(t testing.TB, transactor *Transactor) *atomic.Int64 {
+	var leaderOffset atomic.Int64
+	serveOffset(t, transactor, &leaderOffset)
+	return &leaderOffset
+}
+
+// serveOffset makes the transactor ask for the replication offset of the upstream server from a fake, which replies
+// with offset.
+func serveOffset(t testing.TB, transactor *Transactor, offset *atomic.Int64) {
+	transactor.leader = &replication.Subscriber{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	transactor.offsets = newUpstreamPool(0, 0, func(ctx context.Context) (net.Conn, error) {
+		client, server := tcpPipe(t)
+		go func() {
+			leader := protocol.NewConnection(server)
+			for {
+				_, err := leader.ReadFrame()
+				if err != nil {
+					return
+				}
+				info := fmt.Sprintf("# Replication\r\nrole:master\r\nmaster_repl_offset:%d\r\n", offset.Load())
+				_, err = leader.Write(message.BulkBytes([]byte(info)))
+				if err == nil {
+					err = leader.Flush()
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+		return client, nil
+	})
+}
+
+// TestProxy_AckOffset tests that replies are held until the replication stream has been committed up to the offset
+// of the upstream server when they were read.
+func TestProxy_AckOffset(t *testing.T) {
+	transactor := newTestTransactor(t, 1)
+	transactor.ack = AckOffset
+	leaderOffset := fakeLeader(t, transactor)
+
+	client := startProxy(t, transactor)
+	r := protocol.NewConnection(client)
+
+	for i, cmd := range [][]string{{"SET", "a", "1"}, {"GET", "a"}} {
+		offset := int64(100 * (i + 1))
+		leaderOffset.Store(offset)
+		_, err := client.Write(encode(cmd...))
+		assert.NilError(t, err)
+
+		assert.NilError(t, client.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+		_, err = r.ReadFrame()
+		var netErr net.Error
+		assert.Assert(t, errors.As(err, &netErr) && netErr.Timeout(), err)
+
+		transactor.committed.advance(offset)
+		assert.NilError(t, client.SetReadDeadline(time.Now().Add(time.Second)))
+		r = protocol.NewConnection(client)
+		_, err = r.ReadFrame()
+		assert.NilError(t, err)
+	}
+
+	// a command without keys doesn't wait.
+	leaderOffset.Store(1000)
+	replies, err := pipeline(client, r, [][]byte{encode("PING")})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, replies, []string{message.SimpleString("PONG").String()})
+}
+
+// TestProxy_LockedKeys tests that a write isn't forwarded while another holds the lock on its keys.
+func TestProxy_LockedKeys(t *testing.T) {
+	transactor := newTestTransactor(t, 1)
+	client := startProxy(t, transactor)
+	r := protocol.NewConnection(client)
+
+	other, err := transactor.keys.LockKeys("other", "0", []string{"a"})
+	assert.NilError(t, err)
+
+	_, err = client.Write(encode("SET", "a", "1"))
+	assert.NilError(t, err)
+	assert.NilError(t, client.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+	_, err = r.ReadFrame()
+	var netErr net.Error
+	assert.Assert(t, errors.As(err, &netErr) && netErr.Timeout(), err)
+
+	assert.NilError(t, transactor.keys.UnlockKeys(other, "0", []string{"a"}))
+	assert.NilError(t, client.SetReadDeadline(time.Now().Add(time.Second)))
+	r = protocol.NewConnection(client)
+	frame, err := r.ReadFrame()
+	assert.NilError(t, err)
+	assert.Equal(t, frame.Message.String(), message.SimpleString("OK").String())
+}
+
+// TestProxy_Unreplicated tests that a write that changes nothing, and so isn't replicated, is replied to once the
+// replication stream has been committed up to the offset of the upstream server, and its keys are unlocked.
+func TestProxy_Unreplicated(t *testing.T) {
+	transactor := newTestTransactor(t, 1)
+	leaderOffset := fakeLeader(t, transactor)
+	client := startProxy(t, transactor)
+	r := protocol.NewConnection(client)
+
+	leaderOffset.Store(100)
+	_, err := client.Write(encode("ZADD", "z", "1", "m"))
+	assert.NilError(t, err)
+	assert.NilError(t, client.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+	_, err = r.ReadFrame()
+	var netErr net.Error
+	assert.Assert(t, errors.As(err, &netErr) && netErr.Timeout(), err)
+
+	transactor.committed.advance(100)
+	assert.NilError(t, client.SetReadDeadline(time.Now().Add(time.Second)))
+	r = protocol.NewConnection(client)
+	frame, err := r.ReadFrame()
+	assert.NilError(t, err)
+	assert.Equal(t, frame.Message.String(), message.Int(0).String())
+
+	_, err = transactor.keys.LockKeys("other", "0", []string{"z"})
+	assert.NilError(t, err)
+}
+
+// TestProxy_KeylessWrite tests that a write without keys is replied to once the replication stream has been committed
+// up to the offset of the upstream server, including in a transaction.
+func TestProxy_KeylessWrite(t *testing.T) {
+	transactor := newTestTransactor(t, 1)
+	leaderOffset := fakeLeader(t, transactor)
+	client := startProxy(t, transactor)
+	r := protocol.NewConnection(client)
+
+	for i, commands := range [][][]byte{
+		{encode("FLUSHALL")},
+		{encode("MULTI"), encode("FLUSHDB"), encode("EXEC")},
+	} {
+		offset := int64(100 * (i + 1))
+		leaderOffset.Store(offset)
+		_, err := pipeline(client, r, commands[:len(commands)-1])
+		assert.NilError(t, err)
+		_, err = client.Write(commands[len(commands)-1])
+		assert.NilError(t, err)
+
+		assert.NilError(t, client.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+		_, err = r.ReadFrame()
+		var netErr net.Error
+		assert.Assert(t, errors.As(err, &netErr) && netErr.Timeout(), err)
+
+		transactor.committed.advance(offset)
+		assert.NilError(t, client.SetReadDeadline(time.Now().Add(time.Second)))
+		r = protocol.NewConnection(client)
+		_, err = r.ReadFrame()
+		assert.NilError(t, err)
+	}
+}
+
+// TestProxy_Serializing tests that a serializing command waits for the writes in flight to be committed, but not for a
+// blocked one to be answered.
+func TestProxy_Serializing(t *testing.T) {
+	transactor := newTestTransactor(t, 0)
+	leaderOffset := fakeLeader(t, transactor)
+	blocked, client := startProxy(t, transactor), startProxy(t, transactor)
+	r := protocol.NewConnection(client)
+
+	_, err := blocked.Write(encode("BLPOP", "list", "0"))
+	assert.NilError(t, err)
+	deadline := time.Now().Add(time.Second)
+	for transactor.writing.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, transactor.writing.Load(), int64(1))
+
+	leaderOffset.Store(100)
+	_, err = client.Write(encode("SCRIPT", "LOAD", "return 1"))
+	assert.NilError(t, err)
+	assert.NilError(t, client.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+	_, err = r.ReadFrame()
+	var netErr net.Error
+	assert.Assert(t, errors.As(err, &netErr) && netErr.Timeout(), err)
+
+	transactor.committed.advance(100)
+	assert.NilError(t, client.SetReadDeadline(time.Now().Add(time.Second)))
+	r = protocol.NewConnection(client)
+	_, err = r.ReadFrame()
+	assert.NilError(t, err)
+
+	// a write is no longer in flight once it has been replied to.
+	replies, err := pipeline(client, r, [][]byte{encode("SET", "a", "1")})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, replies, []string{message.SimpleString("OK").String()})
+	assert.Equal(t, transactor.writing.Load(), int64(1))
+}
+
+// TestProxy_SerializingHoldsOff tests that a write isn't forwarded while a serializing command is in flight.
+func TestProxy_SerializingHoldsOff(t *testing.T) {
+	transactor := newTestTransactor(t, 0)
+	leaderOffset := fakeLeader(t, transactor)
+	serializing, client := startProxy(t, transactor), startProxy(t, transactor)
+	r1, r2 := protocol.NewConnection(serializing), protocol.NewConnection(client)
+
+	leaderOffset.Store(100)
+	_, err := serializing.Write(encode("SCRIPT", "LOAD", "return 1"))
+	assert.NilError(t, err)
+	deadline := time.Now().Add(time.Second)
+	for transactor.serials.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, transactor.serials.Load(), int64(1))
+
+	_, err = client.Write(encode("SET", "a", "1"))
+	assert.NilError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, transactor.writing.Load(), int64(0))
+
+	transactor.committed.advance(100)
+	assert.NilError(t, serializing.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = r1.ReadFrame()
+	assert.NilError(t, err)
+	assert.NilError(t, client.SetReadDeadline(time.Now().Add(time.Second)))
+	frame, err := r2.ReadFrame()
+	assert.NilError(t, err)
+	assert.Equal(t, frame.Message.String(), message.SimpleString("OK").String())
+}
@@ -0,0 +1,143 @@
+package rdb
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/awinterman/anarchoredis/protocol"
+)
+
+// chunk bounds the number of elements added by each command that recreates a key, so that a large key doesn't make
+// a command too large to send.
+const chunk = 1024
+
+// Commands returns the commands that recreate the key in the database it is in, once selected, the way redis
+// rewrites its append only file: one or more commands adding the elements of its value, followed by those restoring
+// its metadata, such as its expiry. A module value can't be added element by element, and is restored from its dump.
+func (k Key) Commands() []*protocol.Message {
+	key := string(k.Key)
+	var cmds []*protocol.Message
+	add := func(name string, args [][]byte, per int) {
+		for start := 0; start < len(args); start += chunk * per {
+			cmd := []string{name, key}
+			for _, arg := range args[start:min(start+chunk*per, len(args))] {
+				cmd = append(cmd, string(arg))
+			}
+			cmds = append(cmds, protocol.NewOutgoingCommand(cmd...))
+		}
+	}
+
+	switch v := k.Value.(type) {
+	case String:
+		cmds = append(cmds, protocol.NewOutgoingCommand("SET", key, string(v)))
+	case List:
+		add("RPUSH", v, 1)
+	case Set:
+		add("SADD", v, 1)
+	case SortedSet:
+		args := make([][]byte, 0, 2*len(v))
+		for _, m := range v {
+			args = append(args, []byte(formatScore(m.Score)), m.Member)
+		}
+		add("ZADD", args, 2)
+	case Hash:
+		args := make([][]byte, 0, 2*len(v))
+		for _, f := range v {
+			args = append(args, f.Field, f.Value)
+		}
+		add("HSET", args, 2)
+		for _, f := range v {
+			if !f.ExpireAt.IsZero() {
+				cmds = append(cmds, protocol.NewOutgoingCommand("HPEXPIREAT", key,
+					strconv.FormatInt(f.ExpireAt.UnixMilli(), 10), "FIELDS", "1", string(f.Field)))
+			}
+		}
+	case Stream:
+		cmds = append(cmds, v.commands(key)...)
+	default:
+		return []*protocol.Message{k.Restore()}
+	}
+
+	if !k.ExpireAt.IsZero() {
+		cmds = append(cmds, protocol.NewOutgoingCommand("PEXPIREAT", key, strconv.FormatInt(k.ExpireAt.UnixMilli(), 10)))
+	}
+	return cmds
+}
+
+// commands returns the commands that recreate the stream at key, with its consumer groups and their pending entries.
+func (s Stream) commands(key string) []*protocol.Message {
+	var cmds []*protocol.Message
+	for _, e := range s.Entries {
+		cmd := []string{"XADD", key, e.ID.String()}
+		for _, f := range e.Fields {
+			cmd = append(cmd, string(f))
+		}
+		cmds = append(cmds, protocol.NewOutgoingCommand(cmd...))
+	}
+	if len(s.Entries) == 0 {
+		// a stream is only created by adding to it, which MAXLEN 0 undoes.
+		cmds = append(cmds, protocol.NewOutgoingCommand("XADD", key, "MAXLEN", "0", s.LastID.String(), "x", "y"))
+	}
+	cmds = append(cmds, protocol.NewOutgoingCommand("XSETID", key, s.LastID.String(),
+		"ENTRIESADDED", strconv.FormatUint(s.EntriesAdded, 10), "MAXDELETEDID", s.MaxDeletedID.String()))
+
+	for _, g := range s.Groups {
+		group := string(g.Name)
+		create := []string{"XGROUP", "CREATE", key, group, g.LastID.String()}
+		if g.EntriesRead >= 0 {
+			create = append(create, "ENTRIESREAD", strconv.FormatInt(g.EntriesRead, 10))
+		}
+		cmds = append(cmds, protocol.NewOutgoingCommand(create...))
+
+		pending := make(map[StreamID]PendingEntry, len(g.Pending))
+		for _, p := range g.Pending {
+			pending[p.ID] = p
+		}
+		for _, c := range g.Consumers {
+			consumer := string(c.Name)
+			if len(c.Pending) == 0 {
+				cmds = append(cmds, protocol.NewOutgoingCommand("XGROUP", "CREATECONSUMER", key, group, consumer))
+			}
+			// claiming a pending entry for the consumer creates it, and FORCE adds the entry to the group's.
+			for _, id := range c.Pending {
+				p := pending[id]
+				cmds = append(cmds, protocol.NewOutgoingCommand("XCLAIM", key, group, consumer, "0", id.String(),
+					"TIME", strconv.FormatInt(p.DeliveryTime.UnixMilli(), 10),
+					"RETRYCOUNT", strconv.FormatUint(p.DeliveryCount, 10), "JUSTID", "FORCE"))
+			}
+		}
+	}
+	return cmds
+}
+
+// formatScore formats a score of a sorted set as ZADD takes it.
+func formatScore(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Restore returns the RESTORE command that recreates the key from its dump, replacing it if it exists, with its
+// expiry and eviction hints. The server it is sent to must read the version of the snapshot.
+func (k Key) Restore() *protocol.Message {
+	ttl := "0"
+	if !k.ExpireAt.IsZero() {
+		ttl = strconv.FormatInt(k.ExpireAt.UnixMilli(), 10)
+	}
+	cmd := []string{"RESTORE", string(k.Key), ttl, string(k.Dump), "REPLACE"}
+	if !k.ExpireAt.IsZero() {
+		cmd = append(cmd, "ABSTTL")
+	}
+	if k.Idle >= 0 {
+		cmd = append(cmd, "IDLETIME", strconv.FormatInt(int64(k.Idle/time.Second), 10))
+	}
+	if k.Freq >= 0 {
+		cmd = append(cmd, "FREQ", strconv.Itoa(k.Freq))
+	}
+	return protocol.NewOutgoingCommand(cmd...)
+}
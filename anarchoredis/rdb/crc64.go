@@ -0,0 +1,12 @@
+package rdb
+
+import "hash/crc64"
+
+// crcTable is the table of the CRC64 that redis checksums snapshots and DUMP payloads with, which uses the Jones
+// polynomial, reflected.
+var crcTable = crc64.MakeTable(0x95ac9329ac4bc9b5)
+
+// crc updates the checksum of redis with p. Unlike that of hash/crc64, it isn't inverted before and after.
+func crc(sum uint64, p []byte) uint64 {
+	return ^crc64.Update(^sum, crcTable, p)
+}
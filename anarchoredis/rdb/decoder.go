@@ -0,0 +1,970 @@
+package rdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	// MinVersion and MaxVersion are the versions of the RDB format that can be decoded, as saved by redis 5 to 7.4.
+	MinVersion = 9
+	MaxVersion = 12
+)
+
+// ErrInvalid is returned when a snapshot can't be decoded.
+var ErrInvalid = errors.New("invalid RDB")
+
+// the opcodes that introduce what follows them.
+const (
+	opSlotInfo      = 0xf4
+	opFunction2     = 0xf5
+	opFunctionPreGA = 0xf6
+	opModuleAux     = 0xf7
+	opIdle          = 0xf8
+	opFreq          = 0xf9
+	opAux           = 0xfa
+	opResizeDB      = 0xfb
+	opExpireTimeMs  = 0xfc
+	opExpireTime    = 0xfd
+	opSelectDB      = 0xfe
+	opEOF           = 0xff
+)
+
+// the types of values, which are also the opcodes of keys.
+const (
+	typeString              = 0
+	typeList                = 1
+	typeSet                 = 2
+	typeZSet                = 3
+	typeHash                = 4
+	typeZSet2               = 5
+	typeModule2             = 7
+	typeHashZipmap          = 9
+	typeListZiplist         = 10
+	typeSetIntset           = 11
+	typeZSetZiplist         = 12
+	typeHashZiplist         = 13
+	typeListQuicklist       = 14
+	typeStreamListpacks     = 15
+	typeHashListpack        = 16
+	typeZSetListpack        = 17
+	typeListQuicklist2      = 18
+	typeStreamListpacks2    = 19
+	typeSetListpack         = 20
+	typeStreamListpacks3    = 21
+	typeHashMetadataPreGA   = 22
+	typeHashListpackExPreGA = 23
+	typeHashMetadata        = 24
+	typeHashListpackEx      = 25
+)
+
+// the special encodings of strings.
+const (
+	encInt8  = 0
+	encInt16 = 1
+	encInt32 = 2
+	encLZF   = 3
+)
+
+// the containers of the nodes of a quicklist.
+const (
+	quicklistPlain  = 1
+	quicklistPacked = 2
+)
+
+// the opcodes of the data saved by modules.
+const (
+	moduleEOF    = 0
+	moduleSInt   = 1
+	moduleUInt   = 2
+	moduleFloat  = 3
+	moduleDouble = 4
+	moduleString = 5
+)
+
+// maxLength bounds the length of a string, so that a corrupt length fails rather than allocating it.
+const maxLength = math.MaxInt32
+
+// preallocate bounds how many elements are allocated ahead of reading them.
+const preallocate = 1024
+
+// Decoder reads the records of a snapshot.
+type Decoder struct {
+	r       *bufio.Reader
+	version int
+	// db is the database selected by the last SELECTDB.
+	db uint64
+	// sum is the checksum of what has been read so far.
+	sum uint64
+	// dump collects what is read while dumping, which is the encoding of a value.
+	dump    []byte
+	dumping bool
+	// done says whether the end of the snapshot has been read.
+	done bool
+	buf  [16]byte
+}
+
+// NewDecoder returns a decoder of the snapshot read from r. It may read past the end of the snapshot.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Version returns the version of the RDB format of the snapshot, once a record has been read.
+func (d *Decoder) Version() int {
+	return d.version
+}
+
+// Records ranges over the records of the snapshot, until its end or the first error.
+func (d *Decoder) Records() iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		for {
+			record, err := d.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if !yield(record, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Next reads the next record of the snapshot, or returns io.EOF once the end of the snapshot has been read and its
+// checksum verified.
+func (d *Decoder) Next() (Record, error) {
+	if d.done {
+		return nil, io.EOF
+	}
+	if d.version == 0 {
+		err := d.header()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the expiry and eviction hints of a key come before it.
+	var expireAt time.Time
+	idle, freq := time.Duration(-1), -1
+	for {
+		op, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case opEOF:
+			return nil, d.eof()
+		case opSelectDB:
+			db, err := d.length()
+			if err != nil {
+				return nil, err
+			}
+			d.db = db
+			return SelectDB{DB: db}, nil
+		case opResizeDB:
+			var r ResizeDB
+			err := d.lengths(&r.Size, &r.ExpiresSize)
+			if err != nil {
+				return nil, err
+			}
+			return r, nil
+		case opSlotInfo:
+			var r SlotInfo
+			err := d.lengths(&r.Slot, &r.Size, &r.ExpiresSize)
+			if err != nil {
+				return nil, err
+			}
+			return r, nil
+		case opAux:
+			key, err := d.string()
+			if err != nil {
+				return nil, err
+			}
+			value, err := d.string()
+			if err != nil {
+				return nil, err
+			}
+			return Aux{Key: string(key), Value: string(value)}, nil
+		case opFunction2:
+			code, err := d.string()
+			if err != nil {
+				return nil, err
+			}
+			return Function{Code: string(code)}, nil
+		case opFunctionPreGA:
+			return nil, fmt.Errorf("%w: functions saved by a release candidate of redis 7.0", ErrInvalid)
+		case opModuleAux:
+			return d.moduleAux()
+		case opExpireTime:
+			err := d.read(d.buf[:4])
+			if err != nil {
+				return nil, err
+			}
+			expireAt = time.Unix(int64(int32(binary.LittleEndian.Uint32(d.buf[:4]))), 0)
+		case opExpireTimeMs:
+			expireAt, err = d.millisecondTime()
+			if err != nil {
+				return nil, err
+			}
+		case opIdle:
+			seconds, err := d.length()
+			if err != nil {
+				return nil, err
+			}
+			idle = time.Duration(seconds) * time.Second
+		case opFreq:
+			b, err := d.readByte()
+			if err != nil {
+				return nil, err
+			}
+			freq = int(b)
+		default:
+			return d.key(op, expireAt, idle, freq)
+		}
+	}
+}
+
+// header reads the magic string and the version of the snapshot.
+func (d *Decoder) header() error {
+	err := d.read(d.buf[:9])
+	if err != nil {
+		return err
+	}
+	if string(d.buf[:5]) != "REDIS" {
+		return fmt.Errorf("%w: no REDIS header", ErrInvalid)
+	}
+	version, err := strconv.Atoi(string(d.buf[5:9]))
+	if err != nil || version < MinVersion || version > MaxVersion {
+		return fmt.Errorf("%w: unsupported version %q", ErrInvalid, d.buf[5:9])
+	}
+	d.version = version
+	return nil
+}
+
+// eof verifies the checksum that ends the snapshot, unless the leader saved it without one.
+func (d *Decoder) eof() error {
+	d.done = true
+	sum := d.sum
+	err := d.read(d.buf[:8])
+	if err != nil {
+		return err
+	}
+	if saved := binary.LittleEndian.Uint64(d.buf[:8]); saved != 0 && saved != sum {
+		return fmt.Errorf("%w: checksum %#x, computed %#x", ErrInvalid, saved, sum)
+	}
+	return io.EOF
+}
+
+// key reads a key and its value, of type t.
+func (d *Decoder) key(t byte, expireAt time.Time, idle time.Duration, freq int) (Record, error) {
+	name, err := d.string()
+	if err != nil {
+		return nil, err
+	}
+
+	d.dumping, d.dump = true, []byte{t}
+	value, err := d.value(t)
+	d.dumping = false
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", name, err)
+	}
+	// DUMP is the encoding of the value followed by the version of the encoding and a checksum of both.
+	dump := binary.LittleEndian.AppendUint16(d.dump, uint16(d.version))
+	dump = binary.LittleEndian.AppendUint64(dump, crc(0, dump))
+	d.dump = nil
+
+	return Key{
+		DB:       d.db,
+		Key:      name,
+		ExpireAt: expireAt,
+		Idle:     idle,
+		Freq:     freq,
+		Value:    value,
+		Dump:     dump,
+	}, nil
+}
+
+// value reads a value of type t.
+func (d *Decoder) value(t byte) (Value, error) {
+	switch t {
+	case typeString:
+		s, err := d.string()
+		return String(s), err
+	case typeList, typeSet:
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		elements := make([][]byte, 0, min(n, preallocate))
+		for range n {
+			s, err := d.string()
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, s)
+		}
+		if t == typeList {
+			return List(elements), nil
+		}
+		return Set(elements), nil
+	case typeZSet, typeZSet2:
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		zset := make(SortedSet, 0, min(n, preallocate))
+		for range n {
+			member, err := d.string()
+			if err != nil {
+				return nil, err
+			}
+			var score float64
+			if t == typeZSet {
+				score, err = d.stringDouble()
+			} else {
+				score, err = d.binaryDouble()
+			}
+			if err != nil {
+				return nil, err
+			}
+			zset = append(zset, Member{Member: member, Score: score})
+		}
+		return zset, nil
+	case typeHash:
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		hash := make(Hash, 0, min(n, preallocate))
+		for range n {
+			field, err := d.string()
+			if err != nil {
+				return nil, err
+			}
+			value, err := d.string()
+			if err != nil {
+				return nil, err
+			}
+			hash = append(hash, Field{Field: field, Value: value})
+		}
+		return hash, nil
+	case typeModule2:
+		id, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		return moduleType(id), d.skipModule()
+	case typeListQuicklist, typeListQuicklist2:
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		var list List
+		for range n {
+			container := uint64(quicklistPacked)
+			if t == typeListQuicklist2 {
+				container, err = d.length()
+				if err != nil {
+					return nil, err
+				}
+			}
+			node, err := d.string()
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case container == quicklistPlain:
+				list = append(list, node)
+				continue
+			case container != quicklistPacked:
+				return nil, fmt.Errorf("%w: quicklist container %d", ErrInvalid, container)
+			}
+			var elements [][]byte
+			if t == typeListQuicklist {
+				elements, err = ziplist(node)
+			} else {
+				elements, err = listpack(node)
+			}
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, elements...)
+		}
+		return list, nil
+	case typeStreamListpacks, typeStreamListpacks2, typeStreamListpacks3:
+		return d.stream(t)
+	case typeHashMetadataPreGA, typeHashMetadata:
+		return d.hashMetadata(t)
+	case typeHashListpackExPreGA, typeHashListpackEx:
+		if t == typeHashListpackEx {
+			// the earliest expiry of the fields, which are read anyway.
+			_, err := d.millisecondTime()
+			if err != nil {
+				return nil, err
+			}
+		}
+		b, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		entries, err := listpack(b)
+		if err != nil {
+			return nil, err
+		}
+		return hashWithExpiry(entries)
+	}
+
+	// the rest are encoded in a single string.
+	b, err := d.string()
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case typeHashZipmap:
+		return zipmap(b)
+	case typeSetIntset:
+		elements, err := intset(b)
+		return Set(elements), err
+	case typeListZiplist, typeZSetZiplist, typeHashZiplist:
+		entries, err := ziplist(b)
+		if err != nil {
+			return nil, err
+		}
+		return pairs(t, entries)
+	case typeHashListpack, typeZSetListpack, typeSetListpack:
+		entries, err := listpack(b)
+		if err != nil {
+			return nil, err
+		}
+		return pairs(t, entries)
+	}
+	return nil, fmt.Errorf("%w: value type %d", ErrInvalid, t)
+}
+
+// pairs makes a value of type t of the entries of a ziplist or listpack, which alternate members and scores, or
+// fields and values.
+func pairs(t byte, entries [][]byte) (Value, error) {
+	switch t {
+	case typeListZiplist:
+		return List(entries), nil
+	case typeSetListpack:
+		return Set(entries), nil
+	}
+	if len(entries)%2 != 0 {
+		return nil, fmt.Errorf("%w: odd number of entries", ErrInvalid)
+	}
+	if t == typeHashZiplist || t == typeHashListpack {
+		hash := make(Hash, 0, len(entries)/2)
+		for i := 0; i < len(entries); i += 2 {
+			hash = append(hash, Field{Field: entries[i], Value: entries[i+1]})
+		}
+		return hash, nil
+	}
+	zset := make(SortedSet, 0, len(entries)/2)
+	for i := 0; i < len(entries); i += 2 {
+		score, err := strconv.ParseFloat(string(entries[i+1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: score %q", ErrInvalid, entries[i+1])
+		}
+		zset = append(zset, Member{Member: entries[i], Score: score})
+	}
+	return zset, nil
+}
+
+// hashWithExpiry makes a hash of the entries of a listpack, which are triples of a field, its value, and when it
+// expires in unix milliseconds, or 0.
+func hashWithExpiry(entries [][]byte) (Hash, error) {
+	if len(entries)%3 != 0 {
+		return nil, fmt.Errorf("%w: hash entries aren't triples", ErrInvalid)
+	}
+	hash := make(Hash, 0, len(entries)/3)
+	for i := 0; i < len(entries); i += 3 {
+		ms, err := strconv.ParseInt(string(entries[i+2]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: field expiry %q", ErrInvalid, entries[i+2])
+		}
+		field := Field{Field: entries[i], Value: entries[i+1]}
+		if ms != 0 {
+			field.ExpireAt = time.UnixMilli(ms)
+		}
+		hash = append(hash, field)
+	}
+	return hash, nil
+}
+
+// hashMetadata reads a hash with fields that expire. Before redis 7.4 was released, their expiry was saved as is, and
+// since, relative to the earliest, which is saved first.
+func (d *Decoder) hashMetadata(t byte) (Hash, error) {
+	var earliest int64
+	if t == typeHashMetadata {
+		at, err := d.millisecondTime()
+		if err != nil {
+			return nil, err
+		}
+		earliest = at.UnixMilli()
+	}
+	n, err := d.length()
+	if err != nil {
+		return nil, err
+	}
+	hash := make(Hash, 0, min(n, preallocate))
+	for range n {
+		ttl, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		field, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		f := Field{Field: field, Value: value}
+		switch {
+		case ttl == 0:
+		case t == typeHashMetadataPreGA:
+			f.ExpireAt = time.UnixMilli(int64(ttl))
+		default:
+			// one is added to the relative expiry, so that zero still means none.
+			f.ExpireAt = time.UnixMilli(earliest + int64(ttl) - 1)
+		}
+		hash = append(hash, f)
+	}
+	return hash, nil
+}
+
+// the flags of a stream entry.
+const (
+	streamDeleted    = 1
+	streamSameFields = 2
+)
+
+// stream reads a stream, saved as a listpack of entries per node of its radix tree, followed by its metadata and
+// consumer groups.
+func (d *Decoder) stream(t byte) (Stream, error) {
+	var s Stream
+	nodes, err := d.length()
+	if err != nil {
+		return s, err
+	}
+	for range nodes {
+		key, err := d.string()
+		if err != nil {
+			return s, err
+		}
+		if len(key) != 16 {
+			return s, fmt.Errorf("%w: stream node key %q", ErrInvalid, key)
+		}
+		master := StreamID{Ms: binary.BigEndian.Uint64(key), Seq: binary.BigEndian.Uint64(key[8:])}
+		b, err := d.string()
+		if err != nil {
+			return s, err
+		}
+		entries, err := listpack(b)
+		if err != nil {
+			return s, err
+		}
+		s.Entries, err = streamEntries(s.Entries, master, entries)
+		if err != nil {
+			return s, err
+		}
+	}
+
+	err = d.lengths(&s.Length, &s.LastID.Ms, &s.LastID.Seq)
+	if err != nil {
+		return s, err
+	}
+	if t == typeStreamListpacks {
+		// older streams don't keep track of what was deleted.
+		s.EntriesAdded = s.Length
+		if len(s.Entries) > 0 {
+			s.FirstID = s.Entries[0].ID
+		}
+	} else {
+		err = d.lengths(&s.FirstID.Ms, &s.FirstID.Seq, &s.MaxDeletedID.Ms, &s.MaxDeletedID.Seq, &s.EntriesAdded)
+		if err != nil {
+			return s, err
+		}
+	}
+
+	groups, err := d.length()
+	if err != nil {
+		return s, err
+	}
+	for range groups {
+		g := ConsumerGroup{EntriesRead: -1}
+		g.Name, err = d.string()
+		if err != nil {
+			return s, err
+		}
+		err = d.lengths(&g.LastID.Ms, &g.LastID.Seq)
+		if err != nil {
+			return s, err
+		}
+		if t != typeStreamListpacks {
+			read, err := d.length()
+			if err != nil {
+				return s, err
+			}
+			// an unknown count is saved as -1.
+			g.EntriesRead = int64(read)
+		}
+
+		pending, err := d.length()
+		if err != nil {
+			return s, err
+		}
+		for range pending {
+			var p PendingEntry
+			p.ID, err = d.rawStreamID()
+			if err != nil {
+				return s, err
+			}
+			p.DeliveryTime, err = d.millisecondTime()
+			if err != nil {
+				return s, err
+			}
+			p.DeliveryCount, err = d.length()
+			if err != nil {
+				return s, err
+			}
+			g.Pending = append(g.Pending, p)
+		}
+
+		consumers, err := d.length()
+		if err != nil {
+			return s, err
+		}
+		for range consumers {
+			var c Consumer
+			c.Name, err = d.string()
+			if err != nil {
+				return s, err
+			}
+			c.SeenTime, err = d.millisecondTime()
+			if err != nil {
+				return s, err
+			}
+			c.ActiveTime = c.SeenTime
+			if t == typeStreamListpacks3 {
+				c.ActiveTime, err = d.millisecondTime()
+				if err != nil {
+					return s, err
+				}
+			}
+			n, err := d.length()
+			if err != nil {
+				return s, err
+			}
+			for range n {
+				id, err := d.rawStreamID()
+				if err != nil {
+					return s, err
+				}
+				c.Pending = append(c.Pending, id)
+			}
+			g.Consumers = append(g.Consumers, c)
+		}
+		s.Groups = append(s.Groups, g)
+	}
+	return s, nil
+}
+
+// streamEntries appends the entries of a node of a stream to into, skipping those deleted. The node starts with a
+// master entry, whose fields the entries with the same fields leave out, and IDs are relative to its own:
+//
+//	count, deleted, number of fields, field..., 0
+//	flags, ms, seq, [number of fields], [field], value, ..., number of listpack entries
+func streamEntries(into []StreamEntry, master StreamID, lp [][]byte) ([]StreamEntry, error) {
+	at := func(i int) (int64, error) {
+		if i >= len(lp) {
+			return 0, fmt.Errorf("%w: stream node cut short", ErrInvalid)
+		}
+		n, err := strconv.ParseInt(string(lp[i]), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: stream node entry %q", ErrInvalid, lp[i])
+		}
+		return n, nil
+	}
+	span := func(i int, n int64) ([][]byte, error) {
+		if n < 0 || int64(len(lp)-i) < n {
+			return nil, fmt.Errorf("%w: stream node cut short", ErrInvalid)
+		}
+		return lp[i : i+int(n)], nil
+	}
+
+	n, err := at(2)
+	if err != nil {
+		return into, err
+	}
+	fields, err := span(3, n)
+	if err != nil {
+		return into, err
+	}
+	i := 3 + len(fields) + 1
+	for i < len(lp) {
+		var header [3]int64
+		for j := range header {
+			header[j], err = at(i + j)
+			if err != nil {
+				return into, err
+			}
+		}
+		i += 3
+		flags := header[0]
+		// the differences wrap around, e.g. the sequence of a later millisecond.
+		entry := StreamEntry{ID: StreamID{Ms: master.Ms + uint64(header[1]), Seq: master.Seq + uint64(header[2])}}
+		if flags&streamSameFields != 0 {
+			values, err := span(i, int64(len(fields)))
+			if err != nil {
+				return into, err
+			}
+			for j := range fields {
+				entry.Fields = append(entry.Fields, fields[j], values[j])
+			}
+			i += len(values)
+		} else {
+			n, err := at(i)
+			if err != nil {
+				return into, err
+			}
+			entry.Fields, err = span(i+1, 2*n)
+			if err != nil {
+				return into, err
+			}
+			i += 1 + len(entry.Fields)
+		}
+		// the number of listpack entries of the entry, which lets it be read backwards.
+		i++
+		if flags&streamDeleted == 0 {
+			into = append(into, entry)
+		}
+	}
+	return into, nil
+}
+
+// moduleAux reads the data saved by a module outside of any key.
+func (d *Decoder) moduleAux() (Record, error) {
+	var id, when, opcode uint64
+	err := d.lengths(&id, &opcode, &when)
+	if err != nil {
+		return nil, err
+	}
+	if opcode != moduleUInt {
+		return nil, fmt.Errorf("%w: module aux opcode %d", ErrInvalid, opcode)
+	}
+	err = d.skipModule()
+	if err != nil {
+		return nil, err
+	}
+	return ModuleAux{Module: moduleType(id), When: when}, nil
+}
+
+// moduleCharset are the characters of the names of module types.
+const moduleCharset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+// moduleType decodes the ID of a module type: its 9 character name, 6 bits per character, followed by 10 bits of
+// version.
+func moduleType(id uint64) Module {
+	var name [9]byte
+	bits := id >> 10
+	for i := len(name) - 1; i >= 0; i-- {
+		name[i] = moduleCharset[bits&63]
+		bits >>= 6
+	}
+	return Module{Name: string(name[:]), Version: int(id & 1023)}
+}
+
+// skipModule skips the data saved by a module, which is a sequence of typed values ending with moduleEOF.
+func (d *Decoder) skipModule() error {
+	for {
+		opcode, err := d.length()
+		if err != nil {
+			return err
+		}
+		switch opcode {
+		case moduleEOF:
+			return nil
+		case moduleSInt, moduleUInt:
+			_, err = d.length()
+		case moduleFloat:
+			err = d.read(d.buf[:4])
+		case moduleDouble:
+			err = d.read(d.buf[:8])
+		case moduleString:
+			_, err = d.string()
+		default:
+			err = fmt.Errorf("%w: module opcode %d", ErrInvalid, opcode)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// read reads exactly len(p) bytes, which count towards the checksum, and the dump.
+func (d *Decoder) read(p []byte) error {
+	_, err := io.ReadFull(d.r, p)
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	d.sum = crc(d.sum, p)
+	if d.dumping {
+		d.dump = append(d.dump, p...)
+	}
+	return nil
+}
+
+func (d *Decoder) readByte() (byte, error) {
+	err := d.read(d.buf[:1])
+	return d.buf[0], err
+}
+
+// length reads a length.
+func (d *Decoder) length() (uint64, error) {
+	n, encoded, err := d.lengthOrEncoding()
+	if err == nil && encoded {
+		err = fmt.Errorf("%w: encoded string in place of a length", ErrInvalid)
+	}
+	return n, err
+}
+
+// lengths reads lengths into each of ns.
+func (d *Decoder) lengths(ns ...*uint64) error {
+	for _, n := range ns {
+		var err error
+		*n, err = d.length()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lengthOrEncoding reads a length, whose first two bits say how long it is, or, if they are set, the special
+// encoding of the string that follows.
+func (d *Decoder) lengthOrEncoding() (n uint64, encoded bool, err error) {
+	b, err := d.readByte()
+	if err != nil {
+		return 0, false, err
+	}
+	switch b >> 6 {
+	case 0:
+		return uint64(b & 0x3f), false, nil
+	case 1:
+		next, err := d.readByte()
+		return uint64(b&0x3f)<<8 | uint64(next), false, err
+	case 3:
+		return uint64(b & 0x3f), true, nil
+	}
+	switch b {
+	case 0x80:
+		err = d.read(d.buf[:4])
+		return uint64(binary.BigEndian.Uint32(d.buf[:4])), false, err
+	case 0x81:
+		err = d.read(d.buf[:8])
+		return binary.BigEndian.Uint64(d.buf[:8]), false, err
+	}
+	return 0, false, fmt.Errorf("%w: length encoding %#x", ErrInvalid, b)
+}
+
+// string reads a string, which is either raw, an integer, or compressed with LZF.
+func (d *Decoder) string() ([]byte, error) {
+	n, encoded, err := d.lengthOrEncoding()
+	if err != nil {
+		return nil, err
+	}
+	if !encoded {
+		return d.bytes(n)
+	}
+	switch n {
+	case encInt8:
+		b, err := d.readByte()
+		return strconv.AppendInt(nil, int64(int8(b)), 10), err
+	case encInt16:
+		err := d.read(d.buf[:2])
+		return strconv.AppendInt(nil, int64(int16(binary.LittleEndian.Uint16(d.buf[:2]))), 10), err
+	case encInt32:
+		err := d.read(d.buf[:4])
+		return strconv.AppendInt(nil, int64(int32(binary.LittleEndian.Uint32(d.buf[:4]))), 10), err
+	case encLZF:
+		var compressed, length uint64
+		err := d.lengths(&compressed, &length)
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.bytes(compressed)
+		if err != nil {
+			return nil, err
+		}
+		if length > maxLength {
+			return nil, fmt.Errorf("%w: string of %d bytes", ErrInvalid, length)
+		}
+		return lzf(b, int(length))
+	}
+	return nil, fmt.Errorf("%w: string encoding %d", ErrInvalid, n)
+}
+
+// bytes reads n bytes.
+func (d *Decoder) bytes(n uint64) ([]byte, error) {
+	if n > maxLength {
+		return nil, fmt.Errorf("%w: string of %d bytes", ErrInvalid, n)
+	}
+	b := make([]byte, n)
+	return b, d.read(b)
+}
+
+// stringDouble reads a double saved as a string, whose length is read first, with lengths that stand for NaN and
+// infinities.
+func (d *Decoder) stringDouble() (float64, error) {
+	n, err := d.readByte()
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 253:
+		return math.NaN(), nil
+	case 254:
+		return math.Inf(1), nil
+	case 255:
+		return math.Inf(-1), nil
+	}
+	b, err := d.bytes(uint64(n))
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: double %q", ErrInvalid, b)
+	}
+	return f, nil
+}
+
+// binaryDouble reads a double saved as its little endian IEEE 754 encoding.
+func (d *Decoder) binaryDouble() (float64, error) {
+	err := d.read(d.buf[:8])
+	return math.Float64frombits(binary.LittleEndian.Uint64(d.buf[:8])), err
+}
+
+// millisecondTime reads a time saved as little endian unix milliseconds.
+func (d *Decoder) millisecondTime() (time.Time, error) {
+	err := d.read(d.buf[:8])
+	return time.UnixMilli(int64(binary.LittleEndian.Uint64(d.buf[:8]))), err
+}
+
+// rawStreamID reads a stream ID saved as its big endian milliseconds and sequence.
+func (d *Decoder) rawStreamID() (StreamID, error) {
+	err := d.read(d.buf[:16])
+	return StreamID{Ms: binary.BigEndian.Uint64(d.buf[:8]), Seq: binary.BigEndian.Uint64(d.buf[8:16])}, err
+}
@@ -0,0 +1,448 @@
+package rdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awinterman/anarchoredis/protocol"
+	"gotest.tools/v3/assert"
+)
+
+// snapshot builds a snapshot as redis saves it.
+type snapshot struct {
+	bytes.Buffer
+}
+
+func newSnapshot(version string) *snapshot {
+	s := &snapshot{}
+	s.WriteString("REDIS" + version)
+	return s
+}
+
+func (s *snapshot) op(b ...byte) *snapshot {
+	s.Write(b)
+	return s
+}
+
+func (s *snapshot) length(n uint64) *snapshot {
+	switch {
+	case n < 1<<6:
+		s.WriteByte(byte(n))
+	case n < 1<<14:
+		s.WriteByte(byte(n>>8) | 0x40)
+		s.WriteByte(byte(n))
+	case n <= math.MaxUint32:
+		s.WriteByte(0x80)
+		s.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	default:
+		s.WriteByte(0x81)
+		s.Write(binary.BigEndian.AppendUint64(nil, n))
+	}
+	return s
+}
+
+func (s *snapshot) string(str string) *snapshot {
+	s.length(uint64(len(str)))
+	s.WriteString(str)
+	return s
+}
+
+func (s *snapshot) ms(t time.Time) *snapshot {
+	s.Write(binary.LittleEndian.AppendUint64(nil, uint64(t.UnixMilli())))
+	return s
+}
+
+func (s *snapshot) streamID(ms, seq uint64) *snapshot {
+	s.Write(binary.BigEndian.AppendUint64(binary.BigEndian.AppendUint64(nil, ms), seq))
+	return s
+}
+
+// end ends the snapshot with its checksum.
+func (s *snapshot) end() []byte {
+	s.WriteByte(opEOF)
+	return binary.LittleEndian.AppendUint64(s.Bytes(), crc(0, s.Bytes()))
+}
+
+// listpackOf encodes entries, strings or ints, as a listpack.
+func listpackOf(entries ...any) string {
+	var body []byte
+	for _, e := range entries {
+		var entry []byte
+		switch e := e.(type) {
+		case string:
+			if len(e) < 64 {
+				entry = append([]byte{0x80 | byte(len(e))}, e...)
+			} else {
+				entry = append([]byte{0xe0 | byte(len(e)>>8), byte(len(e))}, e...)
+			}
+		case int:
+			switch {
+			case e >= 0 && e < 128:
+				entry = []byte{byte(e)}
+			case e >= -4096 && e < 4096:
+				u := uint16(e) & 0x1fff
+				entry = []byte{0xc0 | byte(u>>8), byte(u)}
+			case e >= math.MinInt16 && e <= math.MaxInt16:
+				entry = binary.LittleEndian.AppendUint16([]byte{0xf1}, uint16(e))
+			case e >= math.MinInt32 && e <= math.MaxInt32:
+				entry = binary.LittleEndian.AppendUint32([]byte{0xf3}, uint32(e))
+			default:
+				entry = binary.LittleEndian.AppendUint64([]byte{0xf4}, uint64(e))
+			}
+		}
+		// the content of the back length doesn't matter to the decoder, only its size.
+		body = append(append(body, entry...), make([]byte, backlenSize(len(entry)))...)
+	}
+	lp := binary.LittleEndian.AppendUint32(nil, uint32(6+len(body)+1))
+	lp = binary.LittleEndian.AppendUint16(lp, uint16(len(entries)))
+	return string(append(append(lp, body...), 0xff))
+}
+
+// ziplistOf encodes entries, strings or ints, as a ziplist.
+func ziplistOf(entries ...any) string {
+	var body []byte
+	prev := 0
+	for _, e := range entries {
+		var entry []byte
+		switch e := e.(type) {
+		case string:
+			entry = append([]byte{byte(len(e))}, e...)
+		case int:
+			switch {
+			case e >= 0 && e <= 12:
+				entry = []byte{0xf1 + byte(e)}
+			case e >= math.MinInt8 && e <= math.MaxInt8:
+				entry = []byte{0xfe, byte(e)}
+			case e >= math.MinInt16 && e <= math.MaxInt16:
+				entry = binary.LittleEndian.AppendUint16([]byte{0xc0}, uint16(e))
+			default:
+				entry = binary.LittleEndian.AppendUint64([]byte{0xe0}, uint64(e))
+			}
+		}
+		entry = append([]byte{byte(prev)}, entry...)
+		prev = len(entry)
+		body = append(body, entry...)
+	}
+	zl := binary.LittleEndian.AppendUint32(nil, uint32(10+len(body)+1))
+	zl = binary.LittleEndian.AppendUint32(zl, 0)
+	zl = binary.LittleEndian.AppendUint16(zl, uint16(len(entries)))
+	return string(append(append(zl, body...), 0xff))
+}
+
+// moduleID encodes the name and version of a module type as its ID.
+func moduleID(name string, version int) uint64 {
+	var id uint64
+	for _, c := range []byte(name) {
+		id = id<<6 | uint64(strings.IndexByte(moduleCharset, c))
+	}
+	return id<<10 | uint64(version)
+}
+
+// TestCRC tests the checksum against the check value of CRC-64/Jones, as redis checks it.
+func TestCRC(t *testing.T) {
+	assert.Equal(t, crc(0, []byte("123456789")), uint64(0xe9c6d914c4b8d9ca))
+}
+
+// TestDecoder tests that every encoding of every type is decoded, along with the metadata of the snapshot.
+func TestDecoder(t *testing.T) {
+	expire := time.UnixMilli(1700000000123)
+	seen := time.UnixMilli(1700000000000)
+
+	s := newSnapshot("0012")
+	s.op(opAux).string("redis-ver").string("7.4.0")
+	s.op(opAux).string("repl-offset").op(0xc0|encInt16, 0x39, 0x30)
+	s.op(opFunction2).string("#!lua name=lib")
+	s.op(opModuleAux).length(moduleID("auxmodule", 2)).length(moduleUInt).length(2).
+		length(moduleString).string("aux").length(moduleEOF)
+	s.op(opSelectDB).length(0)
+	s.op(opResizeDB).length(20).length(1)
+
+	s.op(opExpireTimeMs).ms(expire)
+	s.op(typeString).string("string").string("value")
+	s.op(opIdle).length(30)
+	s.op(typeString).string("int").op(0xc0 | encInt32).op(binary.LittleEndian.AppendUint32(nil, uint32(1<<20))...)
+	// "a" followed by a reference to it, 9 times.
+	s.op(opFreq, 7)
+	s.op(typeString).string("lzf").op(0xc0|encLZF).length(5).length(10).op(0x00, 'a', 0xe0, 0x00, 0x00)
+
+	s.op(typeList).string("linkedlist").length(2).string("a").string("b")
+	s.op(typeListQuicklist).string("quicklist").length(2).
+		string(ziplistOf("a", 1, -100)).string(ziplistOf(1000, "b"))
+	s.op(typeListQuicklist2).string("quicklist2").length(2).
+		length(quicklistPacked).string(listpackOf("a", 5, -1000, 30000, 1<<40, strings.Repeat("x", 100))).
+		length(quicklistPlain).string("plain")
+	s.op(typeListZiplist).string("ziplist").string(ziplistOf("z", 7))
+
+	s.op(typeSet).string("set").length(2).string("a").string("b")
+	intsetBytes := binary.LittleEndian.AppendUint32(nil, 2)
+	intsetBytes = binary.LittleEndian.AppendUint32(intsetBytes, 2)
+	intsetBytes = binary.LittleEndian.AppendUint16(binary.LittleEndian.AppendUint16(intsetBytes, uint16(0xffff)), 3)
+	s.op(typeSetIntset).string("intset").string(string(intsetBytes))
+	s.op(typeSetListpack).string("setlistpack").string(listpackOf("a", 1))
+
+	s.op(typeZSet).string("zset").length(2).string("a").op(3).op([]byte("1.5")...).string("b").op(254)
+	s.op(typeZSet2).string("zset2").length(1).string("a").
+		op(binary.LittleEndian.AppendUint64(nil, math.Float64bits(-2.25))...)
+	s.op(typeZSetZiplist).string("zsetziplist").string(ziplistOf("a", 1, "b", "2.5"))
+	s.op(typeZSetListpack).string("zsetlistpack").string(listpackOf("a", 1, "b", "-inf"))
+
+	s.op(typeHash).string("hash").length(1).string("f").string("v")
+	s.op(typeHashZipmap).string("zipmap").string(string([]byte{1, 1, 'f', 1, 2, 'v', 0, 0, 0xff}))
+	s.op(typeHashZiplist).string("hashziplist").string(ziplistOf("f", 1))
+	s.op(typeHashListpack).string("hashlistpack").string(listpackOf("f", "v"))
+	s.op(typeHashMetadata).string("hashmetadata").ms(expire).length(2).
+		length(0).string("f").string("v").
+		length(11).string("g").string("w")
+	s.op(typeHashListpackEx).string("hashlistpackex").ms(expire).
+		string(listpackOf("f", "v", 0, "g", "w", int(expire.UnixMilli())))
+
+	s.op(typeStreamListpacks3).string("stream").length(1).
+		length(16).streamID(1, 0).
+		string(listpackOf(
+			// the master entry, with two live entries, one deleted, and the field f.
+			2, 1, 1, "f", 0,
+			// 1-0 with the master fields.
+			streamSameFields, 0, 0, "v1", 4,
+			// 2-0 with its own fields.
+			0, 1, 0, 2, "a", "1", "b", "2", 7,
+			// 3-0, deleted.
+			streamSameFields|streamDeleted, 2, 0, "gone", 4,
+		)).
+		length(2).length(3).length(0).
+		length(1).length(0).length(3).length(0).length(3).
+		length(1).string("g").length(2).length(0).length(2).
+		length(1).streamID(1, 0).ms(seen).length(1).
+		length(1).string("c").ms(seen).ms(seen).length(1).streamID(1, 0)
+
+	s.op(typeModule2).string("module").length(moduleID("mymodtype", 1)).
+		length(moduleSInt).length(5).
+		length(moduleFloat).op(0, 0, 0, 0).
+		length(moduleDouble).op(0, 0, 0, 0, 0, 0, 0, 0).
+		length(moduleString).string("data").
+		length(moduleEOF)
+
+	s.op(opSelectDB).length(1)
+	s.op(typeString).string("other").string("db")
+
+	want := []Record{
+		Aux{Key: "redis-ver", Value: "7.4.0"},
+		Aux{Key: "repl-offset", Value: "12345"},
+		Function{Code: "#!lua name=lib"},
+		ModuleAux{Module: Module{Name: "auxmodule", Version: 2}, When: 2},
+		SelectDB{DB: 0},
+		ResizeDB{Size: 20, ExpiresSize: 1},
+		Key{Key: []byte("string"), ExpireAt: expire, Idle: -1, Freq: -1, Value: String("value")},
+		Key{Key: []byte("int"), Idle: 30 * time.Second, Freq: -1, Value: String("1048576")},
+		Key{Key: []byte("lzf"), Idle: -1, Freq: 7, Value: String("aaaaaaaaaa")},
+		Key{Key: []byte("linkedlist"), Idle: -1, Freq: -1, Value: List{[]byte("a"), []byte("b")}},
+		Key{Key: []byte("quicklist"), Idle: -1, Freq: -1, Value: List{
+			[]byte("a"), []byte("1"), []byte("-100"), []byte("1000"), []byte("b"),
+		}},
+		Key{Key: []byte("quicklist2"), Idle: -1, Freq: -1, Value: List{
+			[]byte("a"), []byte("5"), []byte("-1000"), []byte("30000"), []byte("1099511627776"),
+			[]byte(strings.Repeat("x", 100)), []byte("plain"),
+		}},
+		Key{Key: []byte("ziplist"), Idle: -1, Freq: -1, Value: List{[]byte("z"), []byte("7")}},
+		Key{Key: []byte("set"), Idle: -1, Freq: -1, Value: Set{[]byte("a"), []byte("b")}},
+		Key{Key: []byte("intset"), Idle: -1, Freq: -1, Value: Set{[]byte("-1"), []byte("3")}},
+		Key{Key: []byte("setlistpack"), Idle: -1, Freq: -1, Value: Set{[]byte("a"), []byte("1")}},
+		Key{Key: []byte("zset"), Idle: -1, Freq: -1, Value: SortedSet{
+			{Member: []byte("a"), Score: 1.5}, {Member: []byte("b"), Score: math.Inf(1)},
+		}},
+		Key{Key: []byte("zset2"), Idle: -1, Freq: -1, Value: SortedSet{{Member: []byte("a"), Score: -2.25}}},
+		Key{Key: []byte("zsetziplist"), Idle: -1, Freq: -1, Value: SortedSet{
+			{Member: []byte("a"), Score: 1}, {Member: []byte("b"), Score: 2.5},
+		}},
+		Key{Key: []byte("zsetlistpack"), Idle: -1, Freq: -1, Value: SortedSet{
+			{Member: []byte("a"), Score: 1}, {Member: []byte("b"), Score: math.Inf(-1)},
+		}},
+		Key{Key: []byte("hash"), Idle: -1, Freq: -1, Value: Hash{{Field: []byte("f"), Value: []byte("v")}}},
+		Key{Key: []byte("zipmap"), Idle: -1, Freq: -1, Value: Hash{{Field: []byte("f"), Value: []byte("v")}}},
+		Key{Key: []byte("hashziplist"), Idle: -1, Freq: -1, Value: Hash{{Field: []byte("f"), Value: []byte("1")}}},
+		Key{Key: []byte("hashlistpack"), Idle: -1, Freq: -1, Value: Hash{{Field: []byte("f"), Value: []byte("v")}}},
+		Key{Key: []byte("hashmetadata"), Idle: -1, Freq: -1, Value: Hash{
+			{Field: []byte("f"), Value: []byte("v")},
+			{Field: []byte("g"), Value: []byte("w"), ExpireAt: expire.Add(10 * time.Millisecond)},
+		}},
+		Key{Key: []byte("hashlistpackex"), Idle: -1, Freq: -1, Value: Hash{
+			{Field: []byte("f"), Value: []byte("v")},
+			{Field: []byte("g"), Value: []byte("w"), ExpireAt: expire},
+		}},
+		Key{Key: []byte("stream"), Idle: -1, Freq: -1, Value: Stream{
+			Entries: []StreamEntry{
+				{ID: StreamID{1, 0}, Fields: [][]byte{[]byte("f"), []byte("v1")}},
+				{ID: StreamID{2, 0}, Fields: [][]byte{[]byte("a"), []byte("1"), []byte("b"), []byte("2")}},
+			},
+			Length:       2,
+			LastID:       StreamID{3, 0},
+			FirstID:      StreamID{1, 0},
+			MaxDeletedID: StreamID{3, 0},
+			EntriesAdded: 3,
+			Groups: []ConsumerGroup{{
+				Name:        []byte("g"),
+				LastID:      StreamID{2, 0},
+				EntriesRead: 2,
+				Pending:     []PendingEntry{{ID: StreamID{1, 0}, DeliveryTime: seen, DeliveryCount: 1}},
+				Consumers: []Consumer{{
+					Name: []byte("c"), SeenTime: seen, ActiveTime: seen, Pending: []StreamID{{1, 0}},
+				}},
+			}},
+		}},
+		Key{Key: []byte("module"), Idle: -1, Freq: -1, Value: Module{Name: "mymodtype", Version: 1}},
+		SelectDB{DB: 1},
+		Key{DB: 1, Key: []byte("other"), Idle: -1, Freq: -1, Value: String("db")},
+	}
+
+	d := NewDecoder(bytes.NewReader(s.end()))
+	var got []Record
+	for record, err := range d.Records() {
+		assert.NilError(t, err)
+		if key, ok := record.(Key); ok {
+			assert.Assert(t, len(key.Dump) > 10)
+			key.Dump = nil
+			record = key
+		}
+		got = append(got, record)
+	}
+	assert.Equal(t, d.Version(), 12)
+	assert.DeepEqual(t, got, want)
+
+	_, err := d.Next()
+	assert.Assert(t, errors.Is(err, io.EOF))
+}
+
+// TestDecoder_Dump tests that the dump of a key is that of DUMP, as given by the redis documentation.
+func TestDecoder_Dump(t *testing.T) {
+	s := newSnapshot("0009")
+	s.op(opSelectDB).length(0)
+	s.op(typeString).string("mykey").op(0xc0|encInt8, 10)
+	d := NewDecoder(bytes.NewReader(s.end()))
+
+	_, err := d.Next()
+	assert.NilError(t, err)
+	record, err := d.Next()
+	assert.NilError(t, err)
+	key := record.(Key)
+	assert.Equal(t, string(key.Dump), "\x00\xc0\n\t\x00\xbem\x06\x89Z(\x00\n")
+	assert.Equal(t, key.Restore().String(),
+		protocol.NewOutgoingCommand("RESTORE", "mykey", "0", string(key.Dump), "REPLACE").String())
+}
+
+// TestDecoder_Invalid tests that snapshots that can't be decoded fail.
+func TestDecoder_Invalid(t *testing.T) {
+	valid := newSnapshot("0011")
+	valid.op(typeString).string("a").string("b")
+	corrupt := valid.end()
+	corrupt[len(corrupt)-1]++
+
+	tests := []struct {
+		name     string
+		snapshot []byte
+	}{
+		{"not a snapshot", []byte("*1\r\n$4\r\nPING\r\n")},
+		{"newer version", newSnapshot("0013").end()},
+		{"older version", newSnapshot("0008").end()},
+		{"checksum", corrupt},
+		{"unknown type", newSnapshot("0012").op(100).string("a").end()},
+		{"cut short listpack", newSnapshot("0012").op(typeSetListpack).string("a").string(listpackOf("a")[:8]).end()},
+		{"lzf reference", newSnapshot("0012").op(typeString).string("a").op(0xc0|encLZF).length(2).length(3).
+			op(0x20, 0x05).end()},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := NewDecoder(bytes.NewReader(test.snapshot))
+			var err error
+			for err == nil {
+				_, err = d.Next()
+			}
+			assert.ErrorIs(t, err, ErrInvalid)
+		})
+	}
+
+	d := NewDecoder(bytes.NewReader(valid.Bytes()[:valid.Len()-3]))
+	_, err := d.Next()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+// TestKey_Commands tests the commands that recreate keys.
+func TestKey_Commands(t *testing.T) {
+	expire := time.UnixMilli(1700000000123)
+	seen := time.UnixMilli(1700000000000)
+	tests := []struct {
+		name string
+		key  Key
+		want []string
+	}{
+		{"string", Key{Key: []byte("k"), ExpireAt: expire, Value: String("v")}, []string{
+			"SET k v",
+			"PEXPIREAT k 1700000000123",
+		}},
+		{"list", Key{Key: []byte("k"), Value: List{[]byte("a"), []byte("b")}}, []string{"RPUSH k a b"}},
+		{"set", Key{Key: []byte("k"), Value: Set{[]byte("a")}}, []string{"SADD k a"}},
+		{"sorted set", Key{Key: []byte("k"), Value: SortedSet{
+			{Member: []byte("a"), Score: 1.5}, {Member: []byte("b"), Score: math.Inf(-1)},
+		}}, []string{"ZADD k 1.5 a -inf b"}},
+		{"hash", Key{Key: []byte("k"), Value: Hash{
+			{Field: []byte("f"), Value: []byte("v")},
+			{Field: []byte("g"), Value: []byte("w"), ExpireAt: expire},
+		}}, []string{
+			"HSET k f v g w",
+			"HPEXPIREAT k 1700000000123 FIELDS 1 g",
+		}},
+		{"stream", Key{Key: []byte("k"), Value: Stream{
+			Entries:      []StreamEntry{{ID: StreamID{1, 0}, Fields: [][]byte{[]byte("f"), []byte("v")}}},
+			LastID:       StreamID{3, 0},
+			MaxDeletedID: StreamID{3, 0},
+			EntriesAdded: 3,
+			Groups: []ConsumerGroup{{
+				Name:        []byte("g"),
+				LastID:      StreamID{1, 0},
+				EntriesRead: 1,
+				Pending:     []PendingEntry{{ID: StreamID{1, 0}, DeliveryTime: seen, DeliveryCount: 2}},
+				Consumers: []Consumer{
+					{Name: []byte("c"), Pending: []StreamID{{1, 0}}},
+					{Name: []byte("idle")},
+				},
+			}},
+		}}, []string{
+			"XADD k 1-0 f v",
+			"XSETID k 3-0 ENTRIESADDED 3 MAXDELETEDID 3-0",
+			"XGROUP CREATE k g 1-0 ENTRIESREAD 1",
+			"XCLAIM k g c 0 1-0 TIME 1700000000000 RETRYCOUNT 2 JUSTID FORCE",
+			"XGROUP CREATECONSUMER k g idle",
+		}},
+		{"empty stream", Key{Key: []byte("k"), Value: Stream{LastID: StreamID{5, 1}}}, []string{
+			"XADD k MAXLEN 0 5-1 x y",
+			"XSETID k 5-1 ENTRIESADDED 0 MAXDELETEDID 0-0",
+		}},
+		{"module", Key{Key: []byte("k"), Idle: -1, Freq: 3, Value: Module{}, Dump: []byte("dump")}, []string{
+			"RESTORE k 0 dump REPLACE FREQ 3",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got, want []string
+			for _, cmd := range test.key.Commands() {
+				got = append(got, cmd.String())
+			}
+			for _, cmd := range test.want {
+				want = append(want, protocol.NewOutgoingCommand(strings.Fields(cmd)...).String())
+			}
+			assert.DeepEqual(t, got, want)
+		})
+	}
+
+	// large values are added in chunks.
+	list := make(List, chunk+1)
+	for i := range list {
+		list[i] = []byte("x")
+	}
+	cmds := Key{Key: []byte("k"), Value: list}.Commands()
+	assert.Equal(t, len(cmds), 2)
+}
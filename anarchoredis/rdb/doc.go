@@ -0,0 +1,16 @@
+// Copyright 2025 Outreach Corporation. All Rights Reserved.
+
+// Description:
+
+// Package rdb decodes the snapshots that redis saves to disk, and sends to its replicas on a full resync, in the RDB
+// format of redis 5 to 7.4 (versions 9 to 12).
+//
+// A snapshot is a header, "REDIS" followed by the four digit version, and a sequence of opcodes, each introducing
+// either metadata, such as an auxiliary field, the database that the keys that follow are in, or the expiry of the
+// next key, or a key and its value, whose encoding is given by the opcode. It ends with the EOF opcode, followed by a
+// CRC64 of everything before it.
+//
+// Decoder reads the records of a snapshot one at a time, so that a snapshot larger than memory can be read, as long
+// as each of its keys fits. A Key can be turned into the commands that recreate it, or into a RESTORE of its value
+// as it was encoded in the snapshot.
+package rdb
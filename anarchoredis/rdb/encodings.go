@@ -0,0 +1,348 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
+// errShort is returned when an encoding is cut short.
+var errShort = fmt.Errorf("%w: encoding cut short", ErrInvalid)
+
+// cursor reads through an encoding, checking its bounds.
+type cursor struct {
+	b   []byte
+	pos int
+}
+
+func (c *cursor) take(n int) ([]byte, error) {
+	if n < 0 || len(c.b)-c.pos < n {
+		return nil, errShort
+	}
+	p := c.b[c.pos : c.pos+n]
+	c.pos += n
+	return p, nil
+}
+
+func (c *cursor) peek() (byte, error) {
+	if c.pos >= len(c.b) {
+		return 0, errShort
+	}
+	return c.b[c.pos], nil
+}
+
+// ziplist decodes the entries of a ziplist, which follow its length in bytes, the offset of its tail and the number
+// of entries, and are each made of the length of the previous entry, an encoding, and the data.
+func ziplist(b []byte) ([][]byte, error) {
+	c := &cursor{b: b}
+	if _, err := c.take(10); err != nil {
+		return nil, err
+	}
+	var entries [][]byte
+	for {
+		prev, err := c.peek()
+		if err != nil {
+			return nil, err
+		}
+		if prev == 0xff {
+			return entries, nil
+		}
+		// the length of the previous entry is one byte, or 0xfe followed by four.
+		size := 1
+		if prev == 0xfe {
+			size = 5
+		}
+		if _, err := c.take(size); err != nil {
+			return nil, err
+		}
+
+		header, err := c.take(1)
+		if err != nil {
+			return nil, err
+		}
+		enc := header[0]
+		var n int
+		switch enc >> 6 {
+		case 0:
+			n = int(enc & 0x3f)
+		case 1:
+			next, err := c.take(1)
+			if err != nil {
+				return nil, err
+			}
+			n = int(enc&0x3f)<<8 | int(next[0])
+		case 2:
+			l, err := c.take(4)
+			if err != nil {
+				return nil, err
+			}
+			n = int(binary.BigEndian.Uint32(l))
+		default:
+			v, err := ziplistInt(c, enc)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, strconv.AppendInt(nil, v, 10))
+			continue
+		}
+		s, err := c.take(n)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, s)
+	}
+}
+
+// ziplistInt decodes an integer of a ziplist with the encoding enc.
+func ziplistInt(c *cursor, enc byte) (int64, error) {
+	var size int
+	switch enc {
+	case 0xc0:
+		size = 2
+	case 0xd0:
+		size = 4
+	case 0xe0:
+		size = 8
+	case 0xf0:
+		size = 3
+	case 0xfe:
+		size = 1
+	default:
+		// 0xf1 to 0xfd are the integers 0 to 12.
+		if enc >= 0xf1 && enc <= 0xfd {
+			return int64(enc&0x0f) - 1, nil
+		}
+		return 0, fmt.Errorf("%w: ziplist encoding %#x", ErrInvalid, enc)
+	}
+	b, err := c.take(size)
+	if err != nil {
+		return 0, err
+	}
+	return littleEndianInt(b), nil
+}
+
+// littleEndianInt decodes a signed little endian integer of 1 to 8 bytes.
+func littleEndianInt(b []byte) int64 {
+	var u uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		u = u<<8 | uint64(b[i])
+	}
+	shift := 64 - 8*len(b)
+	return int64(u<<shift) >> shift
+}
+
+// listpack decodes the entries of a listpack, which follow its length in bytes and the number of entries, and are
+// each made of an encoding, the data, and the length of both, so that it can be read backwards.
+func listpack(b []byte) ([][]byte, error) {
+	c := &cursor{b: b}
+	if _, err := c.take(6); err != nil {
+		return nil, err
+	}
+	var entries [][]byte
+	for {
+		enc, err := c.peek()
+		if err != nil {
+			return nil, err
+		}
+		if enc == 0xff {
+			return entries, nil
+		}
+
+		start := c.pos
+		var entry []byte
+		switch {
+		case enc&0x80 == 0:
+			// a 7 bit unsigned integer.
+			c.pos++
+			entry = strconv.AppendInt(nil, int64(enc), 10)
+		case enc&0xc0 == 0x80:
+			// a string of up to 63 bytes.
+			c.pos++
+			entry, err = c.take(int(enc & 0x3f))
+		case enc&0xe0 == 0xc0:
+			// a 13 bit signed integer.
+			var b []byte
+			b, err = c.take(2)
+			if err == nil {
+				v := int64(b[0]&0x1f)<<8 | int64(b[1])
+				if v >= 1<<12 {
+					v -= 1 << 13
+				}
+				entry = strconv.AppendInt(nil, v, 10)
+			}
+		case enc&0xf0 == 0xe0:
+			// a string of up to 4095 bytes.
+			var b []byte
+			b, err = c.take(2)
+			if err == nil {
+				entry, err = c.take(int(b[0]&0x0f)<<8 | int(b[1]))
+			}
+		case enc == 0xf0:
+			// a string with a 32 bit length.
+			var b []byte
+			b, err = c.take(5)
+			if err == nil {
+				entry, err = c.take(int(binary.LittleEndian.Uint32(b[1:])))
+			}
+		case enc >= 0xf1 && enc <= 0xf4:
+			// a 16, 24, 32 or 64 bit signed integer.
+			size := [...]int{2, 3, 4, 8}[enc-0xf1]
+			var b []byte
+			b, err = c.take(1 + size)
+			if err == nil {
+				entry = strconv.AppendInt(nil, littleEndianInt(b[1:]), 10)
+			}
+		default:
+			err = fmt.Errorf("%w: listpack encoding %#x", ErrInvalid, enc)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := c.take(backlenSize(c.pos - start)); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// backlenSize is the number of bytes taken by the length of a listpack entry of n bytes, 7 bits of it per byte.
+func backlenSize(n int) int {
+	// the bounds are those of redis, which are one less than what fits.
+	switch {
+	case n <= 127:
+		return 1
+	case n < 16383:
+		return 2
+	case n < 2097151:
+		return 3
+	case n < 268435455:
+		return 4
+	}
+	return 5
+}
+
+// intset decodes the elements of an intset, which are little endian integers of 2, 4 or 8 bytes, given first,
+// following the number of elements.
+func intset(b []byte) ([][]byte, error) {
+	c := &cursor{b: b}
+	header, err := c.take(8)
+	if err != nil {
+		return nil, err
+	}
+	size := int(binary.LittleEndian.Uint32(header))
+	n := int(binary.LittleEndian.Uint32(header[4:]))
+	if size != 2 && size != 4 && size != 8 {
+		return nil, fmt.Errorf("%w: intset encoding %d", ErrInvalid, size)
+	}
+	if n > (len(b)-8)/size {
+		return nil, errShort
+	}
+	elements := make([][]byte, 0, n)
+	for range n {
+		e, _ := c.take(size)
+		elements = append(elements, strconv.AppendInt(nil, littleEndianInt(e), 10))
+	}
+	return elements, nil
+}
+
+// zipmap decodes a hash encoded as a zipmap, as saved by redis before 2.6: the number of fields, then each field and
+// value preceded by their lengths, and the value by the number of free bytes that follow it.
+func zipmap(b []byte) (Hash, error) {
+	c := &cursor{b: b}
+	if _, err := c.take(1); err != nil {
+		return nil, err
+	}
+	length := func() (int, error) {
+		l, err := c.take(1)
+		if err != nil {
+			return 0, err
+		}
+		if l[0] < 254 {
+			return int(l[0]), nil
+		}
+		l, err = c.take(4)
+		if err != nil {
+			return 0, err
+		}
+		return int(binary.LittleEndian.Uint32(l)), nil
+	}
+
+	var hash Hash
+	for {
+		end, err := c.peek()
+		if err != nil {
+			return nil, err
+		}
+		if end == 0xff {
+			return hash, nil
+		}
+		n, err := length()
+		if err != nil {
+			return nil, err
+		}
+		field, err := c.take(n)
+		if err != nil {
+			return nil, err
+		}
+		n, err = length()
+		if err != nil {
+			return nil, err
+		}
+		free, err := c.take(1)
+		if err != nil {
+			return nil, err
+		}
+		value, err := c.take(n)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := c.take(int(free[0])); err != nil {
+			return nil, err
+		}
+		hash = append(hash, Field{Field: field, Value: value})
+	}
+}
+
+// lzf decompresses b into n bytes. It is a sequence of literal runs, of up to 32 bytes, and back references to what
+// has been decompressed so far.
+func lzf(b []byte, n int) ([]byte, error) {
+	out := make([]byte, 0, n)
+	c := &cursor{b: b}
+	for c.pos < len(b) {
+		ctrl, _ := c.take(1)
+		if ctrl[0] < 32 {
+			literal, err := c.take(int(ctrl[0]) + 1)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, literal...)
+			continue
+		}
+
+		length := int(ctrl[0] >> 5)
+		if length == 7 {
+			more, err := c.take(1)
+			if err != nil {
+				return nil, err
+			}
+			length += int(more[0])
+		}
+		length += 2
+		low, err := c.take(1)
+		if err != nil {
+			return nil, err
+		}
+		ref := len(out) - (int(ctrl[0]&0x1f)<<8 | int(low[0])) - 1
+		if ref < 0 {
+			return nil, fmt.Errorf("%w: LZF reference out of range", ErrInvalid)
+		}
+		// the reference may overlap what it appends, so it is copied a byte at a time.
+		for i := range length {
+			out = append(out, out[ref+i])
+		}
+	}
+	if len(out) != n {
+		return nil, fmt.Errorf("%w: LZF decompressed %d bytes, want %d", ErrInvalid, len(out), n)
+	}
+	return out, nil
+}
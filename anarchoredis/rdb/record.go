@@ -0,0 +1,177 @@
+package rdb
+
+import (
+	"strconv"
+	"time"
+)
+
+// Record is what Decoder reads from a snapshot: one of Aux, SelectDB, ResizeDB, SlotInfo, Function, ModuleAux or Key.
+type Record interface {
+	record()
+}
+
+// Aux is an auxiliary field of the snapshot, such as the version of redis that saved it, or its replication ID and
+// offset.
+type Aux struct {
+	Key, Value string
+}
+
+// SelectDB says that the keys that follow are in the database DB.
+type SelectDB struct {
+	DB uint64
+}
+
+// ResizeDB hints at the number of keys in the current database, and of those with an expiry.
+type ResizeDB struct {
+	Size, ExpiresSize uint64
+}
+
+// SlotInfo hints at the number of keys in a cluster slot, and of those with an expiry.
+type SlotInfo struct {
+	Slot, Size, ExpiresSize uint64
+}
+
+// Function is the code of a library of functions, as loaded with FUNCTION LOAD.
+type Function struct {
+	Code string
+}
+
+// ModuleAux is data saved by a module outside any key, which only the module can decode.
+type ModuleAux struct {
+	Module Module
+	// When says whether the data is saved before or after the keys.
+	When uint64
+}
+
+// Key is a key and its value.
+type Key struct {
+	// DB is the database the key is in.
+	DB  uint64
+	Key []byte
+	// ExpireAt is when the key expires, or zero if it doesn't.
+	ExpireAt time.Time
+	// Idle is how long the key has been idle, and Freq its access frequency, when the leader evicts keys by LRU or
+	// LFU respectively, or -1.
+	Idle time.Duration
+	Freq int
+	// Value is one of String, List, Set, SortedSet, Hash, Stream or Module.
+	Value Value
+	// Dump is the value serialized as by DUMP, i.e. as it was encoded in the snapshot, followed by the version of the
+	// snapshot and a checksum.
+	Dump []byte
+}
+
+func (Aux) record()       {}
+func (SelectDB) record()  {}
+func (ResizeDB) record()  {}
+func (SlotInfo) record()  {}
+func (Function) record()  {}
+func (ModuleAux) record() {}
+func (Key) record()       {}
+
+// Value is the value of a Key, whichever way it was encoded.
+type Value interface {
+	value()
+}
+
+// String is a string value.
+type String []byte
+
+// List is a list value, from head to tail.
+type List [][]byte
+
+// Set is a set value.
+type Set [][]byte
+
+// SortedSet is a sorted set value.
+type SortedSet []Member
+
+// Member is a member of a sorted set.
+type Member struct {
+	Member []byte
+	Score  float64
+}
+
+// Hash is a hash value.
+type Hash []Field
+
+// Field is a field of a hash.
+type Field struct {
+	Field, Value []byte
+	// ExpireAt is when the field expires, or zero if it doesn't.
+	ExpireAt time.Time
+}
+
+// Stream is a stream value.
+type Stream struct {
+	// Entries are the entries of the stream that haven't been deleted.
+	Entries []StreamEntry
+	// Length is the number of entries.
+	Length uint64
+	// LastID is the ID of the last entry added, FirstID that of the first entry, and MaxDeletedID the largest ID
+	// deleted.
+	LastID, FirstID, MaxDeletedID StreamID
+	// EntriesAdded counts every entry ever added.
+	EntriesAdded uint64
+	Groups       []ConsumerGroup
+}
+
+// StreamID is the ID of a stream entry.
+type StreamID struct {
+	Ms, Seq uint64
+}
+
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// StreamEntry is an entry of a stream.
+type StreamEntry struct {
+	ID StreamID
+	// Fields are the fields of the entry, each followed by its value.
+	Fields [][]byte
+}
+
+// ConsumerGroup is a consumer group of a stream.
+type ConsumerGroup struct {
+	Name []byte
+	// LastID is the last entry delivered to the group.
+	LastID StreamID
+	// EntriesRead is the number of entries the group has read, or -1 if it isn't known.
+	EntriesRead int64
+	// Pending are the entries delivered to consumers of the group but not acknowledged.
+	Pending   []PendingEntry
+	Consumers []Consumer
+}
+
+// PendingEntry is an entry delivered to a consumer of a group but not acknowledged.
+type PendingEntry struct {
+	ID            StreamID
+	DeliveryTime  time.Time
+	DeliveryCount uint64
+}
+
+// Consumer is a consumer of a group.
+type Consumer struct {
+	Name []byte
+	// SeenTime is when the consumer last tried to read, and ActiveTime when it last read or claimed an entry.
+	SeenTime, ActiveTime time.Time
+	// Pending are the IDs of the pending entries of the group delivered to the consumer.
+	Pending []StreamID
+}
+
+// Module is a value of a type defined by a module, which only the module can decode, and only be restored from the
+// dump.
+type Module struct {
+	// Name is the name of the module type, and Version the version of its encoding.
+	Name    string
+	Version int
+}
+
+func (String) value()    {}
+func (List) value()      {}
+func (Set) value()       {}
+func (SortedSet) value() {}
+func (Hash) value()      {}
+func (Stream) value()    {}
+func (Module) value()    {}
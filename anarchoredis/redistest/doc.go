@@ -0,0 +1,20 @@
+// Copyright 2025 Outreach Corporation. All Rights Reserved.
+
+// Description:
+
+// Package redistest provides a fake redis server that replicas can follow, so that replication, and the Transactor
+// built on it, can be tested without running redis.
+//
+// A Leader speaks enough of the protocol of a redis leader for a replica: it answers the handshake, replies to PSYNC
+// with a full resync, sending a snapshot of the strings it holds, or continues from its backlog, propagates writes,
+// asks for acknowledgements with REPLCONF GETACK, and serves WAIT and WAITAOF from them. Clients can read and write
+// strings with GET, SET and DEL, and other writes are propagated without being executed, so the stream can be
+// scripted with Do and Propagate.
+//
+// Faults are injected by cutting the connection to a replica after a number of bytes, which leaves a frame, or the
+// snapshot, truncated, by disconnecting the replicas mid-stream, by restarting, which loses the replication history,
+// and by failing over to a new Leader, which continues it.
+//
+// A Sentinel tells clients the address of the leaders it monitors, as Redis Sentinel does, and announces failovers
+// to those subscribed to +switch-master.
+package redistest
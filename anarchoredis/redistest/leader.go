@@ -0,0 +1,728 @@
+package redistest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awinterman/anarchoredis/protocol"
+	"github.com/awinterman/anarchoredis/protocol/message"
+)
+
+// Leader is a fake redis server that replicas follow as they would a redis leader. Its options are set before it is
+// started.
+type Leader struct {
+	// Diskless streams snapshots to the replicas that can read them, ending them with a mark rather than giving their
+	// length first, as redis does with repl-diskless-sync.
+	Diskless bool
+	// BacklogSize is the most bytes of the stream kept for replicas to continue from, or unbounded if zero. A replica
+	// whose offset is no longer in the backlog has to sync in full.
+	BacklogSize int
+	// OnAck, if set, is called with the offsets that a replica acknowledges processing and fsyncing, as it does, which
+	// is zero if it doesn't say.
+	OnAck func(ack, fack int64)
+
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu            sync.Mutex
+	closed        bool
+	replicationID string
+	// replicationID2 is the ID of the history that the Leader took over from in a failover, which replicas can
+	// continue from up to offset2, or empty.
+	replicationID2 string
+	offset2        int64
+	// backlog is the stream from the offset after start.
+	start   int64
+	backlog []byte
+	// database is the database the stream is in, or -1 until a command has been propagated in one.
+	database int
+	data     map[int]map[string]string
+	conns    map[net.Conn]bool
+	replicas map[*replica]bool
+	// cut is how many bytes are sent to the next replica to sync before it is disconnected, or -1.
+	cut int
+	// acked is closed, and replaced, whenever a replica acknowledges an offset.
+	acked chan struct{}
+}
+
+// NewLeader starts a Leader with the default options, which is closed when the test ends.
+func NewLeader(t testing.TB) *Leader {
+	l := &Leader{}
+	l.Start(t)
+	return l
+}
+
+// Start listens on a port of the loopback interface, and serves the connections to it until the test ends.
+func (l *Leader) Start(t testing.TB) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.listener = listener
+	l.replicationID = newReplicationID()
+	l.database = -1
+	l.data = map[int]map[string]string{}
+	l.conns = map[net.Conn]bool{}
+	l.replicas = map[*replica]bool{}
+	l.cut = -1
+	l.acked = make(chan struct{})
+
+	l.wg.Add(1)
+	go l.accept()
+	t.Cleanup(l.Close)
+}
+
+// Addr is the address the Leader listens on.
+func (l *Leader) Addr() string {
+	return l.listener.Addr().String()
+}
+
+// Close stops listening, and closes every connection.
+func (l *Leader) Close() {
+	_ = l.listener.Close()
+	l.mu.Lock()
+	l.closed = true
+	for conn := range l.conns {
+		_ = conn.Close()
+	}
+	l.mu.Unlock()
+	l.wg.Wait()
+}
+
+// ReplicationID is the ID of the replication history of the Leader.
+func (l *Leader) ReplicationID() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.replicationID
+}
+
+// Offset is the replication offset of the Leader: the number of bytes propagated since its history began.
+func (l *Leader) Offset() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.offset()
+}
+
+func (l *Leader) offset() int64 {
+	return l.start + int64(len(l.backlog))
+}
+
+// Do executes a command in database as a client would, outside of a transaction, and returns the reply.
+func (l *Leader) Do(database int, args ...string) protocol.Message {
+	cmd, err := parse(encode(args...))
+	if err != nil {
+		return message.Error("ERR " + err.Error())
+	}
+	return l.execute(&client{database: database}, []*protocol.Command{cmd}, false)[0]
+}
+
+// Propagate appends a command to the stream without executing it, e.g. PING, in whichever database the stream is
+// in.
+func (l *Leader) Propagate(args ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.append(encode(args...))
+}
+
+// GetAck asks the replicas for their offsets, which is propagated like any other command.
+func (l *Leader) GetAck() {
+	l.Propagate("REPLCONF", "GETACK", "*")
+}
+
+// CutAfter disconnects each of the replicas once n more bytes have been sent to it, so that what is propagated next
+// can be cut short.
+func (l *Leader) CutAfter(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for r := range l.replicas {
+		r.cutAfter(n)
+	}
+}
+
+// CutSync disconnects the next replica to sync once n bytes of the reply to its PSYNC have been sent, so that the
+// reply, or the snapshot that follows it, can be cut short.
+func (l *Leader) CutSync(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cut = n
+}
+
+// Disconnect closes the connections to the replicas, which can continue from their offset if they reconnect.
+func (l *Leader) Disconnect() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for r := range l.replicas {
+		_ = r.conn.Close()
+	}
+}
+
+// Restart loses the data and the replication history, as redis does when it restarts without persistence, and
+// disconnects the replicas, which have to sync in full.
+func (l *Leader) Restart() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for r := range l.replicas {
+		_ = r.conn.Close()
+	}
+	l.replicationID = newReplicationID()
+	l.replicationID2 = ""
+	l.start = 0
+	l.backlog = nil
+	l.database = -1
+	l.data = map[int]map[string]string{}
+}
+
+// Failover closes l, and hands its data and its backlog to a new Leader with the same options, which is started, as
+// a failover does when it promotes a replica that is up to date. The new Leader starts a replication history of its
+// own, and keeps the ID of that of l as its second one, so that the replicas of l can continue with PSYNC from any
+// offset up to that of the failover, as psync2 allows.
+func (l *Leader) Failover(t testing.TB) *Leader {
+	next := &Leader{Diskless: l.Diskless, BacklogSize: l.BacklogSize, OnAck: l.OnAck}
+	next.Start(t)
+	l.Close()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	next.mu.Lock()
+	defer next.mu.Unlock()
+	next.replicationID2 = l.replicationID
+	next.offset2 = l.offset() + 1
+	next.start = l.start
+	next.backlog = bytes.Clone(l.backlog)
+	for database, keys := range l.data {
+		next.data[database] = maps.Clone(keys)
+	}
+	return next
+}
+
+// Wait waits for numreplicas replicas to acknowledge the current offset, as WAIT does, and returns how many have.
+func (l *Leader) Wait(numreplicas int, timeout time.Duration) int {
+	return l.wait(numreplicas, timeout, false)
+}
+
+// WaitAOF waits for numreplicas replicas to acknowledge that they have fsynced the current offset, as WAITAOF does
+// for replicas, and returns how many have.
+func (l *Leader) WaitAOF(numreplicas int, timeout time.Duration) int {
+	return l.wait(numreplicas, timeout, true)
+}
+
+// wait waits until numreplicas replicas have acknowledged the current offset, or fsynced it, asking them for their
+// offsets if too few have. It waits forever if timeout is zero. Unlike redis, which waits for the last write of the
+// client, it waits for everything propagated so far.
+func (l *Leader) wait(numreplicas int, timeout time.Duration, fsynced bool) int {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	l.mu.Lock()
+	offset := l.offset()
+	asked := false
+	for {
+		n := 0
+		for r := range l.replicas {
+			if r.ack >= offset && (!fsynced || r.fack >= offset) {
+				n++
+			}
+		}
+		if n >= numreplicas {
+			l.mu.Unlock()
+			return n
+		}
+		if !asked {
+			l.append(encode("REPLCONF", "GETACK", "*"))
+			asked = true
+		}
+		acked := l.acked
+		l.mu.Unlock()
+
+		select {
+		case <-acked:
+		case <-deadline:
+			return n
+		}
+		l.mu.Lock()
+	}
+}
+
+// client is the state of a connection to the Leader.
+type client struct {
+	database int
+	// queued holds the commands of a transaction from MULTI, or is nil outside of one.
+	queued []*protocol.Command
+	// eof and psync2 are the capabilities of a replica, which can read snapshots that end with a mark, and be told
+	// its replication ID when it continues.
+	eof, psync2 bool
+	// replica is set once the client has asked to be sent the stream.
+	replica *replica
+}
+
+func (l *Leader) accept() {
+	defer l.wg.Done()
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			return
+		}
+		l.mu.Lock()
+		if l.closed {
+			l.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		l.conns[conn] = true
+		l.wg.Add(1)
+		l.mu.Unlock()
+		go l.serve(conn)
+	}
+}
+
+// serve replies to the commands of a client until it disconnects. Once it has asked for the stream, it is only sent
+// the stream, and anything but the acknowledgement of its offset is ignored.
+func (l *Leader) serve(conn net.Conn) {
+	defer l.wg.Done()
+	c := &client{}
+	defer func() {
+		_ = conn.Close()
+		l.mu.Lock()
+		delete(l.conns, conn)
+		if c.replica != nil {
+			delete(l.replicas, c.replica)
+			close(c.replica.done)
+		}
+		l.mu.Unlock()
+	}()
+
+	p := protocol.NewConnection(conn)
+	for {
+		frame, err := p.ReadFrame()
+		if err != nil {
+			return
+		}
+		// the frame is only valid until the next read, and queued commands outlive it.
+		cmd, err := parse(bytes.Clone(frame.Raw))
+		var reply *protocol.Message
+		if err != nil {
+			reply = errorReply("ERR " + err.Error())
+		} else {
+			reply = l.do(conn, c, cmd)
+		}
+		if reply == nil {
+			continue
+		}
+		_, err = p.Write(*reply)
+		if err == nil {
+			err = p.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// do executes cmd for c, and returns its reply, or nil if it has none.
+func (l *Leader) do(conn net.Conn, c *client, cmd *protocol.Command) *protocol.Message {
+	reply := func(m protocol.Message) *protocol.Message {
+		return &m
+	}
+	if c.replica != nil && cmd.Name != "REPLCONF" {
+		return nil
+	}
+	switch cmd.Name {
+	case "PING":
+		return reply(message.SimpleString("PONG"))
+	case "REPLCONF":
+		return l.replconf(c, cmd)
+	case "PSYNC":
+		return l.psync(conn, c, cmd)
+	case "INFO":
+		return reply(message.BulkBytes([]byte(l.info())))
+	case "ROLE":
+		offset := message.Int(l.Offset())
+		return reply(message.Array(message.BulkBytes([]byte("master")), offset, message.Array()))
+	case "WAIT", "WAITAOF":
+		return reply(l.waitCommand(cmd))
+	case "MULTI":
+		if c.queued != nil {
+			return reply(message.Error("ERR MULTI calls can not be nested"))
+		}
+		c.queued = []*protocol.Command{}
+		return reply(message.SimpleString("OK"))
+	case "DISCARD":
+		if c.queued == nil {
+			return reply(message.Error("ERR DISCARD without MULTI"))
+		}
+		c.queued = nil
+		return reply(message.SimpleString("OK"))
+	case "EXEC":
+		if c.queued == nil {
+			return reply(message.Error("ERR EXEC without MULTI"))
+		}
+		replies := l.execute(c, c.queued, true)
+		c.queued = nil
+		return reply(message.Array(replies...))
+	}
+	if c.queued != nil {
+		c.queued = append(c.queued, cmd)
+		return reply(message.SimpleString("QUEUED"))
+	}
+	return reply(l.execute(c, []*protocol.Command{cmd}, false)[0])
+}
+
+// replconf records the capabilities of a replica, or, once it is sent the stream, the offset it acknowledges, which
+// isn't replied to.
+func (l *Leader) replconf(c *client, cmd *protocol.Command) *protocol.Message {
+	args := cmd.Args
+	if c.replica != nil {
+		if len(args) < 2 || !strings.EqualFold(string(args[0]), "ACK") {
+			return nil
+		}
+		ack, _ := strconv.ParseInt(string(args[1]), 10, 64)
+		var fack int64
+		fsynced := len(args) >= 4 && strings.EqualFold(string(args[2]), "FACK")
+		if fsynced {
+			fack, _ = strconv.ParseInt(string(args[3]), 10, 64)
+		}
+		l.mu.Lock()
+		c.replica.ack = ack
+		if fsynced {
+			c.replica.fack = fack
+		}
+		close(l.acked)
+		l.acked = make(chan struct{})
+		l.mu.Unlock()
+		if l.OnAck != nil {
+			l.OnAck(ack, fack)
+		}
+		return nil
+	}
+
+	for i := 0; i+1 < len(args); i += 2 {
+		if strings.EqualFold(string(args[i]), "capa") {
+			switch strings.ToLower(string(args[i+1])) {
+			case "eof":
+				c.eof = true
+			case "psync2":
+				c.psync2 = true
+			}
+		}
+	}
+	ok := message.SimpleString("OK")
+	return &ok
+}
+
+// psync replies to PSYNC by continuing from the offset the replica asks for, if it is in the backlog, and in the
+// current history or in the one taken over in a failover, or with a full resync, then sends the replica the stream.
+func (l *Leader) psync(conn net.Conn, c *client, cmd *protocol.Command) *protocol.Message {
+	if len(cmd.Args) != 2 {
+		return errorReply("ERR wrong number of arguments for 'psync' command")
+	}
+	// the offset is that of the first byte the replica is missing.
+	offset, err := strconv.ParseInt(string(cmd.Args[1]), 10, 64)
+	if err != nil {
+		return errorReply("ERR value is not an integer or out of range")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r := &replica{conn: conn, left: l.cut, wake: make(chan struct{}, 1), done: make(chan struct{})}
+	l.cut = -1
+
+	id := string(cmd.Args[0])
+	inHistory := id == l.replicationID || (l.replicationID2 != "" && id == l.replicationID2 && offset <= l.offset2)
+	if inHistory && offset > l.start && offset <= l.offset()+1 {
+		if c.psync2 {
+			r.send([]byte("+CONTINUE " + l.replicationID + "\r\n"))
+		} else {
+			r.send([]byte("+CONTINUE\r\n"))
+		}
+		r.send(l.backlog[offset-1-l.start:])
+	} else {
+		r.send([]byte(fmt.Sprintf("+FULLRESYNC %s %d\r\n", l.replicationID, l.offset())))
+		snapshot := l.snapshot()
+		if l.Diskless && c.eof {
+			mark := newReplicationID()
+			r.send([]byte("$EOF:" + mark + "\r\n"))
+			r.send(snapshot)
+			r.send([]byte(mark))
+		} else {
+			r.send([]byte("$" + strconv.Itoa(len(snapshot)) + "\r\n"))
+			r.send(snapshot)
+		}
+	}
+
+	c.replica = r
+	l.replicas[r] = true
+	l.wg.Add(1)
+	go l.write(r)
+	return nil
+}
+
+// info is the replication section of INFO.
+func (l *Leader) info() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	replicationID2, offset2 := l.replicationID2, l.offset2
+	if replicationID2 == "" {
+		replicationID2, offset2 = strings.Repeat("0", 40), -1
+	}
+	return fmt.Sprintf("# Replication\r\nrole:master\r\nconnected_slaves:%d\r\nmaster_replid:%s\r\n"+
+		"master_replid2:%s\r\nmaster_repl_offset:%d\r\nsecond_repl_offset:%d\r\n"+
+		"repl_backlog_first_byte_offset:%d\r\nrepl_backlog_histlen:%d\r\n",
+		len(l.replicas), l.replicationID, replicationID2, l.offset(), offset2, l.start+1, len(l.backlog))
+}
+
+// waitCommand replies to WAIT numreplicas timeout, and to WAITAOF numlocal numreplicas timeout, which can't wait
+// for a local append only file, since there is none.
+func (l *Leader) waitCommand(cmd *protocol.Command) protocol.Message {
+	args := cmd.Args
+	fsynced := cmd.Name == "WAITAOF"
+	if fsynced {
+		if len(args) != 3 {
+			return message.Error("ERR wrong number of arguments for 'waitaof' command")
+		}
+		if string(args[0]) != "0" {
+			return message.Error("ERR WAITAOF cannot be used when numlocal is set but appendonly is disabled.")
+		}
+		args = args[1:]
+	} else if len(args) != 2 {
+		return message.Error("ERR wrong number of arguments for 'wait' command")
+	}
+	numreplicas, err := strconv.Atoi(string(args[0]))
+	if err != nil {
+		return message.Error("ERR value is not an integer or out of range")
+	}
+	timeout, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil || timeout < 0 {
+		return message.Error("ERR timeout is negative")
+	}
+
+	n := int64(l.wait(numreplicas, time.Duration(timeout)*time.Millisecond, fsynced))
+	if fsynced {
+		return message.Array(message.Int(0), message.Int(n))
+	}
+	return message.Int(n)
+}
+
+// execute executes cmds for c, then propagates those that write, wrapped in MULTI and EXEC if they are a
+// transaction, as redis does.
+func (l *Leader) execute(c *client, cmds []*protocol.Command, transaction bool) []protocol.Message {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	type write struct {
+		database int
+		cmd      []byte
+	}
+	var replies []protocol.Message
+	var writes []write
+	for _, cmd := range cmds {
+		reply, propagate := l.apply(c, cmd)
+		replies = append(replies, reply)
+		if propagate {
+			writes = append(writes, write{c.database, encodeMessage(cmd.Message)})
+		}
+	}
+
+	if len(writes) == 0 {
+		return replies
+	}
+	if transaction {
+		l.propagate(writes[0].database, encode("MULTI"))
+	}
+	for _, w := range writes {
+		l.propagate(w.database, w.cmd)
+	}
+	if transaction {
+		l.propagate(writes[len(writes)-1].database, encode("EXEC"))
+	}
+	return replies
+}
+
+// apply executes cmd for c, and says whether it wrote, and is propagated. Writes other than SET and DEL are
+// propagated without being executed, and SET ignores its options.
+func (l *Leader) apply(c *client, cmd *protocol.Command) (protocol.Message, bool) {
+	args := cmd.Args
+	keys := l.data[c.database]
+	wrongArgs := message.Error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd.Name)))
+
+	switch cmd.Name {
+	case "SELECT":
+		if len(args) != 1 {
+			return wrongArgs, false
+		}
+		database, err := strconv.Atoi(string(args[0]))
+		if err != nil || database < 0 {
+			return message.Error("ERR DB index is out of range"), false
+		}
+		c.database = database
+		return message.SimpleString("OK"), false
+	case "GET":
+		if len(args) != 1 {
+			return wrongArgs, false
+		}
+		if v, ok := keys[string(args[0])]; ok {
+			return message.BulkBytes([]byte(v)), false
+		}
+		return message.NullBulkString(), false
+	case "SET":
+		if len(args) < 2 {
+			return wrongArgs, false
+		}
+		if keys == nil {
+			keys = map[string]string{}
+			l.data[c.database] = keys
+		}
+		keys[string(args[0])] = string(args[1])
+		return message.SimpleString("OK"), true
+	case "DEL":
+		if len(args) == 0 {
+			return wrongArgs, false
+		}
+		var n int64
+		for _, key := range args {
+			if _, ok := keys[string(key)]; ok {
+				delete(keys, string(key))
+				n++
+			}
+		}
+		// deleting nothing writes nothing.
+		return message.Int(n), n > 0
+	}
+	if cmd.IsWrite() {
+		return message.SimpleString("OK"), true
+	}
+	return message.Error(fmt.Sprintf("ERR unknown command '%s'", cmd.Name)), false
+}
+
+// propagate appends cmd to the stream in database, following a SELECT if the stream is in another.
+func (l *Leader) propagate(database int, cmd []byte) {
+	if database != l.database {
+		l.append(encode("SELECT", strconv.Itoa(database)))
+		l.database = database
+	}
+	l.append(cmd)
+}
+
+// append appends p to the stream, and sends it to the replicas.
+func (l *Leader) append(p []byte) {
+	l.backlog = append(l.backlog, p...)
+	if l.BacklogSize > 0 && len(l.backlog) > l.BacklogSize {
+		trimmed := len(l.backlog) - l.BacklogSize
+		l.backlog = bytes.Clone(l.backlog[trimmed:])
+		l.start += int64(trimmed)
+	}
+	for r := range l.replicas {
+		r.send(p)
+	}
+}
+
+// replica is a connection the stream is sent to.
+type replica struct {
+	conn net.Conn
+	// ack and fack are the offsets the replica last acknowledged processing, and fsyncing, guarded by Leader.mu.
+	ack, fack int64
+
+	mu sync.Mutex
+	// pending is what is yet to be sent, and left is how much more is sent before the connection is cut, or -1.
+	pending []byte
+	left    int
+	// wake is signalled when there is something to send, and done is closed once the connection is.
+	wake chan struct{}
+	done chan struct{}
+}
+
+// send queues p to be sent, so that a replica that is slow to read doesn't hold up the Leader.
+func (r *replica) send(p []byte) {
+	r.mu.Lock()
+	r.pending = append(r.pending, p...)
+	r.mu.Unlock()
+	r.signal()
+}
+
+// cutAfter cuts the connection once n bytes have been sent after what is already pending.
+func (r *replica) cutAfter(n int) {
+	r.mu.Lock()
+	r.left = len(r.pending) + n
+	r.mu.Unlock()
+	r.signal()
+}
+
+func (r *replica) signal() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// write sends what is queued to the replica until it is disconnected.
+func (l *Leader) write(r *replica) {
+	defer l.wg.Done()
+	for {
+		select {
+		case <-r.wake:
+		case <-r.done:
+			return
+		}
+		r.mu.Lock()
+		p := r.pending
+		r.pending = nil
+		cut := r.left >= 0 && len(p) >= r.left
+		if cut {
+			p = p[:r.left]
+		} else if r.left >= 0 {
+			r.left -= len(p)
+		}
+		r.mu.Unlock()
+
+		_, err := r.conn.Write(p)
+		if err != nil || cut {
+			_ = r.conn.Close()
+			return
+		}
+	}
+}
+
+func errorReply(s string) *protocol.Message {
+	reply := message.Error(s)
+	return &reply
+}
+
+// encode encodes a command as it is sent in the stream.
+func encode(args ...string) []byte {
+	return encodeMessage(*protocol.NewOutgoingCommand(args...))
+}
+
+func encodeMessage(msg protocol.Message) []byte {
+	var b bytes.Buffer
+	_, _ = (&message.Encoder{}).Encode(msg, &b)
+	return b.Bytes()
+}
+
+// parse parses a command from raw, which it keeps pointing into.
+func parse(raw []byte) (*protocol.Command, error) {
+	msg, err := (&message.Encoder{}).Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	return protocol.Cmd(msg)
+}
+
+// newReplicationID returns a random replication ID, which is 40 hexadecimal characters, like the mark that ends a
+// diskless snapshot.
+func newReplicationID() string {
+	b := make([]byte, 20)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,244 @@
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awinterman/anarchoredis/protocol"
+	"github.com/awinterman/anarchoredis/protocol/message"
+	"github.com/awinterman/anarchoredis/txn/rdb"
+	"gotest.tools/v3/assert"
+)
+
+// replicate connects to the leader as a replica, and asks it to sync from replicationID and offset, returning the
+// connection and the first line of the reply.
+func replicate(t *testing.T, l *Leader, replicationID, offset string) (net.Conn, *bufio.Reader, string) {
+	t.Helper()
+	conn, err := net.Dial("tcp", l.Addr())
+	assert.NilError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	r := bufio.NewReader(conn)
+
+	for _, cmd := range [][]string{{"PING"}, {"REPLCONF", "capa", "eof", "capa", "psync2"}} {
+		_, err = conn.Write(encode(cmd...))
+		assert.NilError(t, err)
+		_, err = r.ReadString('\n')
+		assert.NilError(t, err)
+	}
+	_, err = conn.Write(encode("PSYNC", replicationID, offset))
+	assert.NilError(t, err)
+	line, err := r.ReadString('\n')
+	assert.NilError(t, err)
+	return conn, r, strings.TrimSuffix(line, "\r\n")
+}
+
+// readStream reads n bytes of the stream.
+func readStream(t *testing.T, r *bufio.Reader, n int) string {
+	t.Helper()
+	b := make([]byte, n)
+	_, err := io.ReadFull(r, b)
+	assert.NilError(t, err)
+	return string(b)
+}
+
+// TestLeader_Sync tests a full resync: the snapshot holds what was written before it, and the writes after it are
+// propagated in the database they were made in, transactions wrapped in MULTI and EXEC.
+func TestLeader_Sync(t *testing.T) {
+	for _, diskless := range []bool{false, true} {
+		t.Run(fmt.Sprint("diskless ", diskless), func(t *testing.T) {
+			l := &Leader{Diskless: diskless}
+			l.Start(t)
+			assert.Equal(t, l.Do(0, "SET", "a", "1").String(), message.SimpleString("OK").String())
+			assert.Equal(t, l.Do(1, "SET", "b", "2").String(), message.SimpleString("OK").String())
+			offset := l.Offset()
+			assert.Equal(t, offset, int64(len(encode("SELECT", "0"))+len(encode("SET", "a", "1"))+
+				len(encode("SELECT", "1"))+len(encode("SET", "b", "2"))))
+
+			_, r, reply := replicate(t, l, "?", "-1")
+			assert.Equal(t, reply, fmt.Sprintf("+FULLRESYNC %s %d", l.ReplicationID(), offset))
+			header, err := r.ReadString('\n')
+			assert.NilError(t, err)
+			if diskless {
+				assert.Assert(t, strings.HasPrefix(header, "$EOF:"))
+			} else {
+				assert.Equal(t, header, fmt.Sprintf("$%d\r\n", len(l.snapshot())))
+			}
+			snapshot := readStream(t, r, len(l.snapshot()))
+			if diskless {
+				mark := strings.TrimSuffix(strings.TrimPrefix(header, "$EOF:"), "\r\n")
+				assert.Equal(t, readStream(t, r, len(mark)), mark)
+			}
+
+			decoder := rdb.NewDecoder(strings.NewReader(snapshot))
+			var keys []string
+			for record, err := range decoder.Records() {
+				assert.NilError(t, err)
+				if key, ok := record.(rdb.Key); ok {
+					keys = append(keys, fmt.Sprintf("%d %s %s", key.DB, key.Key, key.Value))
+				}
+			}
+			assert.DeepEqual(t, keys, []string{"0 a 1", "1 b 2"})
+		})
+	}
+
+	l := NewLeader(t)
+	_, r, _ := replicate(t, l, "?", "-1")
+	_, err := r.ReadString('\n')
+	assert.NilError(t, err)
+	readStream(t, r, len(l.snapshot()))
+
+	client, err := net.Dial("tcp", l.Addr())
+	assert.NilError(t, err)
+	defer client.Close()
+	p := protocol.NewConnection(client)
+	var replies []string
+	for _, cmd := range [][]string{
+		{"SELECT", "2"}, {"SET", "c", "3"}, {"GET", "c"}, {"DEL", "missing"},
+		{"MULTI"}, {"SET", "d", "4"}, {"GET", "d"}, {"EXEC"},
+	} {
+		_, err := client.Write(encode(cmd...))
+		assert.NilError(t, err)
+		frame, err := p.ReadFrame()
+		assert.NilError(t, err)
+		replies = append(replies, frame.Message.String())
+	}
+	ok, queued := message.SimpleString("OK").String(), message.SimpleString("QUEUED").String()
+	assert.DeepEqual(t, replies, []string{ok, ok, message.BulkBytes([]byte("3")).String(), message.Int(0).String(),
+		ok, queued, queued, message.Array(message.SimpleString("OK"), message.BulkBytes([]byte("4"))).String()})
+
+	stream := string(encode("SELECT", "2")) + string(encode("SET", "c", "3")) + string(encode("MULTI")) +
+		string(encode("SET", "d", "4")) + string(encode("EXEC"))
+	assert.Equal(t, readStream(t, r, len(stream)), stream)
+	assert.Equal(t, l.Offset(), int64(len(stream)))
+}
+
+// TestLeader_Continue tests that a replica continues from its offset while it is in the backlog, and otherwise
+// syncs in full.
+func TestLeader_Continue(t *testing.T) {
+	l := &Leader{BacklogSize: 100}
+	l.Start(t)
+	set := encode("SET", "a", "1")
+	l.Do(0, "SET", "a", "1")
+	l.Disconnect()
+	offset := l.Offset()
+
+	_, r, reply := replicate(t, l, l.ReplicationID(), "1")
+	assert.Equal(t, reply, "+CONTINUE "+l.ReplicationID())
+	assert.Equal(t, readStream(t, r, int(offset)), string(encode("SELECT", "0"))+string(set))
+
+	// replicas that are up to date continue with nothing.
+	_, _, reply = replicate(t, l, l.ReplicationID(), fmt.Sprint(offset+1))
+	assert.Equal(t, reply, "+CONTINUE "+l.ReplicationID())
+
+	for range 10 {
+		l.Do(0, "SET", "a", "1")
+	}
+	_, _, reply = replicate(t, l, l.ReplicationID(), fmt.Sprint(offset+1))
+	assert.Equal(t, reply, fmt.Sprintf("+FULLRESYNC %s %d", l.ReplicationID(), offset+10*int64(len(set))),
+		"the offset is no longer in the backlog")
+
+	id := l.ReplicationID()
+	l.Restart()
+	assert.Assert(t, l.ReplicationID() != id)
+	_, _, reply = replicate(t, l, id, fmt.Sprint(offset+1))
+	assert.Equal(t, reply, fmt.Sprintf("+FULLRESYNC %s 0", l.ReplicationID()))
+}
+
+// TestLeader_Wait tests that WAIT and WAITAOF ask the replicas for their offsets, and count those that have
+// acknowledged, or fsynced, everything propagated, and that the acknowledgements are passed to OnAck.
+func TestLeader_Wait(t *testing.T) {
+	acks := make(chan [2]int64, 10)
+	l := &Leader{OnAck: func(ack, fack int64) {
+		acks <- [2]int64{ack, fack}
+	}}
+	l.Start(t)
+	conn, r, _ := replicate(t, l, "?", "-1")
+	_, err := r.ReadString('\n')
+	assert.NilError(t, err)
+	readStream(t, r, len(l.snapshot()))
+	l.Do(0, "SET", "a", "1")
+	offset := l.Offset()
+
+	waited := make(chan int)
+	go func() {
+		waited <- l.Wait(1, 0)
+	}()
+	getack := encode("REPLCONF", "GETACK", "*")
+	stream := string(encode("SELECT", "0")) + string(encode("SET", "a", "1")) + string(getack)
+	assert.Equal(t, readStream(t, r, len(stream)), stream)
+	// the GETACK itself needn't be acknowledged.
+	_, err = conn.Write(encode("REPLCONF", "ACK", fmt.Sprint(offset), "FACK", fmt.Sprint(offset-1)))
+	assert.NilError(t, err)
+	assert.Equal(t, <-waited, 1)
+	assert.Equal(t, <-acks, [2]int64{offset, offset - 1})
+
+	// the replica hasn't fsynced the offset, nor the GETACK since.
+	assert.Equal(t, l.WaitAOF(1, 10*time.Millisecond), 0)
+	assert.Equal(t, l.Wait(0, 0), 0)
+
+	client, err := net.Dial("tcp", l.Addr())
+	assert.NilError(t, err)
+	defer client.Close()
+	reply, err := protocol.NewConnection(client).RoundTrip(*protocol.NewOutgoingCommand("WAIT", "1", "10"))
+	assert.NilError(t, err)
+	assert.Equal(t, reply.Int, int64(0))
+}
+
+// TestLeader_Cut tests that the connection to a replica can be cut short in the reply to PSYNC, and in the stream.
+func TestLeader_Cut(t *testing.T) {
+	l := NewLeader(t)
+	l.Do(0, "SET", "a", "1")
+	full := fmt.Sprintf("+FULLRESYNC %s %d\r\n", l.ReplicationID(), l.Offset())
+	header := fmt.Sprintf("$%d\r\n", len(l.snapshot()))
+	l.CutSync(len(full) + len(header) + 3)
+	_, r, _ := replicate(t, l, "?", "-1")
+	line, err := r.ReadString('\n')
+	assert.NilError(t, err)
+	assert.Equal(t, line, header)
+	read, err := io.ReadAll(r)
+	assert.NilError(t, err)
+	assert.Equal(t, string(read), string(l.snapshot()[:3]))
+
+	_, r, reply := replicate(t, l, "?", "-1")
+	assert.Equal(t, reply+"\r\n", full, "only the next sync is cut")
+	_, err = r.ReadString('\n')
+	assert.NilError(t, err)
+	readStream(t, r, len(l.snapshot()))
+
+	l.CutAfter(3)
+	l.Do(0, "SET", "b", "2")
+	read, err = io.ReadAll(r)
+	assert.NilError(t, err)
+	assert.Equal(t, string(read), string(encode("SET", "b", "2"))[:3])
+}
+
+// TestLeader_Failover tests that the replicas of a leader can continue from their offset with the leader that takes
+// over from it in a failover, up to the offset of the failover, as psync2 allows.
+func TestLeader_Failover(t *testing.T) {
+	old := NewLeader(t)
+	old.Do(0, "SET", "a", "1")
+	offset := old.Offset()
+
+	l := old.Failover(t)
+	assert.Assert(t, l.ReplicationID() != old.ReplicationID())
+	assert.Equal(t, l.Offset(), offset)
+	assert.Equal(t, l.Do(0, "GET", "a").String(), message.BulkBytes([]byte("1")).String())
+	_, err := net.Dial("tcp", old.Addr())
+	assert.Assert(t, err != nil, "the old leader is closed")
+
+	_, r, reply := replicate(t, l, old.ReplicationID(), "1")
+	assert.Equal(t, reply, "+CONTINUE "+l.ReplicationID())
+	assert.Equal(t, readStream(t, r, int(offset)), string(encode("SELECT", "0"))+string(encode("SET", "a", "1")))
+
+	l.Do(0, "SET", "b", "2")
+	_, _, reply = replicate(t, l, old.ReplicationID(), fmt.Sprint(offset+1))
+	assert.Equal(t, reply, "+CONTINUE "+l.ReplicationID(), "a replica can continue from the offset of the failover")
+	_, _, reply = replicate(t, l, old.ReplicationID(), fmt.Sprint(offset+2))
+	assert.Equal(t, reply, fmt.Sprintf("+FULLRESYNC %s %d", l.ReplicationID(), l.Offset()),
+		"the old history ends at the failover")
+}
@@ -0,0 +1,179 @@
+package redistest
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/awinterman/anarchoredis/protocol"
+	"github.com/awinterman/anarchoredis/protocol/message"
+)
+
+// switchMaster is the channel a Sentinel announces failovers on.
+const switchMaster = "+switch-master"
+
+// Sentinel is a fake Redis Sentinel, which tells clients the address of the leaders it monitors, and announces their
+// failovers to those subscribed to +switch-master.
+type Sentinel struct {
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	closed  bool
+	conns   map[net.Conn]bool
+	masters map[string]string
+	// subscribers are the connections subscribed to +switch-master. Every reply is written under mu, so that the
+	// messages announcing a failover aren't interleaved with them.
+	subscribers map[net.Conn]bool
+}
+
+// NewSentinel starts a Sentinel on a port of the loopback interface, which is closed when the test ends.
+func NewSentinel(t testing.TB) *Sentinel {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Sentinel{
+		listener:    listener,
+		conns:       map[net.Conn]bool{},
+		masters:     map[string]string{},
+		subscribers: map[net.Conn]bool{},
+	}
+	s.wg.Add(1)
+	go s.accept()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Addr is the address the Sentinel listens on.
+func (s *Sentinel) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops listening, and closes every connection.
+func (s *Sentinel) Close() {
+	_ = s.listener.Close()
+	s.mu.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Subscribers is the number of connections subscribed to +switch-master.
+func (s *Sentinel) Subscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subscribers)
+}
+
+// Monitor sets the address of the leader monitored as name, without announcing it.
+func (s *Sentinel) Monitor(name, addr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.masters[name] = addr
+}
+
+// Switch sets the address of the leader monitored as name, and announces the failover to it on +switch-master, as
+// sentinels do once they have promoted a replica.
+func (s *Sentinel) Switch(name, addr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	oldHost, oldPort, _ := net.SplitHostPort(s.masters[name])
+	newHost, newPort, _ := net.SplitHostPort(addr)
+	s.masters[name] = addr
+
+	event := strings.Join([]string{name, oldHost, oldPort, newHost, newPort}, " ")
+	msg := encode("message", switchMaster, event)
+	for conn := range s.subscribers {
+		_, _ = conn.Write(msg)
+	}
+}
+
+func (s *Sentinel) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.conns[conn] = true
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.serve(conn)
+	}
+}
+
+// serve replies to the commands of a client until it disconnects.
+func (s *Sentinel) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		_ = conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		delete(s.subscribers, conn)
+		s.mu.Unlock()
+	}()
+
+	p := protocol.NewConnection(conn)
+	for {
+		frame, err := p.ReadFrame()
+		if err != nil {
+			return
+		}
+		cmd, err := parse(bytes.Clone(frame.Raw))
+		var reply protocol.Message
+		if err != nil {
+			reply = message.Error("ERR " + err.Error())
+		} else {
+			reply = s.do(conn, cmd)
+		}
+
+		s.mu.Lock()
+		_, err = conn.Write(encodeMessage(reply))
+		s.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// do executes cmd for the client on conn, and returns its reply. Once subscribed, a client is sent the messages of
+// its channels, and may go on sending commands, unlike with redis.
+func (s *Sentinel) do(conn net.Conn, cmd *protocol.Command) protocol.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	args := cmd.Args
+	switch cmd.Name {
+	case "PING":
+		return message.SimpleString("PONG")
+	case "SENTINEL":
+		if len(args) != 2 || !strings.EqualFold(string(args[0]), "get-master-addr-by-name") {
+			return message.Error("ERR only SENTINEL get-master-addr-by-name is supported")
+		}
+		addr, ok := s.masters[string(args[1])]
+		if !ok {
+			return message.NullArray()
+		}
+		host, port, _ := net.SplitHostPort(addr)
+		return message.Array(message.BulkBytes([]byte(host)), message.BulkBytes([]byte(port)))
+	case "SUBSCRIBE":
+		if len(args) != 1 || string(args[0]) != switchMaster {
+			return message.Error("ERR only " + switchMaster + " can be subscribed to")
+		}
+		s.subscribers[conn] = true
+		return message.Array(message.BulkBytes([]byte("subscribe")), message.BulkBytes([]byte(switchMaster)),
+			message.Int(1))
+	}
+	return message.Error("ERR unknown command '" + cmd.Name + "'")
+}
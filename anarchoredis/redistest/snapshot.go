@@ -0,0 +1,51 @@
+package redistest
+
+import (
+	"encoding/binary"
+	"hash/crc64"
+	"maps"
+	"slices"
+)
+
+// crcTable is the table of the CRC64 that redis checksums snapshots with.
+var crcTable = crc64.MakeTable(0x95ac9329ac4bc9b5)
+
+// snapshot encodes the data as a snapshot of redis 7.2, with the keys of each database in order.
+func (l *Leader) snapshot() []byte {
+	b := []byte("REDIS0011")
+	for _, database := range slices.Sorted(maps.Keys(l.data)) {
+		keys := l.data[database]
+		if len(keys) == 0 {
+			continue
+		}
+		b = append(b, 0xfe)
+		b = appendLength(b, database)
+		b = append(b, 0xfb)
+		b = appendLength(b, len(keys))
+		b = appendLength(b, 0)
+		for _, key := range slices.Sorted(maps.Keys(keys)) {
+			// a string.
+			b = append(b, 0)
+			b = appendString(b, key)
+			b = appendString(b, keys[key])
+		}
+	}
+	b = append(b, 0xff)
+	// unlike that of hash/crc64, the checksum of redis isn't inverted before and after.
+	return binary.LittleEndian.AppendUint64(b, ^crc64.Update(^uint64(0), crcTable, b))
+}
+
+// appendLength appends the length encoding of n, in 6, 14 or 32 bits.
+func appendLength(b []byte, n int) []byte {
+	switch {
+	case n < 1<<6:
+		return append(b, byte(n))
+	case n < 1<<14:
+		return append(b, 0x40|byte(n>>8), byte(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, 0x80), uint32(n))
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(appendLength(b, len(s)), s...)
+}
@@ -0,0 +1,188 @@
+package anarchoredis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/awinterman/anarchoredis/protocol"
+)
+
+// ErrReplicating is returned by Replicate if it is already running.
+var ErrReplicating = errors.New("replication is already running")
+
+// Replicate follows the replication stream of the upstream server and commits it to the transaction log until ctx is
+// done, resuming the stream whenever it fails. Committing a write releases the keys it locked, which wakes the client
+// connections waiting on them, and advances the committed offset, which is what the upstream server is told has been
+// processed, so that WAIT waits until writes have been committed.
+//
+// The Transactor has one replication stream however many client connections it proxies, so writes are only
+// acknowledged while Replicate runs. Only one call may run at a time, but it can be called again once it returns.
+func (t *Transactor) Replicate(ctx context.Context) error {
+	if !t.replicating.CompareAndSwap(false, true) {
+		return ErrReplicating
+	}
+	defer t.replicating.Store(false)
+
+	a := &applier{Transactor: t, database: "0"}
+	subscriber := NewSubscriber(t.conf)
+	t.subscriber.Store(subscriber)
+	defer t.subscriber.Store(nil)
+	subscriber.OnSync = func(full bool, database string) {
+		a.restart(database)
+		if full {
+			// the stream starts over, and nothing of it is committed until its snapshot is.
+			t.committed.reset(0)
+		} else {
+			// the stream continues from the last command committed.
+			t.committed.advance(subscriber.Offset.Load())
+		}
+	}
+	subscriber.OnSnapshot = func(snapshot io.Reader) error {
+		offset := subscriber.Offset.Load()
+		err := t.appendSnapshot(ctx, snapshot, *subscriber.ReplicationID.Load(), offset)
+		if err != nil {
+			return err
+		}
+		t.committed.advance(offset)
+		return nil
+	}
+	// connections to the old leader are closed once they are done with, since it may be gone, or demoted to a replica,
+	// which refuses writes.
+	subscriber.OnFailover = func(addr string) {
+		t.upstreams.reset()
+		t.offsets.reset()
+	}
+	// the leader is told the offset committed, so that WAIT on it waits for the transaction log.
+	subscriber.Durable = t.committed.load
+	// the keepalives of the leader count towards the offset it reports, so the committed offset follows them.
+	subscriber.OnKeepalive = func(offset int64) {
+		if a.queued == nil {
+			t.committed.advance(offset)
+		}
+	}
+	return subscriber.Follow(ctx, func(msg *protocol.Message, offset int64) error {
+		err := a.apply(ctx, msg)
+		if err != nil {
+			return err
+		}
+		if a.queued == nil {
+			t.committed.advance(offset)
+		}
+		return nil
+	})
+}
+
+// snapshotChunk is the most bytes of a snapshot appended to the transaction log in one record.
+const snapshotChunk = 512 << 10
+
+// appendSnapshot appends the snapshot that the upstream server sends on a full resync to the transaction log, so
+// that whoever replays the log can load it, then apply the commands that follow it. Everything written before the
+// resync is only found in the snapshot.
+//
+// The snapshot is appended in chunks, between records that mark its beginning and end, which are tagged with the
+// replication ID and offset the stream continues from. They are CONTROL commands, which no server executes, appended
+// with no database:
+//
+//	CONTROL SNAPSHOT <replication ID> <offset> BEGIN
+//	CONTROL SNAPSHOT <replication ID> <offset> CHUNK <n> <bytes>
+//	CONTROL SNAPSHOT <replication ID> <offset> END <chunks>
+//
+// A snapshot cut short by a failed stream has no END, and is followed by the stream from where it was before.
+func (t *Transactor) appendSnapshot(ctx context.Context, snapshot io.Reader, replicationID string, offset int64) error {
+	record := func(args ...string) error {
+		tag := []string{"CONTROL", "SNAPSHOT", replicationID, strconv.FormatInt(offset, 10)}
+		return t.txnlog.Append(ctx, protocol.NewOutgoingCommand(append(tag, args...)...), "")
+	}
+
+	err := record("BEGIN")
+	if err != nil {
+		return err
+	}
+	chunk := make([]byte, snapshotChunk)
+	n := 0
+	for {
+		// a snapshot cut short fails rather than ending, so it is read until EOF.
+		read := 0
+		for read < len(chunk) && err == nil {
+			var m int
+			m, err = snapshot.Read(chunk[read:])
+			read += m
+		}
+		if read > 0 {
+			err := record("CHUNK", strconv.Itoa(n), string(chunk[:read]))
+			if err != nil {
+				return err
+			}
+			n++
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	return record("END", strconv.Itoa(n))
+}
+
+// watermark is the replication offset up to which the stream has been committed to the transaction log, which can
+// be waited on.
+type watermark struct {
+	mu     sync.Mutex
+	offset int64
+	// advanced is closed, and replaced, whenever offset grows.
+	advanced chan struct{}
+}
+
+// load returns the offset.
+func (w *watermark) load() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.offset
+}
+
+// advance raises the offset to offset, and wakes whoever waits on it. It never lowers the offset.
+func (w *watermark) advance(offset int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if offset <= w.offset {
+		return
+	}
+	w.offset = offset
+	if w.advanced != nil {
+		close(w.advanced)
+		w.advanced = nil
+	}
+}
+
+// reset sets the offset to offset, even if it is lower, when the stream starts over.
+func (w *watermark) reset(offset int64) {
+	w.mu.Lock()
+	w.offset = min(w.offset, offset)
+	w.mu.Unlock()
+	w.advance(offset)
+}
+
+// wait waits until the offset has reached offset.
+func (w *watermark) wait(ctx context.Context, offset int64) error {
+	for {
+		w.mu.Lock()
+		if w.offset >= offset {
+			w.mu.Unlock()
+			return nil
+		}
+		if w.advanced == nil {
+			w.advanced = make(chan struct{})
+		}
+		advanced := w.advanced
+		w.mu.Unlock()
+
+		select {
+		case <-advanced:
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package anarchoredis
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/awinterman/anarchoredis/protocol"
+	"github.com/awinterman/anarchoredis/protocol/message"
+	"github.com/awinterman/anarchoredis/txn/redistest"
+	"gotest.tools/v3/assert"
+)
+
+// TestReplicate_Once tests that only one replication stream runs at a time, and that it can be started again once
+// it has stopped.
+func TestReplicate_Once(t *testing.T) {
+	// nothing listens on the address, so the stream keeps failing and restarting.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	addr := l.Addr().String()
+	assert.NilError(t, l.Close())
+	transactor := &Transactor{conf: &Conf{RedisAddress: addr, ListenAddress: addr}}
+
+	for range 2 {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- transactor.Replicate(ctx)
+		}()
+		assert.Assert(t, waitFor(func() bool { return transactor.replicating.Load() }))
+		assert.ErrorIs(t, transactor.Replicate(ctx), ErrReplicating)
+
+		cancel()
+		assert.ErrorIs(t, <-done, context.Canceled)
+	}
+}
+
+// TestWatermark tests waiting on the committed offset.
+func TestWatermark(t *testing.T) {
+	var w watermark
+	ctx := context.Background()
+
+	waited := make(chan error)
+	go func() {
+		waited <- w.wait(ctx, 10)
+	}()
+	w.advance(5)
+	select {
+	case err := <-waited:
+		t.Fatal("returned before the offset was reached", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	w.advance(12)
+	assert.NilError(t, <-waited)
+
+	w.advance(3)
+	assert.Equal(t, w.load(), int64(12), "the offset never goes back")
+	assert.NilError(t, w.wait(ctx, 12))
+
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, w.wait(timeout, 13), context.DeadlineExceeded)
+
+	w.reset(0)
+	assert.Equal(t, w.load(), int64(0), "a new stream starts over")
+	w.advance(4)
+	assert.Equal(t, w.load(), int64(4))
+}
+
+// waitFor polls cond for up to a second.
+func waitFor(cond func() bool) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+	return false
+}
+
+// TestReplicate_AppendSnapshot tests that a snapshot is appended to the transaction log in chunks, and is left
+// without an end if it is cut short.
+func TestReplicate_AppendSnapshot(t *testing.T) {
+	txnlog := &appendLog{}
+	transactor := &Transactor{txnlog: txnlog}
+	ctx := context.Background()
+
+	snapshot := strings.Repeat("x", snapshotChunk+10)
+	assert.NilError(t, transactor.appendSnapshot(ctx, strings.NewReader(snapshot), "abc", 100))
+	record := func(args ...string) string {
+		return " " + protocol.NewOutgoingCommand(append([]string{"CONTROL", "SNAPSHOT", "abc", "100"}, args...)...).String()
+	}
+	assert.DeepEqual(t, txnlog.entries, []string{
+		record("BEGIN"),
+		record("CHUNK", "0", snapshot[:snapshotChunk]),
+		record("CHUNK", "1", snapshot[snapshotChunk:]),
+		record("END", "2"),
+	})
+
+	txnlog.entries = nil
+	cut := io.MultiReader(strings.NewReader("REDIS"), iotest.ErrReader(io.ErrUnexpectedEOF))
+	assert.ErrorIs(t, transactor.appendSnapshot(ctx, cut, "abc", 100), io.ErrUnexpectedEOF)
+	assert.DeepEqual(t, txnlog.entries, []string{record("BEGIN"), record("CHUNK", "0", "REDIS")})
+}
+
+// TestReplicate_Leader proxies writes to a fake leader, which are acknowledged once they have been committed from its
+// replication stream, through a frame cut short and a restart of the leader, and tells the leader which offset has
+// been committed.
+func TestReplicate_Leader(t *testing.T) {
+	for _, mode := range []AckMode{AckKeys, AckOffset} {
+		t.Run(string(mode), func(t *testing.T) {
+			leader := redistest.NewLeader(t)
+			leader.Do(0, "SET", "a", "1")
+
+			txnlog := &appendLog{}
+			conf := &Conf{RedisAddress: leader.Addr(), ListenAddress: "127.0.0.1:0", LockStore: LockMemory,
+				AckMode: mode, LeaderPollInterval: 10 * time.Millisecond}
+			ctx, cancel := context.WithCancel(context.Background())
+			transactor, err := NewTransactor(ctx, conf, txnlog)
+			assert.NilError(t, err)
+			done := make(chan error)
+			go func() {
+				done <- transactor.Replicate(ctx)
+			}()
+			defer func() {
+				cancel()
+				assert.ErrorIs(t, <-done, context.Canceled)
+			}()
+
+			client := startProxy(t, transactor)
+			r := protocol.NewConnection(client)
+			set := func(key, value string) {
+				t.Helper()
+				replies, err := pipeline(client, r, [][]byte{encode("SET", key, value)})
+				assert.NilError(t, err)
+				assert.DeepEqual(t, replies, []string{message.SimpleString("OK").String()})
+			}
+			command := func(database string, args ...string) string {
+				return database + " " + protocol.NewOutgoingCommand(args...).String()
+			}
+			// snapshotted waits until n snapshots have been appended, and returns the entries that follow the last.
+			snapshotted := func(n int) []string {
+				t.Helper()
+				var entries []string
+				assert.Assert(t, waitFor(func() bool {
+					entries = txnlog.appended()
+					ends := 0
+					for i, entry := range entries {
+						if strings.HasPrefix(entry, " ") && strings.Contains(entry, "END") {
+							ends++
+							if ends == n {
+								entries = entries[i+1:]
+								return true
+							}
+						}
+					}
+					return false
+				}))
+				return entries
+			}
+
+			assert.Equal(t, len(snapshotted(1)), 0)
+			set("b", "2")
+			assert.DeepEqual(t, snapshotted(1), []string{command("0", "SET", "b", "2")})
+			assert.Equal(t, leader.Wait(1, 5*time.Second), 1)
+			assert.Equal(t, leader.WaitAOF(1, 5*time.Second), 1)
+
+			leader.CutAfter(5)
+			set("c", "3")
+			assert.DeepEqual(t, snapshotted(1), []string{command("0", "SET", "b", "2"), command("0", "SET", "c", "3")})
+
+			// the stream starts over, from a snapshot without the keys written so far.
+			leader.Restart()
+			assert.Equal(t, len(snapshotted(2)), 0)
+			set("d", "4")
+			assert.DeepEqual(t, snapshotted(2), []string{command("0", "SELECT", "0"), command("0", "SET", "d", "4")})
+			assert.Equal(t, leader.Wait(1, 5*time.Second), 1)
+			assert.Assert(t, waitFor(func() bool { return transactor.committed.load() == leader.Offset() }))
+
+			// the transaction log has caught up with the leader, and the writes waited on their locks.
+			assert.Assert(t, waitFor(func() bool {
+				st, ok := transactor.ReplicationStats()
+				return ok && st.LeaderOffset == leader.Offset() && st.Lag() == 0
+			}))
+			locks := transactor.LockStats()
+			if mode == AckKeys {
+				assert.Equal(t, locks.Acquire.Count, int64(3))
+				assert.Equal(t, locks.Commit.Count, int64(3))
+				assert.Equal(t, locks.Commit.Waiting, int64(0))
+			} else {
+				assert.Equal(t, locks.Acquire, WaitStats{}, "nothing is locked when acknowledging by offset")
+				assert.Equal(t, locks.Commit.Count, int64(3))
+				assert.Equal(t, locks.Commit.Waiting, int64(0))
+			}
+		})
+	}
+}
+
+// TestReplicate_Failover proxies writes to a fake leader found through a sentinel, and goes on once the sentinel has
+// failed it over to a new leader, which continues the replication stream of the old one.
+func TestReplicate_Failover(t *testing.T) {
+	leader := redistest.NewLeader(t)
+	sentinel := redistest.NewSentinel(t)
+	sentinel.Monitor("mymaster", leader.Addr())
+
+	txnlog := &appendLog{}
+	conf := &Conf{SentinelAddresses: []string{sentinel.Addr()}, SentinelMaster: "mymaster",
+		ListenAddress: "127.0.0.1:0", LockStore: LockMemory}
+	ctx, cancel := context.WithCancel(context.Background())
+	transactor, err := NewTransactor(ctx, conf, txnlog)
+	assert.NilError(t, err)
+	done := make(chan error)
+	go func() {
+		done <- transactor.Replicate(ctx)
+	}()
+	defer func() {
+		cancel()
+		assert.ErrorIs(t, <-done, context.Canceled)
+	}()
+
+	client := startProxy(t, transactor)
+	r := protocol.NewConnection(client)
+	set := func(key, value string) {
+		t.Helper()
+		replies, err := pipeline(client, r, [][]byte{encode("SET", key, value)})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, replies, []string{message.SimpleString("OK").String()})
+	}
+	command := func(args ...string) string {
+		return "0 " + protocol.NewOutgoingCommand(args...).String()
+	}
+	// written waits until the entries that follow the snapshot are want, and checks that only one was appended.
+	written := func(want ...string) {
+		t.Helper()
+		var entries []string
+		assert.Assert(t, waitFor(func() bool {
+			entries = txnlog.appended()
+			for i, entry := range entries {
+				if strings.HasPrefix(entry, " ") && strings.Contains(entry, "END") {
+					entries = entries[i+1:]
+					return len(entries) == len(want)
+				}
+			}
+			return false
+		}))
+		assert.Equal(t, strings.Join(entries, "\n"), strings.Join(want, "\n"))
+	}
+
+	written()
+	set("a", "1")
+	assert.Assert(t, waitFor(func() bool { return sentinel.Subscribers() == 1 }))
+	next := leader.Failover(t)
+	sentinel.Switch("mymaster", next.Addr())
+	// the pooled connections to the old leader are dropped once the failover is announced.
+	assert.Assert(t, waitFor(func() bool {
+		transactor.upstreams.mu.Lock()
+		defer transactor.upstreams.mu.Unlock()
+		return transactor.upstreams.generation == 1
+	}))
+
+	// the stream continues from the new leader, without another snapshot.
+	set("b", "2")
+	assert.Equal(t, next.Do(0, "GET", "b").String(), message.BulkBytes([]byte("2")).String())
+	written(command("SELECT", "0"), command("SET", "a", "1"), command("SELECT", "0"), command("SET", "b", "2"))
+	assert.Assert(t, waitFor(func() bool { return transactor.committed.load() == next.Offset() }))
+}
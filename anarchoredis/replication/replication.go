@@ -5,11 +5,15 @@
 package replication
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log/slog"
 	"net"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -17,6 +21,8 @@ import (
 	"time"
 
 	"github.com/awinterman/anarchoredis/protocol"
+	"github.com/awinterman/anarchoredis/protocol/message"
+	"github.com/awinterman/anarchoredis/txn/rdb"
 )
 
 type Subscriber struct {
@@ -24,20 +30,169 @@ type Subscriber struct {
 	LeaderAddr, MyAddr string
 	Logger             *slog.Logger
 
+	// Offset is the replication offset just past the last message read from the stream.
 	Offset        atomic.Int64
 	ReplicationID atomic.Pointer[string]
 
+	// StatePath is the file that the position in the stream is saved to, so that a restarted stream resumes from it
+	// with PSYNC rather than a full resync, even in another process. The position is only kept in memory if it is
+	// empty.
+	StatePath string
+	// OnSync, if set, is called whenever a stream starts, with whether the leader sent a full resync, in which case
+	// the commands start afresh, or continues from just past the last command processed outside of a transaction,
+	// and with the database selected at that point of the stream.
+	OnSync func(full bool, database string)
+	// OnSnapshot, if set, is called with the snapshot that the leader sends on a full resync, after OnSync, and
+	// before the commands that follow it. The snapshot is read from the connection to the leader as it is called,
+	// and what it leaves unread is skipped.
+	OnSnapshot func(snapshot io.Reader) error
+	// OnKeepalive, if set, is called with the offset just past each PING and REPLCONF that the leader sends to keep
+	// the stream alive, which aren't passed to msgFunc. They are never part of a transaction, so once the commands
+	// before them have been processed, so have they.
+	OnKeepalive func(offset int64)
+	// Durable, if set, returns the offset up to which the commands passed to msgFunc have been durably committed,
+	// which is reported to the leader in place of the offset processed, both as processed and as fsynced, so that
+	// WAIT and WAITAOF on the leader wait until writes have been committed.
+	Durable func() int64
+	// Sentinel, if set, is asked for the address of the leader whenever a stream starts, in place of LeaderAddr, and
+	// Follow restarts the stream whenever the sentinels announce a failover. The replica promoted by a failover keeps
+	// the replication ID of the old leader as its second one, so the stream continues from where it was.
+	Sentinel *Sentinel
+	// OnFailover, if set, is called by Follow with the address of the new leader whenever the sentinels announce a
+	// failover, before the stream restarts.
+	OnFailover func(addr string)
+	// PollInterval, if set, is how often the leader is asked for its replication offset while the stream runs, and
+	// the rates at which the stream is read are sampled, see Stats.
+	PollInterval time.Duration
+
+	// resume is the position a restarted stream resumes from, and loaded says whether it has been read from
+	// StatePath. syncs counts the streams started.
+	resume position
+	loaded bool
+	syncs  int
+	// checkpointed is the position last recorded by checkpoint, and saved the one last written to StatePath, which
+	// savePosition brings up to date. saving guards them, and the file.
+	saving              sync.Mutex
+	checkpointed, saved position
+
+	stats stats
 	signal
 }
 
+// position is a position in the replication stream of a leader, and the database selected there.
+type position struct {
+	ReplicationID string
+	Offset        int64
+	Database      string
+}
+
+const (
+	// retryMin and retryMax bound the delay before Follow restarts a failed stream.
+	retryMin = 100 * time.Millisecond
+	retryMax = 30 * time.Second
+)
+
+// loadPosition reads the position saved at path, which is zero if none has been.
+func loadPosition(path string) (position, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return position{}, nil
+	} else if err != nil {
+		return position{}, err
+	}
+	var pos position
+	_, err = fmt.Sscan(string(b), &pos.ReplicationID, &pos.Offset, &pos.Database)
+	if err != nil {
+		return position{}, fmt.Errorf("reading replication position from %q: %w", path, err)
+	}
+	return pos, nil
+}
+
+// save writes the position to path, replacing what was there at once so that a crash doesn't leave half of it. The
+// file, and then the directory it is renamed in, are synced, so that once save returns a crash can't lose it either.
+func (pos position) save(path string) error {
+	dir := filepath.Dir(path)
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(f, pos.ReplicationID, pos.Offset, pos.Database)
+	if err == nil {
+		err = f.Sync()
+	}
+	closeErr := f.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	err = os.Rename(tmp, path)
+	if err != nil {
+		return err
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	closeErr = d.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// current returns the position just past the last message read from the stream, where database is selected.
+func (s *Subscriber) current(database string) position {
+	return position{ReplicationID: *s.ReplicationID.Load(), Offset: s.Offset.Load(), Database: database}
+}
+
+// checkpoint records the position just past the last command processed outside of a transaction, to be saved to
+// StatePath by savePosition.
+func (s *Subscriber) checkpoint(pos position) {
+	s.resume = pos
+	if s.StatePath == "" {
+		return
+	}
+	s.saving.Lock()
+	s.checkpointed = pos
+	s.saving.Unlock()
+}
+
+// savePosition saves the position last recorded by checkpoint to StatePath, unless it has been already. The stream
+// saves it before each time it acknowledges its offset to the leader, rather than after every command, so a restarted
+// stream may process again the commands since, which may not be idempotent, e.g. INCR.
+func (s *Subscriber) savePosition() {
+	if s.StatePath == "" {
+		return
+	}
+	s.saving.Lock()
+	defer s.saving.Unlock()
+	if s.checkpointed == s.saved {
+		return
+	}
+	err := s.checkpointed.save(s.StatePath)
+	if err != nil {
+		s.Logger.Error("could not save the replication position", "path", s.StatePath, "error", err)
+		return
+	}
+	s.saved = s.checkpointed
+}
+
 // signal is a struct used to manage a one-time signaling mechanism with thread-safety.
 // It offers a single broadcast operation and provides synchronization using a channel.
-// Contains a channel, synchronization primitives, and an atomic flag for signaling status.
+// Contains a channel and synchronization primitives.
 type signal struct {
-	ch        chan struct{}
-	once      sync.Once
-	didSignal atomic.Bool
-	mu        sync.Mutex
+	ch   chan struct{}
+	once sync.Once
+	mu   sync.Mutex
 }
 
 // Broadcast signals all goroutines waiting on the signal by closing the channel, ensuring it happens only once.
@@ -49,7 +204,6 @@ func (s *signal) Broadcast() {
 	}
 	s.once.Do(func() {
 		close(s.ch)
-		s.didSignal.Store(true)
 	})
 }
 
@@ -63,38 +217,178 @@ func (s *Subscriber) ReplicationStartedCh() <-chan struct{} {
 	return s.ch
 }
 
-// pointer returns a pointer to the given value of type T.
-func pointer[T any](t T) *T {
-	return &t
+// counter counts the bytes read through it, so that the replication offset can be kept exactly while messages are
+// decoded lazily.
+type counter struct {
+	net.Conn
+	n int64
+}
+
+func (c *counter) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// errFailover ends a stream when the sentinels announce a failover.
+var errFailover = errors.New("the leader failed over")
+
+// Follow streams updates like StreamUpdates until ctx is done, restarting the stream whenever it fails. The delay
+// before a restart doubles with every failure in a row, up to a limit, and is reset once a stream has started. If
+// Sentinel is set, the stream is also restarted, at once, whenever the sentinels announce a failover, since the old
+// leader may never close it.
+func (s *Subscriber) Follow(
+	ctx context.Context,
+	msgFunc func(cmd *protocol.Message, offset int64) error,
+) error {
+	// stop ends the current stream, guarded by mu.
+	var mu sync.Mutex
+	var stop context.CancelCauseFunc
+	if s.Sentinel != nil {
+		watchCtx, cancel := context.WithCancel(ctx)
+		watched := make(chan struct{})
+		defer func() {
+			cancel()
+			<-watched
+		}()
+		go func() {
+			defer close(watched)
+			_ = s.Sentinel.Watch(watchCtx, func(addr string) {
+				if s.OnFailover != nil {
+					s.OnFailover(addr)
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				if stop != nil {
+					stop(errFailover)
+				}
+			})
+		}()
+	}
+
+	delay := retryMin
+	for {
+		syncs := s.syncs
+		streamCtx, cancel := context.WithCancelCause(ctx)
+		mu.Lock()
+		stop = cancel
+		mu.Unlock()
+		err := s.StreamUpdates(streamCtx, msgFunc)
+		cancel(nil)
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		if errors.Is(context.Cause(streamCtx), errFailover) {
+			s.Logger.Info("leader failed over; restarting replication stream")
+			delay = retryMin
+			continue
+		}
+		if s.syncs != syncs {
+			delay = retryMin
+		}
+		s.Logger.Error("replication stream failed; restarting", "error", err, "after", delay)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+		delay = min(2*delay, retryMax)
+	}
 }
 
-// StreamUpdates subscribes to a replication stream. It blocks
+// StreamUpdates subscribes to a replication stream, and calls msgFunc with each command of it along with the
+// replication offset just past the command, which Offset is advanced to once msgFunc returns. The PING and REPLCONF
+// that the leader sends to keep the stream alive count towards the offset, but are answered rather than passed to
+// msgFunc. It blocks until ctx is done or the stream fails.
+//
+// The stream resumes from just past the last command processed outside of a transaction by an earlier call, or
+// from the position saved at StatePath, and starts with a full resync only if there is none, or if the leader
+// can't continue from it.
 func (s *Subscriber) StreamUpdates(
 	ctx context.Context,
-	msgFunc func(cmd *protocol.Message) error,
+	msgFunc func(cmd *protocol.Message, offset int64) error,
 ) error {
-	s.ReplicationID.CompareAndSwap(nil, pointer(""))
-	if s.signal.didSignal.Load() {
-		return fmt.Errorf("attempting to reuse a subscriber, which is not allowed")
+	if !s.loaded {
+		if s.StatePath != "" {
+			pos, err := loadPosition(s.StatePath)
+			if err != nil {
+				return err
+			}
+			s.resume = pos
+		}
+		s.loaded = true
 	}
 
-	p, err2 := s.startReplication(ctx, *s.ReplicationID.Load(), s.Offset.Load(), false)
-	if err2 != nil {
-		return err2
+	if s.resume.Database == "" {
+		s.resume.Database = "0"
+	}
+	// "?" and -1 ask for a full resync, and otherwise the leader continues from the byte after the offset.
+	replicationID, offset := "?", int64(-1)
+	if s.resume.ReplicationID != "" {
+		replicationID, offset = s.resume.ReplicationID, s.resume.Offset+1
 	}
-	// when we exit; try to send the last stored replication offset.
-	defer s.replconfAck(p, s.Offset.Load())
+	p, c, reply, err := s.startReplication(ctx, replicationID, offset, false)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	// a read blocks until the leader sends something, so it is interrupted by closing the connection.
+	stop := context.AfterFunc(ctx, func() { _ = c.Close() })
+	defer stop()
+	full, err := s.synced(reply)
+	if err != nil {
+		return err
+	}
+	if full {
+		err = s.snapshot(p)
+		if err != nil {
+			return err
+		}
+		s.checkpoint(s.current("0"))
+		s.savePosition()
+	}
+	s.stats.received.Store(s.Offset.Load())
+	// the acks sent, and the polls of the leader, below stop with the stream.
+	ctx, cancel := context.WithCancel(ctx)
+	polled := make(chan struct{})
+	defer func() {
+		cancel()
+		<-polled
+	}()
+	go func() {
+		defer close(polled)
+		if s.PollInterval > 0 {
+			s.poll(ctx, c.RemoteAddr().String())
+		}
+	}()
 
-	// a goroutine that regularly sends the offset to the server.
+	// consumed is the number of bytes of the stream decoded, and mark is where the last message ended.
+	consumed := func() int64 {
+		return c.n - int64(p.RW.Reader.Buffered())
+	}
+	mark := consumed()
+	// when we exit; save the position, and try to send the last processed replication offset.
+	defer func() { _ = s.replconfAck(p, s.Offset.Load()) }()
+	defer s.savePosition()
+	// multi says whether the stream is in a transaction, which a restarted stream can't resume from, and database
+	// is the database selected.
+	multi := false
+	database := s.resume.Database
+
+	// a goroutine that regularly sends the offset to the server, which counts as a heartbeat even if it hasn't moved.
+	// The position is saved before the offset is acknowledged, which it is at or past.
 	go func() {
 		for {
 			select {
 			case <-ctx.Done():
 				return
 			case <-time.After(time.Millisecond * 900):
-				err := s.replconfAck(p, s.Offset.Load())
+				offset := s.Offset.Load()
+				s.savePosition()
+				err := s.replconfAck(p, offset)
 				if err != nil {
-					slog.Error("replconfAck", "err", err)
+					s.Logger.Error("replconfAck", "err", err)
 				}
 			}
 		}
@@ -106,97 +400,200 @@ func (s *Subscriber) StreamUpdates(
 		if err != nil {
 			return fmt.Errorf("%w reading message", err)
 		}
-		slog.Debug("replication", "msg", read)
+		s.stats.lastFrame.Store(time.Now().UnixNano())
 
 		switch {
 		case read.Kind == protocol.SimpleString:
-			split := strings.Split(read.SimpleString, " ")
-			switch split[0] {
-			case "FULLRESYNC":
-				// from redis docs:
-				// /* Send a FULLRESYNC reply in the specific case of a full resynchronization,
-				// * as a side effect setup the slave for a full sync in different ways:
-				// *
-				// * 1) Remember, into the slave client structure, the replication offset
-				// *    we sent here, so that if new slaves will later attach to the same
-				// *    background RDB saving process (by duplicating this client output
-				// *    buffer), we can get the right offset from this slave.
-				// * 2) Set the replication state of the slave to WAIT_BGSAVE_END so that
-				// *    we start accumulating differences from this point.
-				// * 3) Force the replication stream to re-emit a SELECT statement so
-				// *    the new slave incremental differences will start selecting the
-				// *    right database number.
-				// *
-				// * Normally this function should be called immediately after a successful
-				// * BGSAVE for replication was started, or when there is one already in
-				// * progress that we attached our slave to. */
-
-				// store the provided replication id
-				s.ReplicationID.Store(&split[1])
-				// storing the offset
-				offset, err := strconv.ParseInt(split[2], 10, 64)
-				if err != nil {
-					return err
+			slog.Info("replication metadata", "msg", read)
+		case read.Kind == protocol.Array:
+			// read the whole command, so that the offset past it is known.
+			read, err = message.Materialize(read, -1)
+			if err != nil {
+				return err
+			}
+			// next is the offset past the command, which it is counted towards once it has been processed.
+			next := s.Offset.Load() + consumed() - mark
+			s.stats.received.Store(next)
+			// the message is only logged once it has been read, since logging it would consume it.
+			slog.Debug("replication", "msg", read)
+
+			cmd, err := protocol.Cmd(read)
+			if err != nil {
+				return err
+			}
+			switch {
+			case cmd.Name == "PING":
+			case cmd.Name == "REPLCONF":
+				// GETACK asks for the offset processed, or committed, before it, which the leader doesn't reply to.
+				if len(cmd.Args) > 0 && strings.EqualFold(string(cmd.Args[0]), "GETACK") {
+					s.savePosition()
+					err = s.replconfAck(p, s.Offset.Load())
+				} else {
+					s.Logger.Info("received REPLCONF", "msg", cmd)
 				}
-				s.Offset.Store(offset)
-				slog.Info(read.String())
-				s.signal.Broadcast()
 			default:
-				slog.Info("replication metadata", "msg", read)
+				err = msgFunc(&cmd.Message, next)
+				s.stats.commands.Add(1)
 			}
-		case read.Kind == protocol.Array:
+			if err != nil {
+				return err
+			}
+
 			switch {
-			case read.Array[0].Str != "PING":
-				err := msgFunc(read)
-				if err != nil {
-					return err
-				}
-			case read.Array[0].Str != "REPLCONF":
-				s.Logger.Info("received REPLCONF", "msg", read)
+			case cmd.Name == "MULTI":
+				multi = true
+			case cmd.Name == "EXEC":
+				multi = false
+			case cmd.Name == "SELECT" && len(cmd.Args) > 0:
+				database = string(cmd.Args[0])
+			}
+			// the position is recorded before the offset is advanced, and so before it is acknowledged to the leader.
+			if !multi {
+				s.checkpoint(position{ReplicationID: *s.ReplicationID.Load(), Offset: next, Database: database})
 			}
-		case read.Indicator == protocol.Error:
+			s.Offset.Store(next)
+			if (cmd.Name == "PING" || cmd.Name == "REPLCONF") && s.OnKeepalive != nil {
+				s.OnKeepalive(next)
+			}
+		case read.Kind == protocol.Error:
 			return fmt.Errorf("%s", read)
-		case read.Indicator == protocol.BulkString && strings.HasPrefix(read.Str, "REDIS0011"):
-			s.Logger.Info("StreamUpdates received RDB snapshot; skipping")
 		}
 
-		s.Offset.Add(read.Size)
+		s.stats.bytes.Add(consumed() - mark)
+		mark = consumed()
 	}
 
 	return nil
 }
 
-func (s *Subscriber) Snapshot(ctx context.Context) (*bufio.Reader, error) {
-	replication, err := s.startReplication(ctx, "?", 0, true)
-	if err != nil {
-		return nil, err
+// synced handles the reply of the leader to PSYNC: either a full resync from a new position, or a continuation from
+// the position asked for, with a new replication ID if the leader's history has changed since, e.g. after a failover.
+func (s *Subscriber) synced(reply *protocol.Message) (full bool, err error) {
+	if reply.Kind != protocol.SimpleString {
+		return false, fmt.Errorf("unexpected reply to PSYNC: %s", reply)
 	}
-	for ctx.Err() == nil {
-		read, err := replication.Read()
+	split := strings.Fields(reply.SimpleString)
+	switch {
+	case len(split) == 3 && split[0] == "FULLRESYNC":
+		// from redis docs:
+		// /* Send a FULLRESYNC reply in the specific case of a full resynchronization,
+		// * as a side effect setup the slave for a full sync in different ways:
+		// *
+		// * 1) Remember, into the slave client structure, the replication offset
+		// *    we sent here, so that if new slaves will later attach to the same
+		// *    background RDB saving process (by duplicating this client output
+		// *    buffer), we can get the right offset from this slave.
+		// * 2) Set the replication state of the slave to WAIT_BGSAVE_END so that
+		// *    we start accumulating differences from this point.
+		// * 3) Force the replication stream to re-emit a SELECT statement so
+		// *    the new slave incremental differences will start selecting the
+		// *    right database number.
+		// *
+		// * Normally this function should be called immediately after a successful
+		// * BGSAVE for replication was started, or when there is one already in
+		// * progress that we attached our slave to. */
+		offset, err := strconv.ParseInt(split[2], 10, 64)
 		if err != nil {
-			return nil, err
+			return false, err
 		}
-		if read.Indicator == protocol.BulkString {
+		full = true
+		s.ReplicationID.Store(&split[1])
+		s.Offset.Store(offset)
+	case len(split) > 0 && len(split) <= 2 && split[0] == "CONTINUE":
+		replicationID := s.resume.ReplicationID
+		if len(split) == 2 {
+			replicationID = split[1]
 		}
+		s.ReplicationID.Store(&replicationID)
+		s.Offset.Store(s.resume.Offset)
+	default:
+		return false, fmt.Errorf("unexpected reply to PSYNC: %s", reply)
+	}
+
+	slog.Info(reply.String())
+	s.syncs++
+	database := s.resume.Database
+	if full {
+		// the position is saved once the snapshot has been read, so that a stream that fails before then resumes from
+		// where it was.
+		database = "0"
+	} else {
+		s.checkpoint(s.current(database))
+	}
+	if s.OnSync != nil {
+		s.OnSync(full, database)
 	}
+	s.signal.Broadcast()
+	return full, nil
 }
 
-func (s *Subscriber) startReplication(ctx context.Context, replicationID string, offset int64,
-	snapshotOnly bool) (*protocol.Conn,
-	error) {
-	conn, err := s.Dialer.DialContext(ctx, "tcp", s.LeaderAddr)
+// snapshot reads the snapshot that follows a full resync, passing it to OnSnapshot if set. It doesn't count towards
+// the offset.
+func (s *Subscriber) snapshot(p *protocol.Conn) error {
+	snapshot, err := readSnapshot(p.RW.Reader)
+	if err != nil {
+		return err
+	}
+	if s.OnSnapshot != nil {
+		err = s.OnSnapshot(snapshot)
+		if err != nil {
+			return err
+		}
+	} else {
+		s.Logger.Info("received snapshot; skipping")
+	}
+	_, err = io.Copy(io.Discard, snapshot)
+	return err
+}
+
+// Snapshot asks the leader for a snapshot of its data, without the updates that would follow it, and returns a
+// decoder of it. The connection to the leader is closed once ctx is done.
+func (s *Subscriber) Snapshot(ctx context.Context) (*rdb.Decoder, error) {
+	replication, c, reply, err := s.startReplication(ctx, "?", -1, true)
+	if err != nil {
+		return nil, err
+	}
+	context.AfterFunc(ctx, func() { _ = c.Close() })
+	if reply.Kind != protocol.SimpleString || !strings.HasPrefix(reply.SimpleString, "FULLRESYNC") {
+		return nil, fmt.Errorf("unexpected reply to PSYNC: %s", reply)
+	}
+	snapshot, err := readSnapshot(replication.RW.Reader)
 	if err != nil {
 		return nil, err
 	}
+	return rdb.NewDecoder(snapshot), nil
+}
 
-	slog.Info("start replication", "leader", s.LeaderAddr, "myaddress", s.MyAddr)
+// startReplication connects to the leader and asks it to stream its updates from the position given, returning the
+// connection, with the bytes read from it counted, and the reply to PSYNC.
+func (s *Subscriber) startReplication(ctx context.Context, replicationID string, offset int64,
+	snapshotOnly bool) (p *protocol.Conn, c *counter, reply *protocol.Message, err error) {
+	leader := s.LeaderAddr
+	if s.Sentinel != nil {
+		leader, err = s.Sentinel.Leader(ctx)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	conn, err := s.Dialer.DialContext(ctx, "tcp", leader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = conn.Close()
+		}
+	}()
+
+	slog.Info("start replication", "leader", leader, "myaddress", s.MyAddr)
 
 	myHost, myPort, err := net.SplitHostPort(s.MyAddr)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
-	p := protocol.NewConnection(conn)
+	c = &counter{Conn: conn}
+	p = protocol.NewConnection(c)
 	p.Logger = s.Logger
 
 	ping := protocol.NewOutgoingCommand("PING")
@@ -209,28 +606,45 @@ func (s *Subscriber) startReplication(ctx context.Context, replicationID string,
 		"REPLCONF",
 		"listening-port", myPort,
 		"ip-address", myHost,
+		"capa", "eof",
 		"capa", "psync2",
 	}
 	if snapshotOnly {
-		replconf = append(replconf, "snapshot-only", "1")
+		replconf = append(replconf, "rdb-only", "1")
 	}
 
 	capa := protocol.NewOutgoingCommand(replconf...)
 
-	_, err = p.RoundTrip(ping)
+	_, err = p.RoundTrip(*ping)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
-	_, err = p.RoundTrip(capa)
+	_, err = p.RoundTrip(*capa)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
-	_, err = p.RoundTrip(psync)
+	msg, err := p.RoundTrip(*psync)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
+	if msg.Kind == protocol.Error {
+		return nil, nil, nil, fmt.Errorf("PSYNC: %s", msg)
+	}
+
+	return p, c, &msg, nil
+}
 
-	return p, nil
+// MasterOffset returns the replication offset of the server on p, from INFO replication. Every write the server has
+// replied to, on any connection, is in its replication stream before that offset.
+func (s *Subscriber) MasterOffset(p *protocol.Conn) (int64, error) {
+	_, offsets, err := s.infoReplication(p)
+	if err != nil {
+		return 0, err
+	}
+	if len(offsets) == 0 {
+		return 0, fmt.Errorf("INFO replication has no master_repl_offset")
+	}
+	return offsets[0], nil
 }
 
 func (s *Subscriber) infoReplication(p *protocol.Conn) ([]string, []int64, error) {
@@ -238,7 +652,7 @@ func (s *Subscriber) infoReplication(p *protocol.Conn) ([]string, []int64, error
 		protocol.NewBulkString("info"),
 		protocol.NewBulkString("replication"),
 	)
-	read, err := p.RoundTrip(replication)
+	read, err := p.RoundTrip(*replication)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -247,7 +661,12 @@ func (s *Subscriber) infoReplication(p *protocol.Conn) ([]string, []int64, error
 	var offsets []int64
 	var attrs []slog.Attr
 
-	split := strings.Split(read.Str, "\r\n")
+	info, err := read.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	split := strings.Split(info, "\r\n")
 	for _, line := range split {
 		kvs := strings.Split(line, ":")
 		if len(kvs) != 2 {
@@ -270,26 +689,17 @@ func (s *Subscriber) infoReplication(p *protocol.Conn) ([]string, []int64, error
 	return replids, offsets, nil
 }
 
-// replconfAck sends an ack back to the server
+// replconfAck tells the leader the offset processed, or, if Durable is set, the offset durably committed, which is
+// never past it. The leader doesn't reply, so it may be sent while the stream is read.
 func (s *Subscriber) replconfAck(p *protocol.Conn, offset int64) error {
-	req := protocol.NewOutgoingCommand(
-		"replconf",
-		"ack",
-		strconv.FormatInt(offset, 10),
-	)
-
-	msg, err := p.RoundTrip(req)
+	ack := []string{"REPLCONF", "ACK", strconv.FormatInt(offset, 10)}
+	if s.Durable != nil {
+		durable := strconv.FormatInt(min(s.Durable(), offset), 10)
+		ack = []string{"REPLCONF", "ACK", durable, "FACK", durable}
+	}
+	_, err := p.Write(*protocol.NewOutgoingCommand(ack...))
 	if err != nil {
 		return err
 	}
-
-	if strings.ToUpper(msg.Str) == "PING" {
-		s.Logger.Info("got PING response; sending PONG")
-		_, err := p.Write(protocol.NewOutgoingCommand("PONG"))
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return p.Flush()
 }
@@ -6,16 +6,25 @@
 package replication
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/awinterman/anarchoredis/protocol"
+	"github.com/awinterman/anarchoredis/protocol/message"
+	"github.com/awinterman/anarchoredis/txn/rdb"
+	"github.com/awinterman/anarchoredis/txn/redistest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -28,11 +37,13 @@ func init() {
 }
 
 func TestSubscribe(t *testing.T) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
-
 	ListenAddress := os.Getenv("LISTEN_ADDRESS")
 	RedisAddress := os.Getenv("REDIS_ADDRESS")
+	if RedisAddress == "" {
+		t.Skip("REDIS_ADDRESS is not set; TestFollow_Leader follows a fake leader instead")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
 
 	s := &Subscriber{
 		Dialer:     net.Dialer{},
@@ -50,7 +61,7 @@ func TestSubscribe(t *testing.T) {
 		}
 	}()
 
-	err := s.StreamUpdates(ctx, func(cmd *protocol.Message) error {
+	err := s.StreamUpdates(ctx, func(cmd *protocol.Message, offset int64) error {
 		return nil
 	})
 
@@ -60,11 +71,13 @@ func TestSubscribe(t *testing.T) {
 }
 
 func TestGetSnapshot(t *testing.T) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
-
 	ListenAddress := os.Getenv("LISTEN_ADDRESS")
 	RedisAddress := os.Getenv("REDIS_ADDRESS")
+	if RedisAddress == "" {
+		t.Skip("REDIS_ADDRESS is not set; TestSnapshot_Leader uses a fake leader instead")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
 
 	s := &Subscriber{
 		Dialer:     net.Dialer{},
@@ -87,13 +100,32 @@ func TestGetSnapshot(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	all, err := io.ReadAll(snapshot)
-	if err != nil {
-		return
+	for record, err := range snapshot.Records() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Log(record)
 	}
+}
 
-	t.Log(string(all))
+// TestSnapshot_Leader tests taking a snapshot of a fake leader.
+func TestSnapshot_Leader(t *testing.T) {
+	leader := &redistest.Leader{Diskless: true}
+	leader.Start(t)
+	leader.Do(0, "SET", "a", "1")
+	leader.Do(3, "SET", "b", "2")
 
+	s := &Subscriber{LeaderAddr: leader.Addr(), MyAddr: "127.0.0.1:0", Logger: slog.Default()}
+	snapshot, err := s.Snapshot(context.Background())
+	assert.NoError(t, err)
+	var keys []string
+	for record, err := range snapshot.Records() {
+		assert.NoError(t, err)
+		if key, ok := record.(rdb.Key); ok {
+			keys = append(keys, fmt.Sprintf("%d %s %s", key.DB, key.Key, key.Value))
+		}
+	}
+	assert.Equal(t, []string{"0 a 1", "3 b 2"}, keys)
 }
 
 func write(t *testing.T, ctx context.Context, addr string, s *Subscriber) error {
@@ -121,7 +153,7 @@ func write(t *testing.T, ctx context.Context, addr string, s *Subscriber) error
 			"1000",
 		)
 
-		_, err := p.RoundTrip(array)
+		_, err := p.RoundTrip(*array)
 		if err != nil {
 			return err
 		}
@@ -140,3 +172,430 @@ func write(t *testing.T, ctx context.Context, addr string, s *Subscriber) error
 
 	return nil
 }
+
+// encode encodes a command as it is sent in the replication stream.
+func encode(args ...string) []byte {
+	var b bytes.Buffer
+	_, _ = (&message.Encoder{}).Encode(*protocol.NewOutgoingCommand(args...), &b)
+	return b.Bytes()
+}
+
+// TestFollow_Resume tests that a restarted stream continues from the last command processed outside of a
+// transaction, and so does a new subscriber with the same state, even from a leader that took over in a failover.
+func TestFollow_Resume(t *testing.T) {
+	leader := redistest.NewLeader(t)
+	state := filepath.Join(t.TempDir(), "replication")
+	events := make(chan string, 100)
+	// follow follows the leader at addr with a new subscriber until it is stopped.
+	follow := func(addr string) (stop func()) {
+		s := &Subscriber{LeaderAddr: addr, MyAddr: "127.0.0.1:0", Logger: slog.Default(), StatePath: state}
+		s.OnSync = func(full bool, database string) {
+			events <- fmt.Sprint("sync ", full, " ", database)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- s.Follow(ctx, func(cmd *protocol.Message, offset int64) error {
+				events <- fmt.Sprint(cmd.String(), " ", offset)
+				return nil
+			})
+		}()
+		return func() {
+			cancel()
+			assert.ErrorIs(t, <-done, context.Canceled)
+		}
+	}
+	expect := func(want ...string) {
+		t.Helper()
+		for _, w := range want {
+			select {
+			case e := <-events:
+				assert.Equal(t, w, e)
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for ", w)
+			}
+		}
+	}
+	// event moves offset past the command args, and returns the event of it being passed to msgFunc.
+	offset := int64(0)
+	event := func(args ...string) string {
+		offset += int64(len(encode(args...)))
+		return fmt.Sprint(protocol.NewOutgoingCommand(args...).String(), " ", offset)
+	}
+
+	stop := follow(leader.Addr())
+	expect("sync true 0")
+	offset = leader.Offset()
+	leader.Do(1, "SET", "a", "1")
+	expect(event("SELECT", "1"), event("SET", "a", "1"))
+
+	// the transaction is cut short, and sent again once the stream continues from before it.
+	leader.CutAfter(len(encode("MULTI")) + len(encode("SET", "b", "1")))
+	leader.Propagate("MULTI")
+	leader.Propagate("SET", "b", "1")
+	leader.Propagate("EXEC")
+	transaction := []string{event("MULTI"), event("SET", "b", "1"), event("EXEC")}
+	expect(transaction[:2]...)
+	expect("sync false 1")
+	expect(transaction...)
+	stop()
+
+	// the new leader continues the history of the old one with a new replication ID, in which the position is saved.
+	next := leader.Failover(t)
+	next.Do(1, "SET", "c", "1")
+	stop = follow(next.Addr())
+	expect("sync false 1", event("SELECT", "1"), event("SET", "c", "1"))
+	stop()
+	pos, err := loadPosition(state)
+	assert.NoError(t, err)
+	assert.Equal(t, position{ReplicationID: next.ReplicationID(), Offset: offset, Database: "1"}, pos)
+
+	stop = follow(next.Addr())
+	expect("sync false 1")
+	stop()
+	select {
+	case e := <-events:
+		t.Fatal("unexpected ", e)
+	default:
+	}
+}
+
+// TestReadSnapshot tests that snapshots are read up to their end, whether their length is given, or they end with a
+// mark, leaving the stream that follows them.
+func TestReadSnapshot(t *testing.T) {
+	mark := strings.Repeat("0123456789", 4)
+	// the snapshot has what looks like the start of the mark in it.
+	rdb := "REDIS0012" + mark[:39] + "\xff"
+	tests := []struct {
+		name   string
+		stream string
+	}{
+		{"length", "\n\n$" + fmt.Sprint(len(rdb)) + "\r\n" + rdb},
+		{"eof", "\n$EOF:" + mark + "\r\n" + rdb + mark},
+		{"eof at once", "$EOF:" + mark + "\r\n" + mark},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := bufio.NewReader(iotest.OneByteReader(strings.NewReader(test.stream + "+after\r\n")))
+			snapshot, err := readSnapshot(r)
+			assert.NoError(t, err)
+			read, err := io.ReadAll(snapshot)
+			assert.NoError(t, err)
+			if test.name == "eof at once" {
+				assert.Empty(t, read)
+			} else {
+				assert.Equal(t, rdb, string(read))
+			}
+			after, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, "+after\r\n", string(after))
+		})
+	}
+
+	for _, cut := range []string{"$EOF:" + mark + "\r\nREDIS", "$10\r\nREDIS"} {
+		snapshot, err := readSnapshot(bufio.NewReader(strings.NewReader(cut)))
+		assert.NoError(t, err)
+		_, err = io.ReadAll(snapshot)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	}
+}
+
+// TestFollow_Snapshot tests that the snapshot streamed by a diskless leader is passed to OnSnapshot, and that the
+// stream continues after it.
+func TestFollow_Snapshot(t *testing.T) {
+	leader := &redistest.Leader{Diskless: true}
+	leader.Start(t)
+	for i := range 100 {
+		leader.Do(0, "SET", fmt.Sprint("key:", i), strings.Repeat("v", 100))
+	}
+
+	s := &Subscriber{LeaderAddr: leader.Addr(), MyAddr: "127.0.0.1:0", Logger: slog.Default()}
+	var synced int64
+	s.OnSync = func(full bool, database string) {
+		synced = s.Offset.Load()
+		leader.Propagate("SET", "a", "1")
+	}
+	var snapshot []byte
+	s.OnSnapshot = func(r io.Reader) error {
+		// most of it is left unread.
+		snapshot = make([]byte, 9)
+		_, err := io.ReadFull(r, snapshot)
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var offset int64
+	err := s.StreamUpdates(ctx, func(cmd *protocol.Message, o int64) error {
+		assert.Equal(t, protocol.NewOutgoingCommand("SET", "a", "1").String(), cmd.String())
+		offset = o
+		cancel()
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "REDIS0011", string(snapshot))
+	assert.Equal(t, synced+int64(len(encode("SET", "a", "1"))), offset)
+}
+
+// TestStreamUpdates_SnapshotCutShort tests that a stream that fails before the snapshot has been read doesn't resume
+// from the position of the snapshot.
+func TestStreamUpdates_SnapshotCutShort(t *testing.T) {
+	leader := redistest.NewLeader(t)
+	leader.Do(0, "SET", "a", "1")
+
+	s := &Subscriber{LeaderAddr: leader.Addr(), MyAddr: "127.0.0.1:0", Logger: slog.Default(),
+		StatePath: filepath.Join(t.TempDir(), "replication")}
+	var syncs []bool
+	s.OnSync = func(full bool, database string) {
+		syncs = append(syncs, full)
+	}
+	for range 2 {
+		// the snapshot is cut short just after it starts.
+		fullResync := fmt.Sprintf("+FULLRESYNC %s %d\r\n", leader.ReplicationID(), leader.Offset())
+		leader.CutSync(len(fullResync) + len("$100\r\nREDIS"))
+		err := s.StreamUpdates(context.Background(), func(cmd *protocol.Message, offset int64) error {
+			return nil
+		})
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	}
+	assert.Equal(t, []bool{true, true}, syncs)
+	_, err := os.Stat(s.StatePath)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// TestStreamUpdates_Keepalive tests the replica side of the protocol: the PING and REPLCONF of the leader count
+// towards the offset without being passed to msgFunc, and GETACK is answered with the offset committed before it.
+func TestStreamUpdates_Keepalive(t *testing.T) {
+	acks := make(chan string, 10)
+	leader := &redistest.Leader{OnAck: func(ack, fack int64) {
+		acks <- fmt.Sprint(ack, " ", fack)
+	}}
+	leader.Start(t)
+
+	ping, setA, getack, setB := encode("PING"), encode("SET", "a", "1"), encode("REPLCONF", "GETACK", "*"),
+		encode("SET", "b", "1")
+	var synced, afterA, afterB int64
+	// SET b is never committed.
+	s := &Subscriber{LeaderAddr: leader.Addr(), MyAddr: "127.0.0.1:0", Logger: slog.Default(),
+		Durable: func() int64 { return afterA }}
+	s.OnSync = func(full bool, database string) {
+		synced = s.Offset.Load()
+		afterA = synced + int64(len(ping)+len(setA))
+		afterB = afterA + int64(len(getack)+len(setB))
+		leader.Propagate("PING")
+		leader.Propagate("SET", "a", "1")
+		leader.GetAck()
+		leader.Propagate("SET", "b", "1")
+		leader.GetAck()
+	}
+	var keepalives []int64
+	s.OnKeepalive = func(offset int64) {
+		keepalives = append(keepalives, offset)
+	}
+	var cmds []string
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.StreamUpdates(ctx, func(cmd *protocol.Message, offset int64) error {
+			cmds = append(cmds, fmt.Sprint(cmd.String(), " ", offset))
+			return nil
+		})
+	}()
+
+	for range 2 {
+		select {
+		case ack := <-acks:
+			// answered before the acks sent every 900ms.
+			assert.Equal(t, fmt.Sprint(afterA, " ", afterA), ack)
+		case <-time.After(500 * time.Millisecond):
+			t.Fatal("GETACK wasn't answered")
+		}
+	}
+	cancel()
+	<-done
+
+	assert.Equal(t, []string{
+		fmt.Sprint(protocol.NewOutgoingCommand("SET", "a", "1").String(), " ", afterA),
+		fmt.Sprint(protocol.NewOutgoingCommand("SET", "b", "1").String(), " ", afterB),
+	}, cmds)
+	assert.Equal(t, []int64{synced + int64(len(ping)), afterA + int64(len(getack)), afterB + int64(len(getack))},
+		keepalives)
+	assert.Equal(t, afterB+int64(len(getack)), s.Offset.Load())
+}
+
+// TestStreamUpdates_Failed tests that a command that fails isn't counted towards the offset, so that the stream
+// resumes from it.
+func TestStreamUpdates_Failed(t *testing.T) {
+	leader := redistest.NewLeader(t)
+	setA, setB := encode("SET", "a", "1"), encode("SET", "b", "1")
+
+	s := &Subscriber{LeaderAddr: leader.Addr(), MyAddr: "127.0.0.1:0", Logger: slog.Default()}
+	var syncs []bool
+	var afterA int64
+	s.OnSync = func(full bool, database string) {
+		syncs = append(syncs, full)
+		if full {
+			afterA = s.Offset.Load() + int64(len(setA))
+			leader.Propagate("SET", "a", "1")
+			leader.Propagate("SET", "b", "1")
+		}
+	}
+	failed := errors.New("failed")
+	err := s.StreamUpdates(context.Background(), func(cmd *protocol.Message, offset int64) error {
+		if offset > afterA {
+			return failed
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, failed)
+	assert.Equal(t, afterA, s.Offset.Load())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var cmds []string
+	err = s.StreamUpdates(ctx, func(cmd *protocol.Message, offset int64) error {
+		cmds = append(cmds, fmt.Sprint(cmd.String(), " ", offset))
+		cancel()
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, false}, syncs)
+	assert.Equal(t, []string{
+		fmt.Sprint(protocol.NewOutgoingCommand("SET", "b", "1").String(), " ", afterA+int64(len(setB))),
+	}, cmds)
+}
+
+// TestFollow_Leader follows a fake leader through the faults of a stream: a frame cut short, a disconnection, and a
+// restart of the leader, which loses its history. Every command is passed to msgFunc once, and the leader is told
+// the offset processed.
+func TestFollow_Leader(t *testing.T) {
+	leader := redistest.NewLeader(t)
+	leader.Do(0, "SET", "a", "1")
+
+	s := &Subscriber{LeaderAddr: leader.Addr(), MyAddr: "127.0.0.1:0", Logger: slog.Default(),
+		StatePath: filepath.Join(t.TempDir(), "replication")}
+	events := make(chan string, 100)
+	s.OnSync = func(full bool, database string) {
+		events <- fmt.Sprint("sync ", full)
+	}
+	s.OnSnapshot = func(r io.Reader) error {
+		for record, err := range rdb.NewDecoder(r).Records() {
+			if err != nil {
+				return err
+			}
+			if key, ok := record.(rdb.Key); ok {
+				events <- fmt.Sprintf("snapshot %s %s", key.Key, key.Value)
+			}
+		}
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.Follow(ctx, func(cmd *protocol.Message, offset int64) error {
+			events <- cmd.String()
+			return nil
+		})
+	}()
+	defer func() {
+		cancel()
+		assert.ErrorIs(t, <-done, context.Canceled)
+	}()
+
+	expect := func(want ...string) {
+		t.Helper()
+		for _, w := range want {
+			select {
+			case e := <-events:
+				assert.Equal(t, w, e)
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for ", w)
+			}
+		}
+	}
+	command := func(args ...string) string {
+		return protocol.NewOutgoingCommand(args...).String()
+	}
+
+	expect("sync true", "snapshot a 1")
+	leader.Do(0, "SET", "b", "2")
+	expect(command("SET", "b", "2"))
+
+	// the SELECT is cut short, and sent again once the replica continues.
+	leader.CutAfter(5)
+	leader.Do(1, "SET", "c", "3")
+	expect("sync false", command("SELECT", "1"), command("SET", "c", "3"))
+
+	leader.Disconnect()
+	leader.Do(1, "SET", "d", "4")
+	expect("sync false", command("SET", "d", "4"))
+
+	leader.Restart()
+	expect("sync true")
+	leader.Do(0, "SET", "e", "5")
+	expect(command("SELECT", "0"), command("SET", "e", "5"))
+
+	assert.Equal(t, 1, leader.Wait(1, 5*time.Second))
+	select {
+	case e := <-events:
+		t.Fatal("unexpected ", e)
+	default:
+	}
+}
+
+// TestStreamUpdates_Checkpoint tests that the position is saved before the offset is acknowledged to the leader, so
+// that a restarted stream doesn't pass what was acknowledged to msgFunc again.
+func TestStreamUpdates_Checkpoint(t *testing.T) {
+	type checkpoint struct{ saved, ack int64 }
+	acks := make(chan checkpoint, 100)
+	state := filepath.Join(t.TempDir(), "replication")
+	leader := &redistest.Leader{OnAck: func(ack, fack int64) {
+		pos, err := loadPosition(state)
+		assert.NoError(t, err)
+		acks <- checkpoint{saved: pos.Offset, ack: ack}
+	}}
+	leader.Start(t)
+	s := &Subscriber{LeaderAddr: leader.Addr(), MyAddr: "127.0.0.1:0", Logger: slog.Default(), StatePath: state}
+	synced := make(chan struct{}, 1)
+	s.OnSync = func(full bool, database string) {
+		synced <- struct{}{}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.StreamUpdates(ctx, func(cmd *protocol.Message, offset int64) error {
+			return nil
+		})
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	<-synced
+	leader.Do(0, "INCR", "a")
+	leader.Do(0, "INCR", "a")
+	processed := leader.Offset()
+	leader.GetAck()
+	for {
+		select {
+		case c := <-acks:
+			assert.GreaterOrEqual(t, c.saved, c.ack)
+			if c.ack >= processed {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for an ack")
+		}
+	}
+}
+
+// TestSynced_Unexpected tests that a reply to PSYNC that is neither a full resync nor a continuation fails the stream,
+// including an empty one.
+func TestSynced_Unexpected(t *testing.T) {
+	for _, reply := range []string{"", " ", "FULLRESYNC", "FULLRESYNC id", "CONTINUE id extra", "OK"} {
+		s := &Subscriber{Logger: slog.Default()}
+		msg := message.SimpleString(reply)
+		_, err := s.synced(&msg)
+		assert.Error(t, err, reply)
+	}
+}
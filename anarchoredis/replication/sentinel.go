@@ -0,0 +1,240 @@
+// Copyright 2025 Outreach Corporation. All Rights Reserved.
+
+// Description:
+
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/awinterman/anarchoredis/protocol"
+	"github.com/awinterman/anarchoredis/protocol/message"
+)
+
+// switchMaster is the channel that sentinels announce a failover on, once they have promoted a replica: its messages
+// are "<name> <old ip> <old port> <new ip> <new port>".
+const switchMaster = "+switch-master"
+
+// ErrNoLeader is returned by Sentinel.Leader if no sentinel knows of a leader that says it is one.
+var ErrNoLeader = errors.New("no sentinel knows of a leader")
+
+// Sentinel finds the leader of the redis servers that Redis Sentinel monitors by a name, as sentinel clients do: it
+// asks the sentinels in turn for its address, starting with the last one that answered, and follows the failovers
+// they announce.
+type Sentinel struct {
+	// Addrs are the addresses of the sentinels.
+	Addrs []string
+	// MasterName is the name the sentinels monitor the leader by.
+	MasterName string
+	Dialer     net.Dialer
+	Logger     *slog.Logger
+
+	mu sync.Mutex
+	// first is the index in Addrs of the sentinel asked first.
+	first int
+}
+
+// Leader asks the sentinels for the address of the leader, and checks with ROLE that the server there is a leader,
+// since a sentinel may not have seen a failover yet.
+func (s *Sentinel) Leader(ctx context.Context) (string, error) {
+	if len(s.Addrs) == 0 {
+		return "", fmt.Errorf("no sentinel addresses for %q", s.MasterName)
+	}
+	s.mu.Lock()
+	first := s.first
+	s.mu.Unlock()
+
+	var errs []error
+	for i := range s.Addrs {
+		n := (first + i) % len(s.Addrs)
+		addr, err := s.ask(ctx, s.Addrs[n])
+		if err == nil {
+			err = s.checkRole(ctx, addr)
+		}
+		if err == nil {
+			s.mu.Lock()
+			s.first = n
+			s.mu.Unlock()
+			return addr, nil
+		}
+		if ctx.Err() != nil {
+			return "", context.Cause(ctx)
+		}
+		errs = append(errs, fmt.Errorf("sentinel %s: %w", s.Addrs[n], err))
+	}
+	return "", fmt.Errorf("%w %q: %w", ErrNoLeader, s.MasterName, errors.Join(errs...))
+}
+
+// ask asks the sentinel at addr for the address of the leader.
+func (s *Sentinel) ask(ctx context.Context, addr string) (string, error) {
+	reply, err := s.roundTrip(ctx, addr, "SENTINEL", "get-master-addr-by-name", s.MasterName)
+	if err != nil {
+		return "", err
+	}
+	if reply.IsNull() {
+		return "", fmt.Errorf("unknown master %q", s.MasterName)
+	}
+	hostPort, err := texts(reply)
+	if err != nil || len(hostPort) != 2 {
+		return "", fmt.Errorf("unexpected reply to SENTINEL get-master-addr-by-name: %s", reply)
+	}
+	return net.JoinHostPort(hostPort[0], hostPort[1]), nil
+}
+
+// checkRole checks that the server at addr is a leader.
+func (s *Sentinel) checkRole(ctx context.Context, addr string) error {
+	reply, err := s.roundTrip(ctx, addr, "ROLE")
+	if err != nil {
+		return err
+	}
+	if reply.Kind != protocol.Array || len(reply.Elems) == 0 {
+		return fmt.Errorf("unexpected reply to ROLE: %s", reply)
+	}
+	role := text(reply.Elems[0])
+	if role != "master" {
+		return fmt.Errorf("%s is a %s, not a leader", addr, role)
+	}
+	return nil
+}
+
+// roundTrip sends a command to the server at addr on a new connection, and returns the reply read into memory.
+func (s *Sentinel) roundTrip(ctx context.Context, addr string, args ...string) (protocol.Message, error) {
+	conn, err := s.Dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return protocol.Message{}, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	// RoundTrip would log the reply, which consumes its elements.
+	p := protocol.NewConnection(conn)
+	_, err = p.Write(*protocol.NewOutgoingCommand(args...))
+	if err == nil {
+		err = p.Flush()
+	}
+	if err != nil {
+		return protocol.Message{}, err
+	}
+	reply, err := p.Read()
+	if err != nil {
+		return protocol.Message{}, err
+	}
+	reply, err = message.Materialize(reply, -1)
+	if err != nil {
+		return protocol.Message{}, err
+	}
+	if reply.Kind == protocol.Error {
+		return protocol.Message{}, fmt.Errorf("%s: %s", args[0], reply)
+	}
+	return reply, nil
+}
+
+// Watch calls f with the address of the new leader whenever the sentinels announce a failover of MasterName, until
+// ctx is done. It listens to one sentinel at a time, moving on to the next if the connection to it fails, so a
+// failover announced while it reconnects is missed, and is only found by asking for the Leader.
+func (s *Sentinel) Watch(ctx context.Context, f func(addr string)) error {
+	if len(s.Addrs) == 0 {
+		return fmt.Errorf("no sentinel addresses for %q", s.MasterName)
+	}
+	delay := retryMin
+	for n := 0; ; n = (n + 1) % len(s.Addrs) {
+		subscribed, err := s.watch(ctx, s.Addrs[n], f)
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		// the delay only grows while no sentinel can be subscribed to, so that a failure after a long watch is
+		// retried as soon as the first.
+		if subscribed {
+			delay = retryMin
+		}
+		s.Logger.Error("watching sentinel failed", "sentinel", s.Addrs[n], "error", err, "after", delay)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+		delay = min(2*delay, retryMax)
+	}
+}
+
+// watch subscribes to the failovers announced by the sentinel at addr, and calls f for those of MasterName until the
+// connection fails or ctx is done. It reports whether the sentinel confirmed the subscription first.
+func (s *Sentinel) watch(ctx context.Context, addr string, f func(addr string)) (subscribed bool, err error) {
+	conn, err := s.Dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	p := protocol.NewConnection(conn)
+	_, err = p.Write(*protocol.NewOutgoingCommand("SUBSCRIBE", switchMaster))
+	if err == nil {
+		err = p.Flush()
+	}
+	if err != nil {
+		return false, err
+	}
+	for {
+		read, err := p.Read()
+		if err != nil {
+			return subscribed, err
+		}
+		read, err = message.Materialize(read, -1)
+		if err != nil {
+			return subscribed, err
+		}
+		if read.Kind == protocol.Error {
+			return subscribed, fmt.Errorf("SUBSCRIBE: %s", read)
+		}
+		fields, err := texts(read)
+		if err != nil || len(fields) != 3 || fields[1] != switchMaster {
+			continue
+		}
+		// the subscription is confirmed with a message of its own, which isn't a failover.
+		if fields[0] == "subscribe" {
+			subscribed = true
+		}
+		if fields[0] != "message" {
+			continue
+		}
+		event := strings.Fields(fields[2])
+		if len(event) != 5 || event[0] != s.MasterName {
+			continue
+		}
+		leader := net.JoinHostPort(event[3], event[4])
+		s.Logger.Info("leader failed over", "master", s.MasterName, "from", net.JoinHostPort(event[1], event[2]),
+			"to", leader)
+		f(leader)
+	}
+}
+
+// texts returns the elements of an array of strings.
+func texts(m protocol.Message) ([]string, error) {
+	if m.Kind != protocol.Array && m.Kind != protocol.Push {
+		return nil, fmt.Errorf("not an array: %s", m)
+	}
+	var s []string
+	for _, el := range m.Elems {
+		s = append(s, text(el))
+	}
+	return s, nil
+}
+
+// text returns the string a message holds, if any.
+func text(m protocol.Message) string {
+	if m.Kind == protocol.SimpleString {
+		return m.SimpleString
+	}
+	return string(m.Bytes)
+}
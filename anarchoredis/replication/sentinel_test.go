@@ -0,0 +1,157 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/awinterman/anarchoredis/protocol"
+	"github.com/awinterman/anarchoredis/txn/rdb"
+	"github.com/awinterman/anarchoredis/txn/redistest"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSentinel_Leader tests that the sentinels are asked in turn until one knows of a leader, which must say it is
+// one.
+func TestSentinel_Leader(t *testing.T) {
+	leader := redistest.NewLeader(t)
+	dead := redistest.NewSentinel(t)
+	dead.Close()
+	unknown := redistest.NewSentinel(t)
+	good := redistest.NewSentinel(t)
+	good.Monitor("mymaster", leader.Addr())
+	// a sentinel is no leader, and doesn't reply to ROLE.
+	good.Monitor("sentinel", good.Addr())
+
+	s := &Sentinel{Addrs: []string{dead.Addr(), unknown.Addr(), good.Addr()}, MasterName: "mymaster",
+		Logger: slog.Default()}
+	addr, err := s.Leader(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, leader.Addr(), addr)
+	assert.Equal(t, 2, s.first, "the sentinel that answered is asked first next time")
+
+	s.MasterName = "sentinel"
+	_, err = s.Leader(context.Background())
+	assert.ErrorIs(t, err, ErrNoLeader)
+	s.MasterName = "missing"
+	_, err = s.Leader(context.Background())
+	assert.ErrorIs(t, err, ErrNoLeader)
+	assert.ErrorContains(t, err, `unknown master "missing"`)
+}
+
+// TestFollow_Failover follows a leader found through a sentinel, restarting the stream when the sentinel announces a
+// failover, and continuing it with the new leader, which inherits the replication history of the old one.
+func TestFollow_Failover(t *testing.T) {
+	leader := redistest.NewLeader(t)
+	leader.Do(0, "SET", "a", "1")
+	sentinel := redistest.NewSentinel(t)
+	sentinel.Monitor("mymaster", leader.Addr())
+
+	s := &Subscriber{MyAddr: "127.0.0.1:0", Logger: slog.Default(),
+		Sentinel: &Sentinel{Addrs: []string{sentinel.Addr()}, MasterName: "mymaster", Logger: slog.Default()}}
+	events := make(chan string, 100)
+	failovers := make(chan string, 100)
+	s.OnSync = func(full bool, database string) {
+		events <- fmt.Sprint("sync ", full)
+	}
+	s.OnSnapshot = func(r io.Reader) error {
+		for record, err := range rdb.NewDecoder(r).Records() {
+			if err != nil {
+				return err
+			}
+			if key, ok := record.(rdb.Key); ok {
+				events <- fmt.Sprintf("snapshot %s %s", key.Key, key.Value)
+			}
+		}
+		return nil
+	}
+	s.OnFailover = func(addr string) {
+		failovers <- addr
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.Follow(ctx, func(cmd *protocol.Message, offset int64) error {
+			events <- cmd.String()
+			return nil
+		})
+	}()
+	defer func() {
+		cancel()
+		assert.ErrorIs(t, <-done, context.Canceled)
+	}()
+
+	receive := func(ch chan string, want string) {
+		t.Helper()
+		select {
+		case e := <-ch:
+			assert.Equal(t, want, e)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for ", want)
+		}
+	}
+	expect := func(want ...string) {
+		t.Helper()
+		for _, w := range want {
+			receive(events, w)
+		}
+	}
+	command := func(args ...string) string {
+		return protocol.NewOutgoingCommand(args...).String()
+	}
+
+	expect("sync true", "snapshot a 1")
+	leader.Do(0, "SET", "b", "2")
+	expect(command("SET", "b", "2"))
+
+	// the stream restarts when a failover is announced, even though the leader hasn't closed it.
+	deadline := time.Now().Add(5 * time.Second)
+	for sentinel.Subscribers() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	sentinel.Switch("mymaster", leader.Addr())
+	receive(failovers, leader.Addr())
+	expect("sync false")
+
+	next := leader.Failover(t)
+	sentinel.Switch("mymaster", next.Addr())
+	receive(failovers, next.Addr())
+	expect("sync false")
+	next.Do(0, "SET", "c", "3")
+	expect(command("SELECT", "0"), command("SET", "c", "3"))
+	assert.Equal(t, next.ReplicationID(), *s.ReplicationID.Load())
+	assert.Equal(t, next.Offset(), s.Offset.Load())
+	select {
+	case e := <-events:
+		t.Fatal("unexpected ", e)
+	default:
+	}
+}
+
+// TestSentinel_Watch tests that watching a sentinel reports whether it was subscribed to before it failed, so that
+// Watch only backs off while none can be.
+func TestSentinel_Watch(t *testing.T) {
+	sentinel := redistest.NewSentinel(t)
+	s := &Sentinel{Addrs: []string{sentinel.Addr()}, MasterName: "mymaster", Logger: slog.Default()}
+
+	done := make(chan error)
+	var subscribed bool
+	go func() {
+		var err error
+		subscribed, err = s.watch(context.Background(), sentinel.Addr(), func(addr string) {})
+		done <- err
+	}()
+	for sentinel.Subscribers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	sentinel.Close()
+	assert.Error(t, <-done)
+	assert.True(t, subscribed)
+
+	subscribed, err := s.watch(context.Background(), sentinel.Addr(), func(addr string) {})
+	assert.Error(t, err)
+	assert.False(t, subscribed)
+}
@@ -0,0 +1,106 @@
+package replication
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// markLength is the length of the mark that ends a snapshot streamed without its length.
+const markLength = 40
+
+// readSnapshot reads the header of the snapshot that the leader sends after a full resync, and returns a reader of
+// the snapshot, which is left in r until it is read. The leader sends newlines while the snapshot is being prepared,
+// then either its length, as a bulk string that isn't followed by a line feed, or, if it is streamed to the replica
+// as it is saved, "EOF:" followed by a random mark that is sent again once it ends.
+func readSnapshot(r *bufio.Reader) (io.Reader, error) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			continue
+		}
+
+		header, ok := strings.CutPrefix(line, "$")
+		if !ok {
+			return nil, fmt.Errorf("expected a snapshot, got %q", line)
+		}
+		if mark, ok := strings.CutPrefix(header, "EOF:"); ok {
+			if len(mark) != markLength {
+				return nil, fmt.Errorf("invalid snapshot end mark %q", mark)
+			}
+			return &eofReader{r: r, mark: []byte(mark)}, nil
+		}
+		n, err := strconv.ParseInt(header, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid snapshot length %q", header)
+		}
+		return &lengthReader{r: r, n: n}, nil
+	}
+}
+
+// lengthReader reads a snapshot of n bytes from r, failing if r ends before it does.
+type lengthReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *lengthReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if errors.Is(err, io.EOF) && l.n > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// eofReader reads a snapshot from r until the mark that ends it, which it consumes, without reading past it.
+type eofReader struct {
+	r    *bufio.Reader
+	mark []byte
+	done bool
+}
+
+func (e *eofReader) Read(p []byte) (int, error) {
+	if e.done {
+		return 0, io.EOF
+	}
+	// look at what is buffered, but at least as much as the mark.
+	window, err := e.r.Peek(max(e.r.Buffered(), len(e.mark)))
+	if errors.Is(err, io.EOF) {
+		return 0, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return 0, err
+	}
+
+	if i := bytes.Index(window, e.mark); i >= 0 {
+		n := copy(p, window[:i])
+		if n < i {
+			_, err = e.r.Discard(n)
+			return n, err
+		}
+		e.done = true
+		_, err = e.r.Discard(n + len(e.mark))
+		if err == nil && n == 0 {
+			err = io.EOF
+		}
+		return n, err
+	}
+	// the mark may begin in the last bytes of the window, which are held back until more is read.
+	n := copy(p, window[:len(window)-len(e.mark)+1])
+	_, err = e.r.Discard(n)
+	return n, err
+}
@@ -0,0 +1,132 @@
+// Copyright 2025 Outreach Corporation. All Rights Reserved.
+
+// Description:
+
+package replication
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/awinterman/anarchoredis/protocol"
+)
+
+// Stats is a snapshot of how far a Subscriber is behind its leader, and of how fast it reads the stream.
+type Stats struct {
+	// LeaderOffset is the replication offset of the leader when it was last polled, or zero if it never was, and
+	// LeaderPolled is when that was.
+	LeaderOffset int64
+	LeaderPolled time.Time
+	// Received is the offset just past the last command read from the stream, and Appended is the offset up to which
+	// commands have been durably committed, see Durable, or processed if it isn't set.
+	Received, Appended int64
+	// Bytes counts what has been read from the stream since the Subscriber was created, but the snapshots, and
+	// Commands the commands passed to msgFunc. BytesPerSecond and CommandsPerSecond are their rates over the last
+	// PollInterval, or zero if the stream isn't being polled.
+	Bytes, Commands                   int64
+	BytesPerSecond, CommandsPerSecond float64
+	// SinceLastFrame is how long ago the last frame was read from the stream, keepalives included, or zero if none
+	// has been.
+	SinceLastFrame time.Duration
+}
+
+// Lag is how many bytes of the stream of the leader had yet to be appended when it was last polled.
+func (st Stats) Lag() int64 {
+	return max(st.LeaderOffset-st.Appended, 0)
+}
+
+// stats are the counters that Stats reads, which are updated as the stream is read.
+type stats struct {
+	received     atomic.Int64
+	leaderOffset atomic.Int64
+	// leaderPolled and lastFrame are in nanoseconds since the Unix epoch, or zero.
+	leaderPolled atomic.Int64
+	lastFrame    atomic.Int64
+	bytes        atomic.Int64
+	commands     atomic.Int64
+	rates        atomic.Pointer[rates]
+}
+
+// rates are the rates at which the stream was read between two polls.
+type rates struct {
+	bytes, commands float64
+}
+
+// Stats returns how far the Subscriber is behind its leader, and how fast it reads the stream. The offset of the
+// leader is only known if PollInterval is set.
+func (s *Subscriber) Stats() Stats {
+	st := Stats{
+		LeaderOffset: s.stats.leaderOffset.Load(),
+		Received:     s.stats.received.Load(),
+		Appended:     s.Offset.Load(),
+		Bytes:        s.stats.bytes.Load(),
+		Commands:     s.stats.commands.Load(),
+	}
+	if s.Durable != nil {
+		st.Appended = min(s.Durable(), st.Appended)
+	}
+	if polled := s.stats.leaderPolled.Load(); polled != 0 {
+		st.LeaderPolled = time.Unix(0, polled)
+	}
+	if r := s.stats.rates.Load(); r != nil {
+		st.BytesPerSecond, st.CommandsPerSecond = r.bytes, r.commands
+	}
+	if last := s.stats.lastFrame.Load(); last != 0 {
+		st.SinceLastFrame = time.Since(time.Unix(0, last))
+	}
+	return st
+}
+
+// poll asks the leader at addr for its replication offset every PollInterval, on a connection of its own, and
+// samples the rates at which the stream is read, until ctx is done. The rates are cleared once it returns, since
+// the stream has stopped.
+func (s *Subscriber) poll(ctx context.Context, addr string) {
+	defer s.stats.rates.Store(nil)
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			_ = conn.Close()
+		}
+	}()
+	var p *protocol.Conn
+	last, bytes, commands := time.Now(), s.stats.bytes.Load(), s.stats.commands.Load()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		elapsed := now.Sub(last).Seconds()
+		b, c := s.stats.bytes.Load(), s.stats.commands.Load()
+		s.stats.rates.Store(&rates{bytes: float64(b-bytes) / elapsed, commands: float64(c-commands) / elapsed})
+		last, bytes, commands = now, b, c
+
+		if conn == nil {
+			var err error
+			conn, err = s.Dialer.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				s.Logger.Warn("could not dial leader to poll its offset", "leader", addr, "error", err)
+				continue
+			}
+			p = protocol.NewConnection(conn)
+			p.Logger = s.Logger
+		}
+		_ = conn.SetDeadline(now.Add(s.PollInterval))
+		offset, err := s.MasterOffset(p)
+		if err != nil {
+			s.Logger.Warn("could not poll the offset of the leader", "leader", addr, "error", err)
+			_ = conn.Close()
+			conn = nil
+			continue
+		}
+		s.stats.leaderOffset.Store(offset)
+		s.stats.leaderPolled.Store(time.Now().UnixNano())
+	}
+}
@@ -0,0 +1,70 @@
+package replication
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/awinterman/anarchoredis/protocol"
+	"github.com/awinterman/anarchoredis/txn/redistest"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStats follows a fake leader, polling its offset, and checks how far behind it the stream is, and how much of it
+// has been read.
+func TestStats(t *testing.T) {
+	leader := redistest.NewLeader(t)
+	leader.Do(0, "SET", "a", "1")
+
+	s := &Subscriber{LeaderAddr: leader.Addr(), MyAddr: "127.0.0.1:0", Logger: slog.Default(),
+		PollInterval: 10 * time.Millisecond}
+	// durable lags behind by the last command processed.
+	var durable atomic.Int64
+	s.Durable = durable.Load
+	syncs := make(chan bool, 1)
+	s.OnSync = func(full bool, database string) {
+		syncs <- full
+	}
+	assert.Equal(t, Stats{}, s.Stats())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	processed := make(chan int64)
+	go func() {
+		done <- s.Follow(ctx, func(cmd *protocol.Message, offset int64) error {
+			durable.Store(s.Offset.Load())
+			processed <- offset
+			return nil
+		})
+	}()
+	defer func() {
+		cancel()
+		assert.ErrorIs(t, <-done, context.Canceled)
+	}()
+
+	assert.True(t, <-syncs)
+	synced := leader.Offset()
+	leader.Do(0, "SET", "b", "2")
+	leader.Do(0, "SET", "c", "3")
+	for range 2 {
+		<-processed
+	}
+	offset := leader.Offset()
+	assert.Eventually(t, func() bool {
+		st := s.Stats()
+		return st.LeaderOffset == offset && st.Received == offset && s.Offset.Load() == offset
+	}, 5*time.Second, time.Millisecond)
+
+	st := s.Stats()
+	assert.Equal(t, offset-synced, st.Bytes, "the snapshot isn't counted")
+	assert.EqualValues(t, 2, st.Commands)
+	assert.Equal(t, durable.Load(), st.Appended)
+	assert.Equal(t, offset-durable.Load(), st.Lag())
+	assert.WithinDuration(t, time.Now(), st.LeaderPolled, 5*time.Second)
+	assert.Greater(t, st.SinceLastFrame, time.Duration(0))
+	// the rates are over the last poll, which may have been after the commands were read.
+	assert.GreaterOrEqual(t, st.BytesPerSecond, 0.0)
+	assert.GreaterOrEqual(t, st.CommandsPerSecond, 0.0)
+}
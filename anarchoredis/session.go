@@ -0,0 +1,352 @@
+package anarchoredis
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/awinterman/anarchoredis/protocol"
+	"github.com/awinterman/anarchoredis/protocol/message"
+	"github.com/awinterman/anarchoredis/txn/localstate"
+)
+
+// session is the state of a client connection proxied to the upstream server.
+//
+// The session takes a connection from the pool of upstream connections when it forwards a request, and gives it back
+// once every reply has been read, unless the state of the connection belongs to the session, such as the selected
+// database, or a transaction, in which case the session is pinned to it until that state is reset. A blocking
+// command holds its connection until it is answered, as any request in flight does.
+type session struct {
+	connection *protocol.Conn
+	upstreams  *upstreamPool
+	// closer closes the client connection, if set.
+	closer io.Closer
+	// owner identifies the session as the owner of the key locks it takes.
+	owner string
+
+	// mu guards the rest of the session, which is tracked when forwarding requests and settled when replying.
+	mu sync.Mutex
+	// upstream is the connection taken from upstreams, if any.
+	upstream *upstreamConn
+	// inflight is the number of requests written to upstream whose replies have not been sent.
+	inflight int
+
+	// database is the database selected by the client.
+	database string
+	// watching says whether keys are watched with WATCH.
+	watching bool
+	// stateful says whether a command has changed the state of the upstream connection in a way that lasts until
+	// RESET, see connectionState.
+	stateful bool
+
+	// multi says whether a transaction has been started with MULTI.
+	multi bool
+	// queuedDatabase is the database selected by the commands queued since MULTI.
+	queuedDatabase string
+	// writes are the keys written by the commands queued since MULTI, and reads the rest of their keys. queuedWrite
+	// says whether any of the commands writes, since some write no keys.
+	writes, reads dbKeys
+	queuedWrite   bool
+}
+
+// errUpstreamReset hangs up on a client whose session is pinned to a connection to a former upstream server, e.g.
+// to the old leader after a failover.
+var errUpstreamReset = errors.New("session is pinned to a connection to a former upstream server")
+
+// request is what is kept of a request forwarded upstream until its reply has been read, since the frame it was
+// read from is only valid until the next read from the client.
+type request struct {
+	name  string
+	keys  []string
+	write bool
+	// serializing says whether the request holds off every other, see protocol.Command.IsSerializing.
+	serializing bool
+	// arg is the first argument of the command, e.g. the database of SELECT.
+	arg string
+
+	// err is the error replied for a request that could not be parsed, which isn't forwarded.
+	err error
+	// upstream is the connection the request was forwarded to.
+	upstream *upstreamConn
+
+	// database is the database selected when the request was forwarded, and selects the one it selects, if any.
+	database, selects string
+	// queued says whether the request was queued by MULTI.
+	queued bool
+	// lock are the keys the request writes, which are locked until it is committed, and await the keys it reads,
+	// which must be unlocked before it is replied to.
+	lock, await dbKeys
+	// held are the locks taken on lock, by database, until they are released by commit.
+	held map[string]localstate.Lock
+}
+
+// connectionState are the commands that change the state of the connection they are sent on until it is reset, so
+// that a session that sends them is pinned to its upstream connection.
+var connectionState = map[string]bool{
+	"AUTH":            true,
+	"HELLO":           true,
+	"CLIENT SETNAME":  true,
+	"CLIENT SETINFO":  true,
+	"CLIENT TRACKING": true,
+	"CLIENT REPLY":    true,
+	"CLIENT NO-EVICT": true,
+	"CLIENT NO-TOUCH": true,
+	"READONLY":        true,
+	"READWRITE":       true,
+	"SUBSCRIBE":       true,
+	"PSUBSCRIBE":      true,
+	"SSUBSCRIBE":      true,
+	"MONITOR":         true,
+}
+
+// newRequest parses a request from a frame read from the client.
+func newRequest(frame protocol.Frame) request {
+	cmd, err := frame.Command()
+	if err != nil {
+		return request{err: err}
+	}
+	keys, err := cmd.Keys()
+	if err != nil {
+		return request{name: cmd.Name, err: err}
+	}
+
+	req := request{
+		name:        cmd.Name,
+		keys:        keys,
+		write:       cmd.IsWrite(),
+		serializing: cmd.IsSerializing(),
+	}
+	if len(cmd.Args) > 0 {
+		req.arg = string(cmd.Args[0])
+	}
+	return req
+}
+
+// dbKeys are keys by the number of the database they are in.
+type dbKeys map[string][]string
+
+// add adds keys in database to k, which is allocated if nil.
+func (k dbKeys) add(database string, keys []string) dbKeys {
+	if len(keys) == 0 {
+		return k
+	}
+	if k == nil {
+		k = dbKeys{}
+	}
+	k[database] = append(k[database], keys...)
+	return k
+}
+
+// track follows the selected database and transaction state of the session as req is forwarded, assuming that it
+// succeeds, and sets the keys it locks and awaits. settle corrects the state once the reply is known.
+//
+// Commands queued by MULTI are not executed until EXEC, at which point the keys of the whole transaction are locked.
+func (s *session) track(req *request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	req.database = s.database
+
+	switch {
+	case req.name == "MULTI":
+		// MULTI can't be nested, and fails without ending the transaction.
+		if !s.multi {
+			s.multi = true
+			s.queuedDatabase = s.database
+		}
+	case req.name == "DISCARD":
+		s.reset()
+	case req.name == "RESET":
+		s.reset()
+		s.database = "0"
+	case req.name == "EXEC" && s.multi:
+		writes, reads, database, write := s.writes, s.reads, s.queuedDatabase, s.queuedWrite
+		s.reset()
+		s.database, req.selects = database, database
+		req.lock, req.await = writes, reads
+		req.write = req.write || write
+	case s.multi:
+		req.queued = true
+		switch {
+		case req.name == "SELECT":
+			s.queuedDatabase = req.arg
+		case req.write:
+			s.writes = s.writes.add(s.queuedDatabase, req.keys)
+			s.queuedWrite = true
+		default:
+			s.reads = s.reads.add(s.queuedDatabase, req.keys)
+		}
+	case req.name == "SELECT":
+		s.database, req.selects = req.arg, req.arg
+	case req.name == "WATCH":
+		s.watching = true
+		req.await = req.await.add(s.database, req.keys)
+	case req.name == "UNWATCH":
+		s.watching = false
+	case req.write:
+		req.lock = req.lock.add(s.database, req.keys)
+	default:
+		req.await = req.await.add(s.database, req.keys)
+	}
+}
+
+// settle corrects the state tracked for req given its reply, and says whether it failed, in which case nothing it
+// locked is committed.
+//
+// A failed SELECT, or a transaction aborted by WATCH or by a command that failed to queue, leaves the database as it
+// was. Requests pipelined after it were tracked in the database it would have selected.
+func (s *session) settle(req *request, reply *protocol.Message) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	failed := reply.Kind == protocol.Error || reply.Kind == protocol.BulkError || req.name == "EXEC" && reply.IsNull()
+
+	if connectionState[req.name] && !failed {
+		s.stateful = true
+	}
+	if req.name == "RESET" {
+		s.stateful = false
+	}
+	if failed && req.selects != "" && s.database == req.selects {
+		s.database = req.database
+	}
+	return failed
+}
+
+// reset ends the transaction, if any, and unwatches every key.
+func (s *session) reset() {
+	s.multi = false
+	s.watching = false
+	s.queuedDatabase = ""
+	s.writes, s.reads = nil, nil
+	s.queuedWrite = false
+}
+
+// pinned says whether the state of the upstream connection belongs to the session, so that it can't be shared. s.mu
+// must be held.
+func (s *session) pinned() bool {
+	return s.stateful || s.watching || s.multi || s.database != "0"
+}
+
+// acquire returns the upstream connection of the session, taking one from the pool if it has none, and counts a
+// request in flight on it. It fails with errUpstreamReset if the session is pinned to a connection dialed before the
+// pool was reset, since the state of the connection can't be carried over to a new one.
+func (s *session) acquire(ctx context.Context) (*upstreamConn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.upstream != nil && s.pinned() && s.upstreams.stale(s.upstream) {
+		return nil, errUpstreamReset
+	}
+	if s.upstream == nil {
+		// nothing is in flight, so the connection isn't given back while it is taken.
+		u, err := s.upstreams.get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.upstream = u
+	}
+	s.inflight++
+	return s.upstream, nil
+}
+
+// done counts a reply sent, and gives the upstream connection back to the pool once nothing is in flight, unless the
+// session is pinned to it. It must be called after settle.
+func (s *session) done() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inflight--
+	if s.inflight == 0 && !s.pinned() {
+		s.upstreams.put(s.upstream)
+		s.upstream = nil
+	}
+}
+
+// flush flushes the requests written to the upstream connection, if any.
+func (s *session) flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.upstream == nil {
+		return nil
+	}
+	return s.upstream.Flush()
+}
+
+// hangUp closes the upstream connection the session holds when the client goes away, since either replies are
+// left unread on it, or its state belongs to the session.
+func (s *session) hangUp() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.upstream != nil {
+		s.upstreams.discard(s.upstream)
+		s.upstream = nil
+		s.inflight = 0
+	}
+}
+
+// applier commits the commands of the replication stream to the transaction log. The replication stream wraps
+// transactions, and the effects of scripts, in MULTI and EXEC, which are collected and committed as one entry.
+type applier struct {
+	*Transactor
+
+	// database is the database selected by the replication stream, in which the commands that follow are executed.
+	database string
+
+	// queued are the commands of the transaction since MULTI, nil outside of one.
+	queued []protocol.Message
+	// queuedDatabase is the database selected when the transaction began.
+	queuedDatabase string
+	keys           dbKeys
+}
+
+// restart starts over when the replication stream starts, in database, from the last command outside of a
+// transaction, so that a transaction cut short is dropped.
+func (a *applier) restart(database string) {
+	a.database = database
+	a.queued, a.queuedDatabase, a.keys = nil, "", nil
+}
+
+// apply commits msg, or queues it if it is part of a transaction.
+func (a *applier) apply(ctx context.Context, msg *protocol.Message) error {
+	cmd, err := protocol.Cmd(*msg)
+	if err != nil {
+		return err
+	}
+
+	// the leader's PING and REPLCONF keep the stream alive, and aren't part of the data.
+	if cmd.Name == "PING" || cmd.Name == "REPLCONF" {
+		return nil
+	}
+	if cmd.Name == "MULTI" {
+		a.queued = []protocol.Message{cmd.Message}
+		a.queuedDatabase = a.database
+		a.keys = nil
+		return nil
+	}
+	if cmd.Name == "SELECT" && len(cmd.Args) > 0 {
+		a.database = string(cmd.Args[0])
+	}
+
+	keys, err := cmd.Keys()
+	if err != nil {
+		return err
+	}
+
+	if a.queued == nil {
+		return a.commit(ctx, msg, a.database, dbKeys{}.add(a.database, keys))
+	}
+
+	// the queued commands are kept after the stream moves on, so they can't be left on the wire.
+	m, err := message.Materialize(cmd.Message, -1)
+	if err != nil {
+		return err
+	}
+	a.queued = append(a.queued, m)
+	a.keys = a.keys.add(a.database, keys)
+	if cmd.Name != "EXEC" {
+		return nil
+	}
+
+	txn := message.Array(a.queued...)
+	queuedKeys := a.keys
+	a.queued, a.keys = nil, nil
+	return a.commit(ctx, &txn, a.queuedDatabase, queuedKeys)
+}
@@ -0,0 +1,208 @@
+package anarchoredis
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/awinterman/anarchoredis/protocol"
+	"github.com/awinterman/anarchoredis/protocol/message"
+	"github.com/awinterman/anarchoredis/txn/localstate"
+	"github.com/dgraph-io/badger/v4"
+	"gotest.tools/v3/assert"
+)
+
+// TestSession_Track tests which keys are locked and awaited through transactions. The keys of a request that fails
+// are not locked.
+func TestSession_Track(t *testing.T) {
+	queued := message.SimpleString("QUEUED")
+	ok := message.SimpleString("OK")
+	type step struct {
+		cmd   string
+		reply protocol.Message
+		lock  dbKeys
+		await dbKeys
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"no transaction", []step{
+			{"SET a 1", ok, dbKeys{"0": {"a"}}, nil},
+			{"GET a", message.BulkBytes([]byte("1")), nil, dbKeys{"0": {"a"}}},
+		}},
+		{"exec", []step{
+			{"WATCH w", ok, nil, dbKeys{"0": {"w"}}},
+			{"MULTI", ok, nil, nil},
+			{"SET a 1", queued, nil, nil},
+			{"GET b", queued, nil, nil},
+			{"MSET c 1 d 2", queued, nil, nil},
+			{"EXEC", message.Array(ok, message.NullBulkString(), ok), dbKeys{"0": {"a", "c", "d"}}, dbKeys{"0": {"b"}}},
+			{"SET e 1", ok, dbKeys{"0": {"e"}}, nil},
+		}},
+		{"discard", []step{
+			{"MULTI", ok, nil, nil},
+			{"SET a 1", queued, nil, nil},
+			{"DISCARD", ok, nil, nil},
+			{"SET b 1", ok, dbKeys{"0": {"b"}}, nil},
+		}},
+		{"aborted by watch", []step{
+			{"WATCH a", ok, nil, dbKeys{"0": {"a"}}},
+			{"MULTI", ok, nil, nil},
+			{"SET a 1", queued, nil, nil},
+			{"EXEC", message.NullArray(), nil, nil},
+		}},
+		{"aborted by error", []step{
+			{"MULTI", ok, nil, nil},
+			{"SET a 1", queued, nil, nil},
+			{"SET b", message.Error("ERR wrong number of arguments for 'set' command"), nil, nil},
+			{"EXEC", message.Error("EXECABORT Transaction discarded because of previous errors."), nil, nil},
+		}},
+		{"select", []step{
+			{"SELECT 2", ok, nil, nil},
+			{"SET a 1", ok, dbKeys{"2": {"a"}}, nil},
+			{"SELECT 99", message.Error("ERR DB index is out of range"), nil, nil},
+			{"GET a", message.BulkBytes([]byte("1")), nil, dbKeys{"2": {"a"}}},
+			{"RESET", message.SimpleString("RESET"), nil, nil},
+			{"SET a 1", ok, dbKeys{"0": {"a"}}, nil},
+		}},
+		{"select in transaction", []step{
+			{"MULTI", ok, nil, nil},
+			{"SET a 1", queued, nil, nil},
+			{"SELECT 1", queued, nil, nil},
+			{"SET b 1", queued, nil, nil},
+			{"EXEC", message.Array(ok, ok, ok), dbKeys{"0": {"a"}, "1": {"b"}}, nil},
+			{"SET c 1", ok, dbKeys{"1": {"c"}}, nil},
+		}},
+		{"select in discarded transaction", []step{
+			{"MULTI", ok, nil, nil},
+			{"SELECT 1", queued, nil, nil},
+			{"DISCARD", ok, nil, nil},
+			{"SET a 1", ok, dbKeys{"0": {"a"}}, nil},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &session{database: "0"}
+			for _, step := range test.steps {
+				msg := protocol.NewOutgoingCommand(strings.Fields(step.cmd)...)
+				req := newRequest(protocol.Frame{Message: msg})
+				assert.NilError(t, req.err)
+				s.track(&req)
+				lock := req.lock
+				if s.settle(&req, &step.reply) {
+					lock = nil
+				}
+				assert.DeepEqual(t, lock, step.lock)
+				assert.DeepEqual(t, req.await, step.await)
+			}
+		})
+	}
+}
+
+// TestSession_Pinned tests which commands pin a session to its upstream connection, and which release it.
+func TestSession_Pinned(t *testing.T) {
+	ok := message.SimpleString("OK")
+	tests := []struct {
+		name   string
+		cmds   []string
+		pinned bool
+	}{
+		{"nothing", []string{"GET a", "SET a 1"}, false},
+		{"select", []string{"SELECT 1"}, true},
+		{"select back", []string{"SELECT 1", "SELECT 0"}, false},
+		{"multi", []string{"MULTI"}, true},
+		{"exec", []string{"MULTI", "EXEC"}, false},
+		{"watch", []string{"WATCH a"}, true},
+		{"unwatch", []string{"WATCH a", "UNWATCH"}, false},
+		{"watch exec", []string{"WATCH a", "MULTI", "EXEC"}, false},
+		{"client setname", []string{"CLIENT SETNAME x"}, true},
+		{"hello", []string{"HELLO 3"}, true},
+		{"reset", []string{"SELECT 1", "CLIENT SETNAME x", "WATCH a", "RESET"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &session{database: "0"}
+			for _, cmd := range test.cmds {
+				req := newRequest(protocol.Frame{Message: protocol.NewOutgoingCommand(strings.Fields(cmd)...)})
+				assert.NilError(t, req.err)
+				s.track(&req)
+				s.settle(&req, &ok)
+			}
+			assert.Equal(t, s.pinned(), test.pinned)
+		})
+	}
+}
+
+// appendLog records what is appended to it, prefixed by the database.
+type appendLog struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *appendLog) Append(ctx context.Context, msg *protocol.Message, database string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, database+" "+msg.String())
+	return nil
+}
+
+// appended returns a copy of the entries, which can be read while more are appended.
+func (l *appendLog) appended() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.entries)
+}
+
+// TestApplier tests that transactions in the replication stream are appended as one entry, and release their keys
+// only once EXEC is seen.
+func TestApplier(t *testing.T) {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	assert.NilError(t, err)
+	defer db.Close()
+
+	txnlog := &appendLog{}
+	transactor := &Transactor{
+		keys:   &localstate.Store{DB: db, Log: slog.Default()},
+		txnlog: txnlog,
+	}
+	a := &applier{Transactor: transactor, database: "0"}
+
+	_, err = transactor.keys.LockKeys("s", "0", []string{"a", "b"})
+	assert.NilError(t, err)
+	_, err = transactor.keys.LockKeys("s", "1", []string{"c"})
+	assert.NilError(t, err)
+
+	ctx := context.Background()
+	for _, cmd := range []string{"MULTI", "SET a 1", "INCR b"} {
+		assert.NilError(t, a.apply(ctx, protocol.NewOutgoingCommand(strings.Fields(cmd)...)))
+	}
+	assert.Equal(t, len(txnlog.entries), 0)
+
+	assert.NilError(t, a.apply(ctx, protocol.NewOutgoingCommand("EXEC")))
+	assert.NilError(t, a.apply(ctx, protocol.NewOutgoingCommand("PING")))
+	assert.NilError(t, a.apply(ctx, protocol.NewOutgoingCommand("REPLCONF", "GETACK", "*")))
+	assert.NilError(t, a.apply(ctx, protocol.NewOutgoingCommand("SELECT", "1")))
+	assert.NilError(t, a.apply(ctx, protocol.NewOutgoingCommand("SET", "c", "1")))
+	assert.DeepEqual(t, txnlog.entries, []string{
+		"0 " + protocol.NewArray(
+			protocol.NewOutgoingCommand("MULTI"),
+			protocol.NewOutgoingCommand("SET", "a", "1"),
+			protocol.NewOutgoingCommand("INCR", "b"),
+			protocol.NewOutgoingCommand("EXEC"),
+		).String(),
+		"1 " + protocol.NewOutgoingCommand("SELECT", "1").String(),
+		"1 " + protocol.NewOutgoingCommand("SET", "c", "1").String(),
+	})
+
+	_, err = transactor.keys.LockKeys("s", "0", []string{"a", "b"})
+	assert.NilError(t, err, "the keys have been released")
+	_, err = transactor.keys.LockKeys("s", "1", []string{"c"})
+	assert.NilError(t, err, "the keys have been released")
+}
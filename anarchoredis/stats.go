@@ -0,0 +1,80 @@
+package anarchoredis
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/awinterman/anarchoredis/txn/replication"
+)
+
+// WaitBuckets are the upper bounds of the buckets that WaitStats counts waits in by how long they took.
+var WaitBuckets = [...]time.Duration{
+	time.Millisecond, 10 * time.Millisecond, 100 * time.Millisecond, time.Second, 10 * time.Second,
+}
+
+// WaitStats is a snapshot of the waits of client commands on key locks of one kind.
+type WaitStats struct {
+	// Waiting is the number of commands waiting now.
+	Waiting int64
+	// Count is the number of waits that are over, and Total how long they took altogether.
+	Count int64
+	Total time.Duration
+	// Buckets counts the waits that are over by how long they took: Buckets[i] counts those that took at most
+	// WaitBuckets[i], and more than the bound before it, and the last those that took longer than every bound.
+	Buckets [len(WaitBuckets) + 1]int64
+}
+
+// LockStats is a snapshot of the waits of client commands on key locks, when acknowledging by keys, and for writes to
+// be committed.
+type LockStats struct {
+	// Acquire are the waits of writes for the keys they lock to be released by the writes before them.
+	Acquire WaitStats
+	// Commit are the waits of commands for the writes before them, and of writes for themselves, to be committed.
+	Commit WaitStats
+}
+
+// waits counts the waits of one kind as they start and end.
+type waits struct {
+	waiting, count, total atomic.Int64
+	buckets               [len(WaitBuckets) + 1]atomic.Int64
+}
+
+// start counts a wait that starts now, and returns the function that counts it as over.
+func (w *waits) start() func() {
+	started := time.Now()
+	w.waiting.Add(1)
+	return func() {
+		took := time.Since(started)
+		bucket := 0
+		for bucket < len(WaitBuckets) && took > WaitBuckets[bucket] {
+			bucket++
+		}
+		w.buckets[bucket].Add(1)
+		w.total.Add(int64(took))
+		w.count.Add(1)
+		w.waiting.Add(-1)
+	}
+}
+
+func (w *waits) stats() WaitStats {
+	st := WaitStats{Waiting: w.waiting.Load(), Count: w.count.Load(), Total: time.Duration(w.total.Load())}
+	for i := range w.buckets {
+		st.Buckets[i] = w.buckets[i].Load()
+	}
+	return st
+}
+
+// LockStats returns how many client commands wait on key locks, and how long they have waited.
+func (t *Transactor) LockStats() LockStats {
+	return LockStats{Acquire: t.acquireWaits.stats(), Commit: t.commitWaits.stats()}
+}
+
+// ReplicationStats returns how far the transaction log is behind the upstream server, and how fast the replication
+// stream is read, while Replicate runs, or false if it doesn't.
+func (t *Transactor) ReplicationStats() (replication.Stats, bool) {
+	s := t.subscriber.Load()
+	if s == nil {
+		return replication.Stats{}, false
+	}
+	return s.Stats(), true
+}
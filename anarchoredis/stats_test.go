@@ -0,0 +1,29 @@
+package anarchoredis
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// TestWaits tests that waits are counted while they last, then by how long they took.
+func TestWaits(t *testing.T) {
+	var w waits
+	done := w.start()
+	assert.Equal(t, w.stats().Waiting, int64(1))
+	time.Sleep(2 * time.Millisecond)
+	done()
+	w.start()()
+
+	st := w.stats()
+	assert.Equal(t, st.Waiting, int64(0))
+	assert.Equal(t, st.Count, int64(2))
+	assert.Assert(t, st.Total >= 2*time.Millisecond)
+	assert.Equal(t, st.Buckets[0], int64(1), "the wait that took no time")
+	var total int64
+	for _, n := range st.Buckets {
+		total += n
+	}
+	assert.Equal(t, total, int64(2))
+}
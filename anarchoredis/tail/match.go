@@ -0,0 +1,82 @@
+package tail
+
+// match reports whether s matches the glob pattern, as KEYS matches keys: * matches any string, ? any byte, and [...]
+// any byte of a class, which may be negated with ^ and include ranges such as a-z. \ escapes the byte that follows
+// it.
+//
+// Like stringmatchlen in redis, a mismatch after a * backtracks to that * alone, matching one more byte of s with it,
+// so that patterns with many stars take time linear in them rather than exponential.
+func match(pattern, s string) bool {
+	// star is the pattern just past the last *, and next what is left of s once it has matched one more byte.
+	var star, next string
+	starred := false
+	for len(pattern) > 0 || len(s) > 0 {
+		if len(pattern) > 0 && pattern[0] == '*' {
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			star, next, starred = pattern, s, true
+			continue
+		}
+		if len(pattern) > 0 && len(s) > 0 {
+			switch pattern[0] {
+			case '?':
+				pattern, s = pattern[1:], s[1:]
+				continue
+			case '[':
+				ok, rest := matchClass(pattern[1:], s[0])
+				if ok {
+					pattern, s = rest, s[1:]
+					continue
+				}
+			default:
+				literal := pattern
+				if literal[0] == '\\' && len(literal) > 1 {
+					literal = literal[1:]
+				}
+				if s[0] == literal[0] {
+					pattern, s = literal[1:], s[1:]
+					continue
+				}
+			}
+		}
+		// on a mismatch, the last * matches one more byte, if it can.
+		if !starred || len(next) == 0 {
+			return false
+		}
+		next = next[1:]
+		pattern, s = star, next
+	}
+	return true
+}
+
+// matchClass reports whether c is in the class that pattern starts with, just past its [, and returns what follows
+// the class. A class that isn't closed runs to the end of the pattern.
+func matchClass(pattern string, c byte) (bool, string) {
+	negated := len(pattern) > 0 && pattern[0] == '^'
+	if negated {
+		pattern = pattern[1:]
+	}
+	matched := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) > 1:
+			matched = matched || pattern[1] == c
+			pattern = pattern[2:]
+		case len(pattern) >= 3 && pattern[1] == '-':
+			lo, hi := min(pattern[0], pattern[2]), max(pattern[0], pattern[2])
+			matched = matched || (c >= lo && c <= hi)
+			pattern = pattern[3:]
+		default:
+			matched = matched || pattern[0] == c
+			pattern = pattern[1:]
+		}
+	}
+	if len(pattern) > 0 {
+		pattern = pattern[1:]
+	}
+	return matched != negated, pattern
+}
@@ -0,0 +1,162 @@
+// Copyright 2025 Outreach Corporation. All Rights Reserved.
+
+// Description:
+
+// Package tail prints the replication stream of a redis server as a replica receives it, to see exactly what the
+// server propagates.
+package tail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/awinterman/anarchoredis/protocol"
+	"github.com/awinterman/anarchoredis/protocol/message"
+	"github.com/awinterman/anarchoredis/txn/replication"
+)
+
+// Format is how a Printer prints commands.
+type Format string
+
+const (
+	// Human prints a line for each command with its offset, database and keys, followed by the command, whose
+	// arguments are quoted if they need to be. The start of each stream is printed as a comment.
+	Human Format = "human"
+	// RESP prints commands as they are propagated, e.g. to pipe them to redis-cli --pipe.
+	RESP Format = "resp"
+	// JSON prints a JSON object for each command on a line, see Entry.
+	JSON Format = "json"
+)
+
+// Entry is a command as it is printed in JSON.
+type Entry struct {
+	// Offset is the replication offset just past the command.
+	Offset   int64    `json:"offset"`
+	Database string   `json:"db"`
+	Command  string   `json:"command"`
+	Args     []string `json:"args"`
+	Keys     []string `json:"keys"`
+}
+
+// Filter selects the commands printed. Each of its fields that is empty selects every command.
+type Filter struct {
+	// Commands are the names of the commands printed, in any case. A command with a subcommand, such as CONFIG SET,
+	// is selected by its name alone or with its subcommand.
+	Commands []string
+	// KeyPattern is a glob pattern, as KEYS takes, that a key of the command must match. Commands whose keys are
+	// unknown match no pattern.
+	KeyPattern string
+	// Databases are the databases of the commands printed.
+	Databases []string
+}
+
+func (f Filter) match(cmd *protocol.Command, keys []string, database string) bool {
+	if len(f.Commands) > 0 && !slices.ContainsFunc(f.Commands, func(name string) bool {
+		container, _, _ := strings.Cut(cmd.Name, " ")
+		return strings.EqualFold(name, cmd.Name) || strings.EqualFold(name, container)
+	}) {
+		return false
+	}
+	if f.KeyPattern != "" && !slices.ContainsFunc(keys, func(key string) bool {
+		return match(f.KeyPattern, key)
+	}) {
+		return false
+	}
+	return len(f.Databases) == 0 || slices.Contains(f.Databases, database)
+}
+
+// Printer prints the commands of a replication stream that its Filter selects to W.
+type Printer struct {
+	W      io.Writer
+	Format Format
+	Filter Filter
+
+	// database is the database the stream is in, which SELECT changes.
+	database string
+}
+
+// Tail follows the leader of s, printing its stream with p, until ctx is done or printing fails. The snapshot sent
+// on a full resync is skipped.
+func Tail(ctx context.Context, s *replication.Subscriber, p *Printer) error {
+	s.OnSync = func(full bool, database string) {
+		p.Sync(full, database, *s.ReplicationID.Load(), s.Offset.Load())
+	}
+	return s.Follow(ctx, p.Print)
+}
+
+// Sync starts a stream in database, printing where it starts from in the Human format.
+func (p *Printer) Sync(full bool, database, replicationID string, offset int64) {
+	p.database = database
+	if p.Format != Human {
+		return
+	}
+	start := "continuing"
+	if full {
+		start = "full resync"
+	}
+	_, _ = fmt.Fprintf(p.W, "# %s from %s at offset %d in db %s\n", start, replicationID, offset, database)
+}
+
+// Print prints msg, which ends at offset, if the Filter selects it.
+func (p *Printer) Print(msg *protocol.Message, offset int64) error {
+	cmd, err := protocol.Cmd(*msg)
+	if err != nil {
+		return err
+	}
+	if cmd.Name == "SELECT" && len(cmd.Args) > 0 {
+		p.database = string(cmd.Args[0])
+	}
+	// the keys of commands that aren't known can't be found.
+	keys, _ := cmd.Keys()
+	if !p.Filter.match(cmd, keys, p.database) {
+		return nil
+	}
+
+	switch p.Format {
+	case RESP:
+		_, err = (&message.Encoder{}).Encode(cmd.Message, p.W)
+		return err
+	case JSON:
+		entry := Entry{Offset: offset, Database: p.database, Command: cmd.Name, Args: []string{}, Keys: []string{}}
+		for _, arg := range cmd.Args {
+			entry.Args = append(entry.Args, string(arg))
+		}
+		entry.Keys = append(entry.Keys, keys...)
+		return json.NewEncoder(p.W).Encode(entry)
+	}
+
+	var line strings.Builder
+	fmt.Fprintf(&line, "%d db=%s keys=[", offset, p.database)
+	for i, key := range keys {
+		if i > 0 {
+			line.WriteByte(' ')
+		}
+		line.WriteString(quote(key))
+	}
+	line.WriteString("] ")
+	line.WriteString(cmd.Name)
+	for _, arg := range cmd.Args {
+		line.WriteByte(' ')
+		line.WriteString(quote(string(arg)))
+	}
+	line.WriteByte('\n')
+	_, err = io.WriteString(p.W, line.String())
+	return err
+}
+
+// quote quotes s if it is empty, or has spaces, quotes or characters that aren't printable.
+func quote(s string) string {
+	if s != "" && utf8.ValidString(s) && !strings.ContainsFunc(s, func(r rune) bool {
+		return r == '"' || r == '\\' || unicode.IsSpace(r) || !unicode.IsPrint(r)
+	}) {
+		return s
+	}
+	return strconv.Quote(s)
+}
@@ -0,0 +1,170 @@
+package tail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awinterman/anarchoredis/protocol"
+	"github.com/awinterman/anarchoredis/protocol/message"
+	"github.com/awinterman/anarchoredis/txn/redistest"
+	"github.com/awinterman/anarchoredis/txn/replication"
+	"gotest.tools/v3/assert"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		match      bool
+	}{
+		{"", "", true},
+		{"", "a", false},
+		{"*", "", true},
+		{"user:*", "user:1", true},
+		{"user:*", "users:1", false},
+		{"*:1", "user:1", true},
+		{"a**b", "axxb", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[b-a]llo", "hbllo", true},
+		{"h[a-b]llo", "hcllo", false},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{`[\]]`, "]", true},
+		{"a/*", "a/b/c", true},
+		{"[abc", "b", true},
+		{"*a*b*c", "xaybzc", true},
+		{"*a*b*c", "xaybz", false},
+		{"a*", "a", true},
+		{"*?", "", false},
+		{`\`, `\`, true},
+		{strings.Repeat("*a", 30) + "b", strings.Repeat("a", 60), false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, match(test.pattern, test.s), test.match, "%q %q", test.pattern, test.s)
+	}
+}
+
+// stream is a replication stream, with the offset just past each command.
+var stream = []struct {
+	args   []string
+	offset int64
+}{
+	{[]string{"SET", "user:1", "hello world"}, 10},
+	{[]string{"SELECT", "1"}, 20},
+	{[]string{"DEL", "user:2", "order:1"}, 30},
+	{[]string{"CONFIG", "SET", "maxmemory", "1"}, 40},
+	{[]string{"MODULE.COMMAND", "x"}, 50},
+}
+
+// TestPrinter tests each format, and each filter.
+func TestPrinter(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		filter Filter
+		want   string
+	}{
+		{"human", Human, Filter{}, `# full resync from abc at offset 0 in db 0
+10 db=0 keys=[user:1] SET user:1 "hello world"
+20 db=1 keys=[] SELECT 1
+30 db=1 keys=[user:2 order:1] DEL user:2 order:1
+40 db=1 keys=[] CONFIG SET maxmemory 1
+50 db=1 keys=[] MODULE.COMMAND x
+`},
+		{"commands", Human, Filter{Commands: []string{"del", "config"}}, `# full resync from abc at offset 0 in db 0
+30 db=1 keys=[user:2 order:1] DEL user:2 order:1
+40 db=1 keys=[] CONFIG SET maxmemory 1
+`},
+		{"subcommand", Human, Filter{Commands: []string{"Config Get"}}, "# full resync from abc at offset 0 in db 0\n"},
+		{"keys", Human, Filter{KeyPattern: "order:*"}, `# full resync from abc at offset 0 in db 0
+30 db=1 keys=[user:2 order:1] DEL user:2 order:1
+`},
+		{"databases", Human, Filter{Databases: []string{"0"}}, `# full resync from abc at offset 0 in db 0
+10 db=0 keys=[user:1] SET user:1 "hello world"
+`},
+		{"json", JSON, Filter{Commands: []string{"SET", "CONFIG"}}, `{"offset":10,"db":"0","command":"SET","args":["user:1","hello world"],"keys":["user:1"]}
+{"offset":40,"db":"1","command":"CONFIG SET","args":["maxmemory","1"],"keys":[]}
+`},
+		{"resp", RESP, Filter{Databases: []string{"1"}}, "*2\r\n$6\r\nSELECT\r\n$1\r\n1\r\n" +
+			"*3\r\n$3\r\nDEL\r\n$6\r\nuser:2\r\n$7\r\norder:1\r\n" +
+			"*4\r\n$6\r\nCONFIG\r\n$3\r\nSET\r\n$9\r\nmaxmemory\r\n$1\r\n1\r\n" +
+			"*2\r\n$14\r\nMODULE.COMMAND\r\n$1\r\nx\r\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			p := &Printer{W: &out, Format: test.format, Filter: test.filter}
+			p.Sync(true, "0", "abc", 0)
+			for _, cmd := range stream {
+				assert.NilError(t, p.Print(protocol.NewOutgoingCommand(cmd.args...), cmd.offset))
+			}
+			assert.Equal(t, out.String(), test.want)
+		})
+	}
+
+	p := &Printer{W: &bytes.Buffer{}, Format: Human}
+	reply := message.SimpleString("OK")
+	err := p.Print(&reply, 0)
+	assert.ErrorIs(t, err, protocol.ErrInvalidCommand)
+}
+
+// TestTail tails a fake leader.
+func TestTail(t *testing.T) {
+	leader := redistest.NewLeader(t)
+	leader.Do(0, "SET", "a", "1")
+
+	out := &syncBuffer{}
+	s := &replication.Subscriber{LeaderAddr: leader.Addr(), MyAddr: "127.0.0.1:0", Logger: slog.Default()}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Tail(ctx, s, &Printer{W: out, Format: Human})
+	}()
+
+	// waitFor waits for the output to be want.
+	waitFor := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for out.String() != want && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		assert.Equal(t, out.String(), want)
+	}
+	offset := leader.Offset()
+	synced := fmt.Sprintf("# full resync from %s at offset %d in db 0\n", leader.ReplicationID(), offset)
+	waitFor(synced)
+	leader.Do(2, "SET", "b", "2")
+	selected := offset + int64(len("*2\r\n$6\r\nSELECT\r\n$1\r\n2\r\n"))
+	waitFor(synced + fmt.Sprintf("%d db=2 keys=[] SELECT 2\n%d db=2 keys=[b] SET b 2\n", selected, leader.Offset()))
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+// syncBuffer is a strings.Builder that can be read while it is written.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  strings.Builder
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
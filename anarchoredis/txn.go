@@ -7,10 +7,13 @@ package anarchoredis
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -26,6 +29,10 @@ import (
 type Conf struct {
 	ListenAddress string
 	RedisAddress  string
+	// SentinelAddresses, if set, are the addresses of Redis Sentinels that monitor the upstream server as
+	// SentinelMaster. They are asked for its address in place of RedisAddress, so that a failover is followed.
+	SentinelAddresses []string
+	SentinelMaster    string
 
 	KafkaAddress []string
 	Topic        string
@@ -34,7 +41,27 @@ type Conf struct {
 	GroupID  string
 
 	LocalStateDir string
-	LockTTL       time.Duration
+	// LockTTL is how long the keys of a write stay locked if they are never released, e.g. when the proxy holding them
+	// stops, DefaultLockTTL if zero.
+	LockTTL time.Duration
+	// LockStore is where the keys of writes are locked, LockBadger if empty.
+	LockStore LockStore
+
+	// AckMode is how writes are known to be committed, AckKeys if empty.
+	AckMode AckMode
+
+	// PoolSize is the most connections to the upstream server shared by client connections, or unbounded if zero.
+	PoolSize int
+	// PoolIdleTimeout is how long a pooled connection is kept unused before it is closed, or forever if zero.
+	PoolIdleTimeout time.Duration
+
+	// DiscoverCommands says whether to ask the upstream server which commands it supports on startup, so that keys
+	// are found for module commands and those of newer servers.
+	DiscoverCommands bool
+
+	// LeaderPollInterval is how often the upstream server is asked for its replication offset while replicating, so
+	// that the lag of the transaction log behind it is known, or never if zero.
+	LeaderPollInterval time.Duration
 
 	net.Dialer
 }
@@ -42,13 +69,53 @@ type Conf struct {
 func (conf *Conf) LoadEnv() {
 	conf.ListenAddress = os.Getenv("LISTEN_ADDRESS")
 	conf.RedisAddress = os.Getenv("REDIS_ADDRESS")
+	if sentinels := os.Getenv("SENTINEL_ADDRESS"); sentinels != "" {
+		conf.SentinelAddresses = strings.Split(sentinels, ",")
+	}
+	conf.SentinelMaster = os.Getenv("SENTINEL_MASTER")
 	conf.KafkaAddress = strings.Split(os.Getenv("KAFKA_ADDRESS"), ",")
 	conf.ClientID = os.Getenv("CLIENT_ID")
 	conf.GroupID = os.Getenv("GROUP_ID")
 	conf.Topic = os.Getenv("TXN_TOPIC")
+	conf.DiscoverCommands, _ = strconv.ParseBool(os.Getenv("DISCOVER_COMMANDS"))
+	conf.AckMode = AckMode(os.Getenv("ACK_MODE"))
+	conf.PoolSize, _ = strconv.Atoi(os.Getenv("POOL_SIZE"))
+	conf.PoolIdleTimeout, _ = time.ParseDuration(os.Getenv("POOL_IDLE_TIMEOUT"))
+	conf.LockTTL, _ = time.ParseDuration(os.Getenv("LOCK_TTL"))
+	conf.LockStore = LockStore(os.Getenv("LOCK_STORE"))
+	conf.LeaderPollInterval, _ = time.ParseDuration(os.Getenv("LEADER_POLL_INTERVAL"))
 	slog.Info("env loaded", "conf", conf)
 }
 
+// DefaultLockTTL is how long the keys of a write stay locked if they are never released, unless Conf.LockTTL is set.
+const DefaultLockTTL = 30 * time.Second
+
+// AckMode is how the Transactor knows that a write has been committed to the transaction log, so that the reply to
+// it can be sent.
+type AckMode string
+
+const (
+	// AckKeys locks the keys of a write before it is forwarded, until the replication stream has been committed up to
+	// the offset of the upstream server once it has replied, since a write that changes nothing isn't replicated.
+	// Writes to the same keys wait on each other to be forwarded, and reads wait on the writes before them.
+	AckKeys AckMode = "keys"
+	// AckOffset asks the upstream server for its replication offset once it has replied, and waits until the
+	// replication stream has been committed up to that offset. Every command waits on every write before it, but the
+	// wrong waiter is never released when two clients write the same key.
+	AckOffset AckMode = "offset"
+)
+
+// LockStore is where the Transactor keeps the locks on the keys of writes, when acknowledging by keys.
+type LockStore string
+
+const (
+	// LockBadger keeps locks in a Badger DB in LocalStateDir, or in memory if it is empty.
+	LockBadger LockStore = "badger"
+	// LockMemory keeps locks in memory, in shards with a queue of waiters for each lock, which is much lighter than
+	// Badger, but loses them if the process restarts.
+	LockMemory LockStore = "memory"
+)
+
 // Transactor is an bastraction around a redis connection that waits to acknowledge writes until they have been
 // persisted to an external datastructure, meaning not just written to an AOF file (with fsync or no), but also sent
 // to e.g. a raft cluster.
@@ -56,171 +123,482 @@ func (conf *Conf) LoadEnv() {
 // It uses a localstore to keep track of which keys are pending,
 // and delays writes and reads to those keys until they have been committed to the distributed log.
 type Transactor struct {
-	conf                       *Conf
-	keys                       *localstate.Store
-	redisReplicationSubscriber *replication.Subscriber
-	txnlog                     TxnLog
-	database                   *atomic.Pointer[string]
+	conf      *Conf
+	keys      localstate.LockManager
+	txnlog    TxnLog
+	upstreams *upstreamPool
+	ack       AckMode
+
+	// leader asks for the replication offset of the upstream server on a connection from offsets, when acknowledging
+	// by offset. offsets are apart from upstreams so that a session holding an upstream connection can always get one.
+	leader  *replication.Subscriber
+	offsets *upstreamPool
+
+	// replicating says whether Replicate is running, and committed is the offset of the replication stream it has
+	// committed to the transaction log.
+	replicating atomic.Bool
+	committed   watermark
+	// subscriber follows the replication stream while Replicate runs.
+	subscriber atomic.Pointer[replication.Subscriber]
+
+	// acquireWaits and commitWaits count the waits of client commands on key locks, and for writes to be committed.
+	acquireWaits, commitWaits waits
+
+	// writing is the number of writes forwarded upstream that have not been acknowledged, which a command that must
+	// not be reordered with any other waits on, see protocol.Command.IsSerializing.
+	writing atomic.Int64
+	// serials counts the serializing commands forwarded upstream, and serialsDone those released since, up to which
+	// serialized is advanced. A write waits for serialized to reach the count of those forwarded before it, so that
+	// none is forwarded while a serializing command is in flight.
+	serials, serialsDone atomic.Int64
+	serialized           watermark
+
+	// id and sessions name the owners of the key locks taken by client sessions.
+	id       string
+	sessions atomic.Uint64
 }
 
+// TxnLog is the log that writes are committed to before they are acknowledged.
 type TxnLog interface {
+	// Append commits msg, which is either a command, or a transaction: an array of commands from MULTI to EXEC
+	// inclusive, that must be applied atomically, executed in database. A snapshot of the upstream server is
+	// committed as CONTROL records with no database, see Transactor.appendSnapshot.
 	Append(ctx context.Context, msg *protocol.Message, database string) error
 }
 
 func NewSubscriber(conf *Conf) *replication.Subscriber {
-	return &replication.Subscriber{
+	s := &replication.Subscriber{
+		Dialer:       conf.Dialer,
+		LeaderAddr:   conf.RedisAddress,
+		MyAddr:       conf.ListenAddress,
+		Logger:       slog.With("comp", "replication"),
+		Sentinel:     NewSentinel(conf),
+		PollInterval: conf.LeaderPollInterval,
+	}
+	if conf.LocalStateDir != "" {
+		s.StatePath = filepath.Join(conf.LocalStateDir, "replication")
+	}
+	return s
+}
+
+// NewSentinel returns the sentinels that find the upstream server, or nil if it is at RedisAddress.
+func NewSentinel(conf *Conf) *replication.Sentinel {
+	if len(conf.SentinelAddresses) == 0 {
+		return nil
+	}
+	return &replication.Sentinel{
+		Addrs:      conf.SentinelAddresses,
+		MasterName: conf.SentinelMaster,
 		Dialer:     conf.Dialer,
-		LeaderAddr: conf.RedisAddress,
-		MyAddr:     conf.ListenAddress,
-		Logger:     slog.With("comp", "replication"),
+		Logger:     slog.With("comp", "sentinel"),
 	}
 }
 
 func NewTransactor(ctx context.Context, conf *Conf, transactionLog TxnLog) (*Transactor, error) {
-	db, err := badger.Open(badger.DefaultOptions(conf.LocalStateDir).WithInMemory(conf.LocalStateDir == ""))
+	keys, err := newLockManager(conf)
 	if err != nil {
-		return nil, fmt.Errorf("badgerdb.Open(): %w", err)
+		return nil, err
 	}
 
-	transactor := Transactor{
-		conf,
-		&localstate.Store{DB: db, Log: slog.With("comp", "key-lock")},
-		&replication.Subscriber{
-			Dialer:     conf.Dialer,
-			LeaderAddr: conf.RedisAddress,
-			MyAddr:     conf.ListenAddress,
-			Logger:     slog.With("comp", "replication"),
-		},
-		transactionLog,
-		&atomic.Pointer[string]{},
+	sentinel := NewSentinel(conf)
+	dial := func(ctx context.Context) (net.Conn, error) {
+		addr := conf.RedisAddress
+		if sentinel != nil {
+			var err error
+			addr, err = sentinel.Leader(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		d, err := conf.Dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("could not dial upstream address %q: %w", addr, err)
+		}
+		slog.Info("established upstream connection", "addr", d.LocalAddr())
+		return d, nil
+	}
+	transactor := &Transactor{
+		conf:      conf,
+		keys:      keys,
+		txnlog:    transactionLog,
+		upstreams: newUpstreamPool(conf.PoolSize, conf.PoolIdleTimeout, dial),
+		ack:       conf.AckMode,
+		leader:    NewSubscriber(conf),
+		offsets:   newUpstreamPool(conf.PoolSize, conf.PoolIdleTimeout, dial),
+		id:        strconv.FormatInt(time.Now().UnixNano(), 36),
+	}
+	switch transactor.ack {
+	case "":
+		transactor.ack = AckKeys
+	case AckKeys, AckOffset:
+	default:
+		return nil, fmt.Errorf("unknown ack mode %q", conf.AckMode)
 	}
-	database := "0"
-	transactor.database.Store(&database)
 
-	return &transactor, nil
+	if conf.DiscoverCommands {
+		err := discoverCommands(ctx, dial)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return transactor, nil
+}
+
+// newLockManager returns the lock manager for conf.LockStore.
+func newLockManager(conf *Conf) (localstate.LockManager, error) {
+	ttl := conf.LockTTL
+	if ttl == 0 {
+		ttl = DefaultLockTTL
+	}
+	switch conf.LockStore {
+	case "", LockBadger:
+		db, err := badger.Open(badger.DefaultOptions(conf.LocalStateDir).WithInMemory(conf.LocalStateDir == ""))
+		if err != nil {
+			return nil, fmt.Errorf("badgerdb.Open(): %w", err)
+		}
+		return &localstate.Store{DB: db, Log: slog.With("comp", "key-lock"), LockTTL: ttl}, nil
+	case LockMemory:
+		return &localstate.Memory{LockTTL: ttl}, nil
+	default:
+		return nil, fmt.Errorf("unknown lock store %q", conf.LockStore)
+	}
 }
 
+// discoverCommands registers the specification of every command supported by the upstream server.
+func discoverCommands(ctx context.Context, dial func(ctx context.Context) (net.Conn, error)) error {
+	d, err := dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	specs, err := protocol.Discover(protocol.NewConnection(d))
+	if err != nil {
+		return fmt.Errorf("discovering commands: %w", err)
+	}
+	protocol.Register(specs)
+	slog.Info("discovered commands", "count", len(specs))
+	return nil
+}
+
+// Transact proxies a client connection until it is closed or ctx is done. Its writes are acknowledged once Replicate
+// has committed them.
 func (t *Transactor) Transact(ctx context.Context, conn net.Conn) error {
-	connection := protocol.NewConnection(conn)
+	return t.proxy(ctx, t.newSession(conn))
+}
 
-	d, err := t.conf.Dialer.DialContext(ctx, "tcp", t.conf.RedisAddress)
+// newSession returns the session of a new client connection.
+func (t *Transactor) newSession(conn net.Conn) *session {
+	return &session{
+		connection: protocol.NewConnection(conn),
+		upstreams:  t.upstreams,
+		closer:     conn,
+		owner:      t.id + "/" + strconv.FormatUint(t.sessions.Add(1), 10),
+		database:   "0",
+	}
+}
+
+// commit appends msg, executed in database, to the transaction log, and releases the keys it wrote, whoever locked
+// them.
+func (t *Transactor) commit(ctx context.Context, msg *protocol.Message, database string, keys dbKeys) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 
+	err := t.txnlog.Append(ctx, msg, database)
 	if err != nil {
-		return fmt.Errorf("could not dial upstream address %q: %w", t.conf.RedisAddress, err)
+		return err
+	}
+
+	for database, keys := range keys {
+		err = t.keys.ReleaseKeys(database, keys)
+		if err != nil {
+			return err
+		}
 	}
-	slog.Info("established upstream connection", "addr", d.LocalAddr(), "error", err)
-	upstream := protocol.NewConnection(d)
 
-	g := errgroup.Group{}
+	return context.Cause(ctx)
+}
+
+// maxPipeline is the number of requests a client can have forwarded upstream before their replies are sent.
+const maxPipeline = 1024
 
-	g.Go(func() error {
-		return t.redisReplicationSubscriber.StreamUpdates(
-			ctx,
-			func(msg *protocol.Message) error {
-				return t.handleMessages(msg, ctx)
+// proxy forwards requests from the client to the upstream server verbatim, and forwards each reply back, in order,
+// once the keys touched by its request have been committed to the transaction log. Requests pipelined by the client
+// are forwarded upstream together while the replies to earlier requests are awaited.
+func (t *Transactor) proxy(ctx context.Context, s *session) error {
+	pending := make(chan request, maxPipeline)
+	g, ctx := errgroup.WithContext(ctx)
 
-			})
+	// when either side fails, stop reading from the client so the other returns.
+	stop := context.AfterFunc(ctx, func() {
+		if s.closer != nil {
+			_ = s.closer.Close()
+		}
 	})
+	defer stop()
+
 	g.Go(func() error {
+		defer close(pending)
 		for ctx.Err() == nil {
-			err2 := t.proxy(ctx, connection, upstream)
-			if err2 != nil {
-				return err2
+			err := t.forward(ctx, s, pending)
+			if err != nil {
+				return err
 			}
 		}
 		return context.Cause(ctx)
 	})
+	g.Go(func() error {
+		for req := range pending {
+			err := t.reply(ctx, s, req, len(pending) == 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 
-	return g.Wait()
-}
-
-// handleMessages processes a protocol message within a transaction and updates the transaction log and key state.
-func (t *Transactor) handleMessages(msg *protocol.Message, ctx context.Context) error {
-	ctx, cancel := context.WithCancelCause(ctx)
-	defer cancel(nil)
-	if ctx.Err() != nil {
-		return ctx.Err()
+	err := g.Wait()
+	for req := range pending {
+		t.release(&req)
 	}
+	s.hangUp()
+	return err
+}
 
-	cmd, err := msg.Cmd()
+// forward reads a request from the client and writes it upstream. The upstream is flushed once the client has no
+// more requests buffered, or before waiting on anything else.
+func (t *Transactor) forward(ctx context.Context, s *session, pending chan<- request) error {
+	frame, err := s.connection.ReadFrame()
 	if err != nil {
 		return err
 	}
-
-	keys, err := cmd.Keys()
-	if err != nil {
-		return err
+	req := newRequest(frame)
+	if req.err == nil {
+		req.upstream, err = s.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		s.track(&req)
 	}
 
-	err = t.txnlog.Append(ctx, msg, *t.database.Load())
-	if err != nil {
-		return err
+	// a serializing command waits until the writes in flight have been committed, as far as the upstream server has
+	// executed them, so that one blocked on a key doesn't hold it off.
+	if req.serializing && t.writing.Load() > 0 {
+		err = s.flush()
+		if err != nil {
+			return err
+		}
+		err = t.awaitCommitted(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	// and the writes after it wait until it has been acknowledged.
+	if req.write && !req.serializing && req.err == nil {
+		if serials := t.serials.Load(); t.serialized.load() < serials {
+			err = s.flush()
+			if err != nil {
+				return err
+			}
+			err = t.serialized.wait(ctx, serials)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if req.write && req.err == nil {
+		t.writing.Add(1)
 	}
+	if req.serializing && req.err == nil {
+		t.serials.Add(1)
+	}
+	sent := false
+	defer func() {
+		if !sent {
+			t.release(&req)
+		}
+	}()
 
-	err = t.keys.UnlockKeys(keys)
-	if err != nil {
-		return err
+	// a write waits for the keys it writes to be free, so that it is the only one in flight on them.
+	if t.ack != AckOffset && len(req.lock) > 0 {
+		err = s.flush()
+		if err != nil {
+			return err
+		}
+		err = t.lockKeys(ctx, s, &req)
+		if err != nil {
+			return err
+		}
 	}
 
-	return context.Cause(ctx)
-}
+	if req.err == nil {
+		_, err = req.upstream.WriteRaw(frame.Raw)
+		if err != nil {
+			return err
+		}
+	}
+	if s.connection.RW.Reader.Buffered() == 0 {
+		err = s.flush()
+		if err != nil {
+			return err
+		}
+	}
 
-func (t *Transactor) proxy(ctx context.Context, connection *protocol.Conn, upstream *protocol.Conn) error {
-	log := slog.With("comp", "proxy")
-	req, err := connection.Read()
+	select {
+	case pending <- req:
+		sent = true
+		return nil
+	default:
+	}
+	err = s.flush()
 	if err != nil {
 		return err
 	}
+	select {
+	case pending <- req:
+		sent = true
+		return nil
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
 
-	_, err = upstream.Write(req)
-	if err != nil {
-		return err
+// release unlocks what forward locked for req, including the key locks it still holds, e.g. because it failed, or was
+// never replied to.
+func (t *Transactor) release(req *request) {
+	if req.write && req.err == nil {
+		t.writing.Add(-1)
 	}
-	err = upstream.Flush()
-	if err != nil {
-		return err
+	if req.serializing && req.err == nil {
+		t.serialized.advance(t.serialsDone.Add(1))
+	}
+	t.unlockKeys(req)
+}
+
+// lockKeys takes the locks on the keys req writes for the session, waiting for them to be released by others.
+func (t *Transactor) lockKeys(ctx context.Context, s *session, req *request) error {
+	defer t.acquireWaits.start()()
+	req.held = map[string]localstate.Lock{}
+	for database, keys := range req.lock {
+		lock, err := t.keys.AcquireKeys(ctx, s.owner, database, keys)
+		if err != nil {
+			return err
+		}
+		req.held[database] = lock
 	}
+	return nil
+}
 
-	resp, err := upstream.Read()
-	if err != nil {
-		return err
+// unlockKeys gives up the key locks req holds, unless they have been released, e.g. by committing its write, and
+// taken by another since.
+func (t *Transactor) unlockKeys(req *request) {
+	for database, lock := range req.held {
+		err := t.keys.UnlockKeys(lock, database, req.lock[database])
+		if err != nil && !errors.Is(err, localstate.ErrNotOwner) {
+			slog.Warn("could not unlock keys", "comp", "proxy", "error", err)
+		}
 	}
+	req.held = nil
+}
 
-	cmd, err := req.Cmd()
-	if err != nil {
-		msg := protocol.NewError(err)
-		_, err := connection.Write(msg)
+// reply reads the reply to req from upstream, and sends it to the client once its keys have been committed. The
+// client is flushed after the last of the pending replies.
+func (t *Transactor) reply(ctx context.Context, s *session, req request, last bool) error {
+	log := slog.With("comp", "proxy")
+	defer t.release(&req)
+
+	if req.err != nil {
+		_, err := s.connection.Write(*protocol.NewError(req.err))
+		if err != nil {
+			return err
+		}
+	} else {
+		resp, err := req.upstream.ReadFrame()
+		if err != nil {
+			return err
+		}
+
+		failed := s.settle(&req, resp.Message)
+		if t.ack == AckOffset {
+			// a write queued by MULTI is waited on at EXEC.
+			if len(req.lock) > 0 || len(req.await) > 0 || req.write && !req.queued {
+				log.Debug("awaiting commit", "cmd", req.name)
+				err = t.awaitCommitted(ctx)
+			}
+		} else {
+			if failed {
+				t.unlockKeys(&req)
+			}
+			// a serializing command, or a write without keys, e.g. FLUSHALL, has no locks to wait on, so it waits on
+			// the offset like any command does when acknowledging by offset.
+			offset := !failed && (req.serializing || req.write && len(req.lock) == 0) && !req.queued
+			err = t.awaitKeys(ctx, &req, offset)
+		}
+
+		log.Debug("command", "cmd", req.name, "resp", resp.Message)
+
+		if err == nil {
+			_, err = s.connection.WriteRaw(resp.Raw)
+		}
 		if err != nil {
 			return err
 		}
+		// the reply points into the upstream connection, so it is only given back once the reply is written.
+		s.done()
 	}
-	if cmd.Name == "SELECT" {
-		t.database.Store(&cmd.Args[0])
+
+	if !last {
+		return nil
 	}
+	return s.connection.Flush()
+}
 
-	if cmd.IsWrite() {
-		err := t.keys.LockKeys(cmd)
+// awaitKeys waits until the write of req has been committed, or if offset is set until the writes before it have been,
+// then gives up the keys it locked if they haven't been released by the commit already, and waits until the keys it
+// reads are unlocked.
+func (t *Transactor) awaitKeys(ctx context.Context, req *request, offset bool) error {
+	slog.Debug("awaiting release of lock", "comp", "proxy", "keys", req.lock, "await", req.await)
+	defer t.commitWaits.start()()
+	if len(req.held) > 0 || offset {
+		err := t.awaitOffset(ctx)
 		if err != nil {
 			return err
 		}
+		t.unlockKeys(req)
 	}
 
-	log.Debug("awaiting release of lock", "msg", cmd.Message)
-	err = t.keys.AwaitUnlocked(ctx, cmd)
-	if err != nil {
-		return err
+	for database, keys := range req.await {
+		_, err := t.keys.AwaitUnlocked(ctx, database, keys)
+		if err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	log.Info("command", "req", req, "resp", resp)
+// awaitCommitted waits until the replication stream has been committed up to the replication offset of the upstream
+// server now, which is past every write it has replied to, and counts the wait.
+func (t *Transactor) awaitCommitted(ctx context.Context) error {
+	defer t.commitWaits.start()()
+	return t.awaitOffset(ctx)
+}
 
-	_, err = connection.Write(resp)
+// awaitOffset waits like awaitCommitted, without counting the wait.
+func (t *Transactor) awaitOffset(ctx context.Context) error {
+	c, err := t.offsets.get(ctx)
 	if err != nil {
 		return err
 	}
-
-	err = connection.Flush()
+	offset, err := t.leader.MasterOffset(c.Conn)
 	if err != nil {
+		t.offsets.discard(c)
 		return err
 	}
-	return nil
+	t.offsets.put(c)
+
+	return t.committed.wait(ctx, offset)
 }
@@ -35,6 +35,9 @@ func TestTransactor(t *testing.T) {
 	defer cancel()
 	conf := &Conf{}
 	conf.LoadEnv()
+	if conf.RedisAddress == "" {
+		t.Skip("REDIS_ADDRESS is not set; TestReplicate_Leader uses a fake leader instead")
+	}
 
 	transactor, err := NewTransactor(ctx, conf, &testLog{})
 
@@ -47,6 +50,9 @@ func TestTransactor(t *testing.T) {
 	defer p2.Close()
 
 	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return transactor.Replicate(ctx)
+	})
 	g.Go(func() error {
 		t.Log("starting transactor")
 		return transactor.Transact(ctx, p2)
@@ -65,11 +71,11 @@ func TestTransactor(t *testing.T) {
 			get := protocol.NewOutgoingCommand("GET", s)
 
 			t.Log("writing", "msg", set)
-			_, err := connection.Write(set)
+			_, err := connection.Write(*set)
 			if err != nil {
 				return err
 			}
-			_, err = connection.Write(get)
+			_, err = connection.Write(*get)
 			if err != nil {
 				return err
 			}
@@ -87,8 +93,12 @@ func TestTransactor(t *testing.T) {
 				return err
 			}
 
-			assert.Equal(t, setrespoonse.Str, "OK")
-			assert.Equal(t, readresponse.Str, s)
+			assert.Equal(t, setrespoonse.SimpleString, "OK")
+			value, err := readresponse.ReadAll()
+			if err != nil {
+				return err
+			}
+			assert.Equal(t, value, s)
 			time.Sleep(time.Millisecond * 1000)
 		}
 		return ctx.Err()
@@ -1,3 +1,18 @@
 module anarchoredis/cmd/anarchoredis
 
 go 1.23.2
+
+require (
+	github.com/alexflint/go-arg v1.5.1
+	github.com/awinterman/anarchoredis/txn v0.0.0-00010101000000-000000000000
+)
+
+require (
+	github.com/alexflint/go-scalar v1.2.0 // indirect
+	github.com/awinterman/anarchoredis/protocol v0.0.0-00010101000000-000000000000 // indirect
+)
+
+replace (
+	github.com/awinterman/anarchoredis/protocol => ../../protocol
+	github.com/awinterman/anarchoredis/txn => ../../anarchoredis
+)
@@ -10,7 +10,12 @@ import (
 
 func main() {
 	ctx := context.Background()
-	err := anarchoredis.Run(ctx)
+	var err error
+	if len(os.Args) > 1 && os.Args[1] == "tail" {
+		err = runTail(ctx, os.Args[2:])
+	} else {
+		err = anarchoredis.Run(ctx)
+	}
 	if err != nil {
 		slog.Error("exiting;", "error", err)
 		os.Exit(1)
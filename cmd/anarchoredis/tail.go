@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+
+	"github.com/alexflint/go-arg"
+	"github.com/awinterman/anarchoredis/txn/replication"
+	"github.com/awinterman/anarchoredis/txn/tail"
+)
+
+// tailArgs are the arguments of anarchoredis tail, which prints the replication stream of a redis server.
+type tailArgs struct {
+	Leader   string   `arg:"--leader,env:REDIS_ADDRESS" default:"localhost:6379" help:"address of the redis server to follow"`
+	Sentinel []string `arg:"--sentinel,separate,env:SENTINEL_ADDRESS" help:"address of a sentinel to ask for the leader, in place of --leader, following its failovers"`
+	Master   string   `arg:"--master,env:SENTINEL_MASTER" default:"mymaster" help:"name the sentinels monitor the leader by"`
+	Addr     string   `arg:"--addr,env:LISTEN_ADDRESS" default:"127.0.0.1:0" help:"address the server is told the replica listens on"`
+	State    string   `arg:"--state" help:"file the position in the stream is saved to and resumed from, rather than starting with a full resync"`
+	Commands []string `arg:"--command,separate" help:"only print these commands"`
+	Keys     string   `arg:"--keys" help:"only print commands with a key matching this glob pattern"`
+	DBs      []string `arg:"--db,separate" help:"only print commands in these databases"`
+	Format   string   `arg:"--format" default:"human" help:"how commands are printed: human, resp or json"`
+}
+
+// runTail follows the server as a replica, printing its stream to stdout until interrupted.
+func runTail(ctx context.Context, args []string) error {
+	var a tailArgs
+	parser, err := arg.NewParser(arg.Config{Program: "anarchoredis tail"}, &a)
+	if err != nil {
+		return err
+	}
+	err = parser.Parse(args)
+	if errors.Is(err, arg.ErrHelp) {
+		parser.WriteHelp(os.Stdout)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	format := tail.Format(a.Format)
+	switch format {
+	case tail.Human, tail.RESP, tail.JSON:
+	default:
+		return fmt.Errorf("unknown format %q", a.Format)
+	}
+
+	s := &replication.Subscriber{
+		LeaderAddr: a.Leader,
+		MyAddr:     a.Addr,
+		StatePath:  a.State,
+		// the stream is printed to stdout, and logs to stderr.
+		Logger: slog.New(slog.NewTextHandler(os.Stderr, nil)),
+	}
+	if len(a.Sentinel) > 0 {
+		s.Sentinel = &replication.Sentinel{Addrs: a.Sentinel, MasterName: a.Master, Logger: s.Logger}
+	}
+	p := &tail.Printer{
+		W:      os.Stdout,
+		Format: format,
+		Filter: tail.Filter{Commands: a.Commands, KeyPattern: a.Keys, Databases: a.DBs},
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	err = tail.Tail(ctx, s, p)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
@@ -6,6 +6,8 @@ import (
 	"io"
 	"os"
 	"testing"
+
+	"github.com/awinterman/anarchoredis/protocol/message"
 )
 
 func TestAOFRead(t *testing.T) {
@@ -14,20 +16,21 @@ func TestAOFRead(t *testing.T) {
 		t.Fatal(err)
 	}
 	defer file.Close()
-	rd := bufio.NewReadWriter(bufio.NewReader(file), nil)
+	rd := bufio.NewReader(file)
 
-	var msg *Message
-	for err == nil {
-		msg, err = Read(rd)
-		if err != nil {
-			t.Log(err)
+	encoder := message.Encoder{}
+	for msg, rerr := range encoder.Iterate(rd) {
+		if rerr != nil {
+			t.Log(rerr)
+			err = rerr
 			break
 		}
 
-		command, rerr := msg.Cmd()
+		command, rerr := Cmd(msg)
 		if rerr != nil {
 			t.Errorf("error parsing %v %T", rerr, rerr)
 			err = rerr
+			break
 		}
 
 		keys, rerr := command.Keys()
@@ -3,8 +3,6 @@ package protocol
 import (
 	"errors"
 	"fmt"
-	"io"
-	"iter"
 	"slices"
 	"strings"
 
@@ -12,22 +10,27 @@ import (
 )
 
 type Command struct {
-	// Name is the name of the command
+	// Name is the name of the command, upper-cased, followed by the name of the subcommand if it has one.
 	Name string
 
-	// Args are all the strings in the command after the name.
-	Args iter.Seq2[Message, error]
+	// Args are all the strings in the command after the name, read into memory.
+	Args [][]byte
+
+	// Trailer is the last argument if it was too large to read into memory, see LazyArgLen, and nil otherwise. It is
+	// not included in Args, and can only be read once.
+	Trailer *Message
 
 	Database string
 
-	// Message is the original message
+	// Message is the original message, read into memory apart from the Trailer.
 	Message message.Message
 }
 
-var commandsWithoutKey = map[string]bool{"FLUSHALL": true, "FLUSHDB": true, "SELECT": true, "FUNCTION": true,
-	"CLIENT": true, "CLUSTER": true, "ACL": true, "COMMAND": true, "CONFIG": true, "PING": true}
-var commandsWithSubOp = map[string]bool{"BITOP": true, "FUNCTION": true, "SCRIPT": true, "CLIENT": true,
-	"CLUSTER": true, "ACL": true, "COMMAND": true, "CONFIG": true}
+//go:generate go run ./internal/cmdspecgen -o commands_gen.go $REDIS_SRC/src/commands
+
+// LazyArgLen is the size above which the last argument of a command is left on the wire rather than read into
+// memory by Cmd.
+var LazyArgLen int64 = 1 << 20
 
 // ErrInvalidCommand is returned when a command is invalid
 var ErrInvalidCommand = errors.New("invalid command")
@@ -45,188 +48,152 @@ var ErrNotImplemented = errors.New("not implemented")
 // determined by the command's implementation and possibly by the client's
 // protocol version.
 //
-// at time of writing, this is exclusively parsing the aof file
+// The message is read into memory, see message.Materialize, so the arguments can be read any number of times.
 func Cmd(msg message.Message) (*Command, error) {
-	cmd := &Command{}
-	cmd.Message = msg
-
 	if msg.Kind != Array {
 		return nil, fmt.Errorf("%w; expected array got %s", ErrInvalidCommand, msg.Kind)
 	}
 
-	var i int
-	for arg, err := range msg.Seq {
-		i++
-		if err != nil {
-			return nil, err
-		}
+	msg, err := message.Materialize(msg, LazyArgLen)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := &Command{}
+	cmd.Message = msg
+
+	argv := make([][]byte, 0, len(msg.Elems))
+	for i, arg := range msg.Elems {
 		if arg.Kind != BulkString {
 			return nil, fmt.Errorf("%w; expected Bulk for %d-th element of message, string got %s",
 				ErrInvalidCommand, i, arg.Kind)
 		}
-		all, err := arg.ReadAll()
-		if err != nil {
-			return nil, err
+		if arg.Reader != nil {
+			cmd.Trailer = &msg.Elems[i]
+			break
 		}
+		argv = append(argv, arg.Bytes)
+	}
 
-		if i == 0 {
-			if cmd.Name = strings.ToUpper(all); cmd.Name == "" {
-				return nil, fmt.Errorf("%w; expected non-empty string for command name", ErrInvalidCommand)
-			}
-			if commandsWithoutKey[cmd.Name] {
-				if msg.RunLength > 2 {
-					return nil, fmt.Errorf("%w; expected at least two elements for command %s got %d", ErrInvalidCommand,
-						cmd.Name, msg.RunLength)
-				}
-			}
-			cmd.Args = msg.Seq
-		}
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("%w; expected non-empty string for command name", ErrInvalidCommand)
+	}
+	if cmd.Name = strings.ToUpper(string(argv[0])); cmd.Name == "" {
+		return nil, fmt.Errorf("%w; expected non-empty string for command name", ErrInvalidCommand)
+	}
+	argv = argv[1:]
 
-		if !commandsWithSubOp[cmd.Name] {
-			cmd.Args = msg.Seq
-			return cmd, nil
-		}
-		if i == 1 {
-			if msg.RunLength < 3 {
-				return nil, fmt.Errorf("%w; expected at least three elements for command %s got %d", ErrInvalidCommand,
-					cmd.Name, msg.RunLength)
-			}
-			readAll, err := arg.ReadAll()
-			if err != nil {
-				return nil, err
-			}
-			cmd.Name = cmd.Name + " " + strings.ToUpper(readAll)
-			cmd.Args = msg.Seq
-		}
+	// containers such as CONFIG are named together with their subcommand, e.g. CONFIG GET. Without one, the
+	// container itself is the command, e.g. COMMAND.
+	if commands.Load().containers[cmd.Name] && len(argv) > 0 {
+		cmd.Name = cmd.Name + " " + strings.ToUpper(string(argv[0]))
+		argv = argv[1:]
 	}
+	cmd.Args = argv
 
 	return cmd, nil
 }
 
-func firstN(n int) func(args iter.Seq2[Message, error], size int) ([]string, error) {
-	return func(args iter.Seq2[Message, error], size int) ([]string, error) {
-		if size < n {
-			return []string{}, fmt.Errorf("%w; expected at least %d argument for firstArgKey", ErrInvalidCommand, n)
-		}
-		var keys []string
-		var err error
-		args(func(m Message, err error) bool {
-			var all string
-			all, err = m.ReadAll()
-			if err != nil {
-				return false
-			}
-			keys = append(keys, all)
-			return len(keys) <= n
-		})
-		return keys, err
+// NArgs is the number of arguments, including the Trailer.
+func (cmd *Command) NArgs() int {
+	if cmd.Trailer != nil {
+		return len(cmd.Args) + 1
 	}
+	return len(cmd.Args)
 }
 
-var firstArgKeyFunc = firstN(1)
-
-// oddIndices returns all the odd indices of args
-func oddIndices(args iter.Seq2[Message, error], size int) ([]string, error) {
-	if size%2 == 1 {
-		return nil, fmt.Errorf("%w: expected an even number of arguments", ErrInvalidCommand)
+// String formats the command for logs, eliding the Trailer.
+func (cmd *Command) String() string {
+	s := cmd.Name
+	for _, arg := range cmd.Args {
+		s += " " + string(arg)
 	}
-	// returns all the odd indices of args
-
-	var keys []string
-	var err error
-
-	args(func(m Message, err error) bool {
-		var all string
-		all, err = m.ReadAll()
-		if err != nil {
-			return false
-		}
-	})
-
-	return keys, nil
+	if cmd.Trailer != nil {
+		s += fmt.Sprintf(" (%d bytes)", cmd.Trailer.RunLength)
+	}
+	return s
 }
 
-// allArgs processes a sequence of Messages and returns a slice of strings extracted from each Message using ReadAll.
-// If an error occurs while reading a Message, it terminates processing and returns the error encountered.
-func allArgs(args iter.Seq2[Message, error], _ int) ([]string, error) {
-	var keys []string
-	var err error
-	args(func(m Message, err error) bool {
-		var all string
-		all, err = m.ReadAll()
-		if err != nil {
-			return false
+// keys returns the arguments at indices as strings. Keys are never left in the Trailer.
+func keys(args [][]byte, indices ...int) ([]string, error) {
+	ks := make([]string, 0, len(indices))
+	for _, i := range indices {
+		if i >= len(args) {
+			return nil, fmt.Errorf("%w: key argument %d is larger than %d bytes", ErrInvalidCommand, i, LazyArgLen)
 		}
-		keys = append(keys, all)
-		return true
-	})
-	return keys, err
+		ks = append(ks, string(args[i]))
+	}
+	return ks, nil
 }
 
+// CommandSpecification describes a command as the Redis command table does. The table for the commands of Redis
+// 7.x is generated into commands_gen.go from the JSON files Redis builds its own table from, and can be extended at
+// runtime with Discover and Register.
 type CommandSpecification struct {
-	Keys       func(iter.Seq2[Message, error], int) ([]string, error)
+	// Arity is the number of arguments including the command name, or the negated minimum if it is variadic.
+	Arity int
+	// Flags are the command flags, lower-cased, e.g. write, readonly, denyoom.
+	Flags []string
+	// Categories are the ACL categories, lower-cased, e.g. write, string, fast.
 	Categories []string
+	KeySpecs   []KeySpec
 }
 
-func noKeysFunc(iter.Seq2[Message, error], int) ([]string, error) {
-	return nil, nil
-}
-
-var cmdSpec = map[string]CommandSpecification{
-	// connection
-	"SELECT": {noKeysFunc, []string{"fast", "connection"}},
-
-	//keyspace
-	"UNLINK":   {allArgs, []string{"keyspace", "write", "fast"}},
-	"FLUSHALL": {noKeysFunc, []string{"keyspace", "write", "slow", "dangerous"}},
-
-	// strings
-	"APPEND":      {firstArgKeyFunc, []string{"write", "string", "fast"}},
-	"DECR":        {firstArgKeyFunc, []string{"write", "string", "fast"}},
-	"DECRBY":      {firstArgKeyFunc, []string{"write", "string", "fast"}},
-	"GET":         {firstArgKeyFunc, []string{"read", "string", "fast"}},
-	"GETDEL":      {firstArgKeyFunc, []string{"write", "string", "fast"}},
-	"GETEX":       {firstArgKeyFunc, []string{"write", "string", "fast"}},
-	"GETRANGE":    {firstArgKeyFunc, []string{"read", "string", "slow"}},
-	"GETSET":      {firstArgKeyFunc, []string{"write", "string", "fast"}},
-	"INCR":        {firstArgKeyFunc, []string{"write", "string", "fast"}},
-	"INCRBY":      {firstArgKeyFunc, []string{"write", "string", "fast"}},
-	"INCRBYFLOAT": {firstArgKeyFunc, []string{"write", "string", "fast"}},
-	"LCS": {func(args []string) ([]string, error) {
-		if len(args) < 2 {
-			return nil, fmt.Errorf("%w: expected at least two arguments", ErrInvalidCommand)
+// keys returns the keys named by the KeySpecs in args, the arguments that follow the first offset elements of the
+// command, of which there are size including any Trailer.
+func (spec CommandSpecification) keys(args [][]byte, size int, offset int) ([]string, error) {
+	var ks []string
+	for _, keySpec := range spec.KeySpecs {
+		if keySpec.notKey() {
+			continue
 		}
-		return args[0:2], nil
-	}, []string{"read", "string", "slow"}},
-	// MGET key [key ...]
-	"MGET": {allArgs, []string{"read", "string", "fast"}},
-	//MSET key value [key value ...]
-	"MSET":     {oddIndices, []string{"write", "string", "fast"}},
-	"MSETNX":   {oddIndices, []string{"write", "string", "fast"}},
-	"SET":      {firstArgKeyFunc, []string{"write", "string", "fast"}},
-	"SETRANGE": {firstArgKeyFunc, []string{"write", "string", "fast"}},
-	"STRLEN":   {firstArgKeyFunc, []string{"read", "string", "fast"}},
-
-	// sets
-	"SADD": {firstArgKeyFunc, []string{"write", "set", "fast"}},
-	"SREM": {firstArgKeyFunc, []string{"write", "set", "fast"}},
-
-	// sorted sets
-	"ZADD": {firstArgKeyFunc, []string{"write", "sortedset", "fast"}},
+		found, err := keySpec.keys(args, size, offset)
+		if err != nil {
+			return nil, err
+		}
+		ks = append(ks, found...)
+	}
+	return ks, nil
 }
 
 // Keys returns the keys affected by the command.
 func (cmd *Command) Keys() ([]string, error) {
-	specification, ok := cmdSpec[cmd.Name]
+	specification, ok := Specification(cmd.Name)
 	if !ok {
 		return nil, fmt.Errorf("%w: %s %w", ErrInvalidCommand, cmd.Name, ErrNotImplemented)
 	}
-	return specification.Keys(cmd.Args, int(cmd.Message.RunLength))
+	// the name, and the subcommand if any, precede the arguments
+	offset := 1 + strings.Count(cmd.Name, " ")
+	return specification.keys(cmd.Args, cmd.NArgs(), offset)
 }
 
-// IsWrite says whether the command would result in a write if executed
+// IsWrite says whether the command would result in a write if executed. Scripts and functions, which are not
+// flagged as writes themselves, may write if they are allowed to write to the keys they declare.
 func (cmd *Command) IsWrite() bool {
-	specification := cmdSpec[cmd.Name]
-	return slices.Contains[[]string, string](specification.Categories, "write")
+	specification, _ := Specification(cmd.Name)
+	if slices.Contains(specification.Categories, "write") {
+		return true
+	}
+	if !slices.Contains(specification.Flags, "may_replicate") {
+		return false
+	}
+	return slices.ContainsFunc(specification.KeySpecs, func(spec KeySpec) bool {
+		return !spec.notKey() && !slices.Contains(spec.Flags, "RO")
+	})
+}
+
+// serializingCommands change the scripts or functions loaded, which any command may depend on.
+var serializingCommands = map[string]bool{
+	"SCRIPT LOAD":      true,
+	"SCRIPT FLUSH":     true,
+	"FUNCTION LOAD":    true,
+	"FUNCTION DELETE":  true,
+	"FUNCTION FLUSH":   true,
+	"FUNCTION RESTORE": true,
+}
 
+// IsSerializing says whether the command must not be reordered with any other, because it changes state shared by
+// every key, such as the scripts and functions loaded.
+func (cmd *Command) IsSerializing() bool {
+	return serializingCommands[cmd.Name]
 }
@@ -0,0 +1,3344 @@
+// Code generated by cmdspecgen; DO NOT EDIT.
+
+package protocol
+
+// commandContainers are the commands that only group subcommands, e.g. CONFIG.
+var commandContainers = map[string]bool{
+	"ACL":      true,
+	"CLIENT":   true,
+	"CLUSTER":  true,
+	"COMMAND":  true,
+	"CONFIG":   true,
+	"FUNCTION": true,
+	"LATENCY":  true,
+	"MEMORY":   true,
+	"MODULE":   true,
+	"OBJECT":   true,
+	"PUBSUB":   true,
+	"SCRIPT":   true,
+	"SLOWLOG":  true,
+	"XGROUP":   true,
+	"XINFO":    true,
+}
+
+var cmdSpec = map[string]CommandSpecification{
+	"ACL": {
+		Arity:      -2,
+		Flags:      []string{"sentinel"},
+		Categories: []string{"slow"},
+	},
+	"ACL CAT": {
+		Arity:      -2,
+		Flags:      []string{"noscript", "loading", "stale"},
+		Categories: []string{"slow"},
+	},
+	"ACL DELUSER": {
+		Arity:      -3,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"ACL DRYRUN": {
+		Arity:      -4,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"ACL GENPASS": {
+		Arity:      -2,
+		Flags:      []string{"noscript", "loading", "stale"},
+		Categories: []string{"slow"},
+	},
+	"ACL GETUSER": {
+		Arity:      3,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"ACL HELP": {
+		Arity:      2,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"slow"},
+	},
+	"ACL LIST": {
+		Arity:      2,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"ACL LOAD": {
+		Arity:      2,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"ACL LOG": {
+		Arity:      -2,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"ACL SAVE": {
+		Arity:      2,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"ACL SETUSER": {
+		Arity:      -3,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"ACL USERS": {
+		Arity:      2,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"ACL WHOAMI": {
+		Arity:      2,
+		Flags:      []string{"noscript", "loading", "stale"},
+		Categories: []string{"slow"},
+	},
+	"APPEND": {
+		Arity:      3,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"string", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "INSERT"},
+			},
+		},
+	},
+	"ASKING": {
+		Arity:      1,
+		Flags:      []string{"fast"},
+		Categories: []string{"fast"},
+	},
+	"AUTH": {
+		Arity:      -2,
+		Flags:      []string{"noscript", "loading", "stale", "fast", "no_auth", "sentinel", "allow_busy"},
+		Categories: []string{"connection", "fast"},
+	},
+	"BGREWRITEAOF": {
+		Arity:      1,
+		Flags:      []string{"admin", "noscript", "no_async_loading"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"BGSAVE": {
+		Arity:      -1,
+		Flags:      []string{"admin", "noscript", "no_async_loading"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"BITCOUNT": {
+		Arity:      -2,
+		Flags:      []string{"readonly"},
+		Categories: []string{"bitmap", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"BITFIELD": {
+		Arity:      -2,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"bitmap", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "UPDATE", "ACCESS", "VARIABLE_FLAGS"},
+			},
+		},
+	},
+	"BITFIELD_RO": {
+		Arity:      -2,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"bitmap", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"BITOP": {
+		Arity:      -4,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"bitmap", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"OW", "UPDATE"},
+			},
+			{
+				BeginSearch: BeginSearch{Index: 3},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"BITPOS": {
+		Arity:      -3,
+		Flags:      []string{"readonly"},
+		Categories: []string{"bitmap", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"BLMOVE": {
+		Arity:      6,
+		Flags:      []string{"write", "denyoom", "blocking"},
+		Categories: []string{"list", "write", "blocking", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "INSERT"},
+			},
+		},
+	},
+	"BLMPOP": {
+		Arity:      -5,
+		Flags:      []string{"write", "blocking"},
+		Categories: []string{"list", "write", "blocking", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Keynum: &KeyNum{KeyNumIdx: 0, FirstKey: 1, Step: 1}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+		},
+	},
+	"BLPOP": {
+		Arity:      -3,
+		Flags:      []string{"write", "blocking"},
+		Categories: []string{"list", "write", "blocking", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -2, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+		},
+	},
+	"BRPOP": {
+		Arity:      -3,
+		Flags:      []string{"write", "blocking"},
+		Categories: []string{"list", "write", "blocking", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -2, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+		},
+	},
+	"BRPOPLPUSH": {
+		Arity:      4,
+		Flags:      []string{"write", "denyoom", "blocking"},
+		Categories: []string{"list", "write", "blocking", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "INSERT"},
+			},
+		},
+	},
+	"BZMPOP": {
+		Arity:      -5,
+		Flags:      []string{"write", "blocking"},
+		Categories: []string{"sortedset", "write", "blocking", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Keynum: &KeyNum{KeyNumIdx: 0, FirstKey: 1, Step: 1}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+		},
+	},
+	"BZPOPMAX": {
+		Arity:      -3,
+		Flags:      []string{"write", "fast", "blocking"},
+		Categories: []string{"sortedset", "write", "fast", "blocking"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -2, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+		},
+	},
+	"BZPOPMIN": {
+		Arity:      -3,
+		Flags:      []string{"write", "fast", "blocking"},
+		Categories: []string{"sortedset", "write", "fast", "blocking"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -2, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+		},
+	},
+	"CLIENT": {
+		Arity:      -2,
+		Flags:      []string{"sentinel"},
+		Categories: []string{"slow"},
+	},
+	"CLIENT CACHING": {
+		Arity:      3,
+		Flags:      []string{"noscript", "loading", "stale"},
+		Categories: []string{"connection", "slow"},
+	},
+	"CLIENT GETNAME": {
+		Arity:      2,
+		Flags:      []string{"noscript", "loading", "stale"},
+		Categories: []string{"connection", "slow"},
+	},
+	"CLIENT GETREDIR": {
+		Arity:      2,
+		Flags:      []string{"noscript", "loading", "stale"},
+		Categories: []string{"connection", "slow"},
+	},
+	"CLIENT HELP": {
+		Arity:      2,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"connection", "slow"},
+	},
+	"CLIENT ID": {
+		Arity:      2,
+		Flags:      []string{"noscript", "loading", "stale"},
+		Categories: []string{"connection", "slow"},
+	},
+	"CLIENT INFO": {
+		Arity:      2,
+		Flags:      []string{"noscript", "loading", "stale"},
+		Categories: []string{"connection", "slow"},
+	},
+	"CLIENT KILL": {
+		Arity:      -3,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"connection", "admin", "dangerous", "slow"},
+	},
+	"CLIENT LIST": {
+		Arity:      -2,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"connection", "admin", "dangerous", "slow"},
+	},
+	"CLIENT NO-EVICT": {
+		Arity:      3,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"connection", "admin", "dangerous", "slow"},
+	},
+	"CLIENT NO-TOUCH": {
+		Arity:      3,
+		Flags:      []string{"noscript", "loading", "stale"},
+		Categories: []string{"connection", "slow"},
+	},
+	"CLIENT PAUSE": {
+		Arity:      -3,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"connection", "admin", "dangerous", "slow"},
+	},
+	"CLIENT REPLY": {
+		Arity:      3,
+		Flags:      []string{"noscript", "loading", "stale"},
+		Categories: []string{"connection", "slow"},
+	},
+	"CLIENT SETINFO": {
+		Arity:      4,
+		Flags:      []string{"noscript", "loading", "stale"},
+		Categories: []string{"connection", "slow"},
+	},
+	"CLIENT SETNAME": {
+		Arity:      3,
+		Flags:      []string{"noscript", "loading", "stale"},
+		Categories: []string{"connection", "slow"},
+	},
+	"CLIENT TRACKING": {
+		Arity:      -3,
+		Flags:      []string{"noscript", "loading", "stale"},
+		Categories: []string{"connection", "slow"},
+	},
+	"CLIENT TRACKINGINFO": {
+		Arity:      2,
+		Flags:      []string{"noscript", "loading", "stale"},
+		Categories: []string{"connection", "slow"},
+	},
+	"CLIENT UNBLOCK": {
+		Arity:      -3,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"connection", "admin", "dangerous", "slow"},
+	},
+	"CLIENT UNPAUSE": {
+		Arity:      2,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"connection", "admin", "dangerous", "slow"},
+	},
+	"CLUSTER": {
+		Arity:      -2,
+		Flags:      nil,
+		Categories: []string{"slow"},
+	},
+	"CLUSTER ADDSLOTS": {
+		Arity:      -3,
+		Flags:      []string{"admin", "noscript", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"CLUSTER ADDSLOTSRANGE": {
+		Arity:      -4,
+		Flags:      []string{"admin", "noscript", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"CLUSTER BUMPEPOCH": {
+		Arity:      2,
+		Flags:      []string{"admin", "noscript", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"CLUSTER COUNT-FAILURE-REPORTS": {
+		Arity:      3,
+		Flags:      []string{"admin", "noscript", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"CLUSTER COUNTKEYSINSLOT": {
+		Arity:      3,
+		Flags:      []string{"stale"},
+		Categories: []string{"slow"},
+	},
+	"CLUSTER DELSLOTS": {
+		Arity:      -3,
+		Flags:      []string{"admin", "noscript", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"CLUSTER DELSLOTSRANGE": {
+		Arity:      -4,
+		Flags:      []string{"admin", "noscript", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"CLUSTER FAILOVER": {
+		Arity:      -2,
+		Flags:      []string{"admin", "noscript", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"CLUSTER FLUSHSLOTS": {
+		Arity:      2,
+		Flags:      []string{"admin", "noscript", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"CLUSTER FORGET": {
+		Arity:      3,
+		Flags:      []string{"admin", "noscript", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"CLUSTER GETKEYSINSLOT": {
+		Arity:      4,
+		Flags:      []string{"stale"},
+		Categories: []string{"slow"},
+	},
+	"CLUSTER HELP": {
+		Arity:      2,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"slow"},
+	},
+	"CLUSTER INFO": {
+		Arity:      2,
+		Flags:      []string{"stale"},
+		Categories: []string{"slow"},
+	},
+	"CLUSTER KEYSLOT": {
+		Arity:      3,
+		Flags:      []string{"stale"},
+		Categories: []string{"slow"},
+	},
+	"CLUSTER LINKS": {
+		Arity:      2,
+		Flags:      []string{"stale"},
+		Categories: []string{"slow"},
+	},
+	"CLUSTER MEET": {
+		Arity:      -4,
+		Flags:      []string{"admin", "noscript", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"CLUSTER MYID": {
+		Arity:      2,
+		Flags:      []string{"stale"},
+		Categories: []string{"slow"},
+	},
+	"CLUSTER MYSHARDID": {
+		Arity:      2,
+		Flags:      []string{"stale"},
+		Categories: []string{"slow"},
+	},
+	"CLUSTER NODES": {
+		Arity:      2,
+		Flags:      []string{"stale"},
+		Categories: []string{"slow"},
+	},
+	"CLUSTER REPLICAS": {
+		Arity:      3,
+		Flags:      []string{"admin", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"CLUSTER REPLICATE": {
+		Arity:      3,
+		Flags:      []string{"admin", "noscript", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"CLUSTER RESET": {
+		Arity:      -2,
+		Flags:      []string{"admin", "noscript", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"CLUSTER SAVECONFIG": {
+		Arity:      2,
+		Flags:      []string{"admin", "noscript", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"CLUSTER SET-CONFIG-EPOCH": {
+		Arity:      3,
+		Flags:      []string{"admin", "noscript", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"CLUSTER SETSLOT": {
+		Arity:      -4,
+		Flags:      []string{"admin", "noscript", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"CLUSTER SHARDS": {
+		Arity:      2,
+		Flags:      []string{"stale"},
+		Categories: []string{"slow"},
+	},
+	"CLUSTER SLAVES": {
+		Arity:      3,
+		Flags:      []string{"admin", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"CLUSTER SLOTS": {
+		Arity:      2,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"slow"},
+	},
+	"COMMAND": {
+		Arity:      -1,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"connection", "slow"},
+	},
+	"COMMAND COUNT": {
+		Arity:      2,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"connection", "slow"},
+	},
+	"COMMAND DOCS": {
+		Arity:      -2,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"connection", "slow"},
+	},
+	"COMMAND GETKEYS": {
+		Arity:      -3,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"connection", "slow"},
+	},
+	"COMMAND GETKEYSANDFLAGS": {
+		Arity:      -3,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"connection", "slow"},
+	},
+	"COMMAND HELP": {
+		Arity:      2,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"connection", "slow"},
+	},
+	"COMMAND INFO": {
+		Arity:      -2,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"connection", "slow"},
+	},
+	"COMMAND LIST": {
+		Arity:      -2,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"connection", "slow"},
+	},
+	"CONFIG": {
+		Arity:      -2,
+		Flags:      nil,
+		Categories: []string{"slow"},
+	},
+	"CONFIG GET": {
+		Arity:      -3,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"CONFIG HELP": {
+		Arity:      2,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"slow"},
+	},
+	"CONFIG RESETSTAT": {
+		Arity:      2,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"CONFIG REWRITE": {
+		Arity:      2,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"CONFIG SET": {
+		Arity:      -4,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"COPY": {
+		Arity:      -3,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"keyspace", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"OW", "UPDATE"},
+			},
+		},
+	},
+	"DBSIZE": {
+		Arity:      1,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"keyspace", "read", "fast"},
+	},
+	"DEBUG": {
+		Arity:      -2,
+		Flags:      []string{"admin", "noscript", "loading", "stale", "no_multi"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"DECR": {
+		Arity:      2,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"string", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "UPDATE"},
+			},
+		},
+	},
+	"DECRBY": {
+		Arity:      3,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"string", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "UPDATE"},
+			},
+		},
+	},
+	"DEL": {
+		Arity:      -2,
+		Flags:      []string{"write"},
+		Categories: []string{"keyspace", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 1, Limit: 0}},
+				Flags:       []string{"RM", "DELETE"},
+			},
+		},
+	},
+	"DISCARD": {
+		Arity:      1,
+		Flags:      []string{"noscript", "loading", "stale", "fast", "allow_busy"},
+		Categories: []string{"transaction", "fast"},
+	},
+	"DUMP": {
+		Arity:      2,
+		Flags:      []string{"readonly"},
+		Categories: []string{"keyspace", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"ECHO": {
+		Arity:      2,
+		Flags:      []string{"fast"},
+		Categories: []string{"connection", "fast"},
+	},
+	"EVAL": {
+		Arity:      -3,
+		Flags:      []string{"noscript", "skip_monitor", "stale", "may_replicate", "no_mandatory_keys"},
+		Categories: []string{"scripting", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Keynum: &KeyNum{KeyNumIdx: 0, FirstKey: 1, Step: 1}},
+				Flags:       []string{"RW", "ACCESS", "UPDATE"},
+			},
+		},
+	},
+	"EVALSHA": {
+		Arity:      -3,
+		Flags:      []string{"noscript", "skip_monitor", "stale", "may_replicate", "no_mandatory_keys"},
+		Categories: []string{"scripting", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Keynum: &KeyNum{KeyNumIdx: 0, FirstKey: 1, Step: 1}},
+				Flags:       []string{"RW", "ACCESS", "UPDATE"},
+			},
+		},
+	},
+	"EVALSHA_RO": {
+		Arity:      -3,
+		Flags:      []string{"noscript", "skip_monitor", "readonly", "stale", "no_mandatory_keys"},
+		Categories: []string{"scripting", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Keynum: &KeyNum{KeyNumIdx: 0, FirstKey: 1, Step: 1}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"EVAL_RO": {
+		Arity:      -3,
+		Flags:      []string{"noscript", "skip_monitor", "readonly", "stale", "no_mandatory_keys"},
+		Categories: []string{"scripting", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Keynum: &KeyNum{KeyNumIdx: 0, FirstKey: 1, Step: 1}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"EXEC": {
+		Arity:      1,
+		Flags:      []string{"noscript", "loading", "stale", "skip_slowlog"},
+		Categories: []string{"transaction", "slow"},
+	},
+	"EXISTS": {
+		Arity:      -2,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"keyspace", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"EXPIRE": {
+		Arity:      -3,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"keyspace", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "UPDATE"},
+			},
+		},
+	},
+	"EXPIREAT": {
+		Arity:      -3,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"keyspace", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "UPDATE"},
+			},
+		},
+	},
+	"EXPIRETIME": {
+		Arity:      2,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"keyspace", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"FAILOVER": {
+		Arity:      -1,
+		Flags:      []string{"admin", "noscript", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"FCALL": {
+		Arity:      -3,
+		Flags:      []string{"noscript", "skip_monitor", "stale", "may_replicate", "no_mandatory_keys"},
+		Categories: []string{"scripting", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Keynum: &KeyNum{KeyNumIdx: 0, FirstKey: 1, Step: 1}},
+				Flags:       []string{"RW", "ACCESS", "UPDATE"},
+			},
+		},
+	},
+	"FCALL_RO": {
+		Arity:      -3,
+		Flags:      []string{"noscript", "skip_monitor", "readonly", "stale", "no_mandatory_keys"},
+		Categories: []string{"scripting", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Keynum: &KeyNum{KeyNumIdx: 0, FirstKey: 1, Step: 1}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"FLUSHALL": {
+		Arity:      -1,
+		Flags:      []string{"write"},
+		Categories: []string{"keyspace", "dangerous", "write", "slow"},
+	},
+	"FLUSHDB": {
+		Arity:      -1,
+		Flags:      []string{"write"},
+		Categories: []string{"keyspace", "dangerous", "write", "slow"},
+	},
+	"FUNCTION": {
+		Arity:      -2,
+		Flags:      nil,
+		Categories: []string{"scripting", "slow"},
+	},
+	"FUNCTION DELETE": {
+		Arity:      3,
+		Flags:      []string{"noscript", "write"},
+		Categories: []string{"scripting", "write", "slow"},
+	},
+	"FUNCTION DUMP": {
+		Arity:      2,
+		Flags:      []string{"noscript"},
+		Categories: []string{"scripting", "slow"},
+	},
+	"FUNCTION FLUSH": {
+		Arity:      -2,
+		Flags:      []string{"noscript", "write"},
+		Categories: []string{"scripting", "write", "slow"},
+	},
+	"FUNCTION HELP": {
+		Arity:      2,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"scripting", "slow"},
+	},
+	"FUNCTION KILL": {
+		Arity:      2,
+		Flags:      []string{"noscript", "allow_busy"},
+		Categories: []string{"scripting", "slow"},
+	},
+	"FUNCTION LIST": {
+		Arity:      -2,
+		Flags:      []string{"noscript"},
+		Categories: []string{"scripting", "slow"},
+	},
+	"FUNCTION LOAD": {
+		Arity:      -3,
+		Flags:      []string{"noscript", "write", "denyoom"},
+		Categories: []string{"scripting", "write", "slow"},
+	},
+	"FUNCTION RESTORE": {
+		Arity:      -3,
+		Flags:      []string{"noscript", "write", "denyoom"},
+		Categories: []string{"scripting", "write", "slow"},
+	},
+	"FUNCTION STATS": {
+		Arity:      2,
+		Flags:      []string{"noscript", "allow_busy"},
+		Categories: []string{"scripting", "slow"},
+	},
+	"GEOADD": {
+		Arity:      -5,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"geo", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "UPDATE"},
+			},
+		},
+	},
+	"GEODIST": {
+		Arity:      -4,
+		Flags:      []string{"readonly"},
+		Categories: []string{"geo", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"GEOHASH": {
+		Arity:      -2,
+		Flags:      []string{"readonly"},
+		Categories: []string{"geo", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"GEOPOS": {
+		Arity:      -2,
+		Flags:      []string{"readonly"},
+		Categories: []string{"geo", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"GEORADIUS": {
+		Arity:      -6,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"geo", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+			{
+				BeginSearch: BeginSearch{Keyword: "STORE", StartFrom: 6},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"OW", "UPDATE"},
+			},
+			{
+				BeginSearch: BeginSearch{Keyword: "STOREDIST", StartFrom: 6},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"OW", "UPDATE"},
+			},
+		},
+	},
+	"GEORADIUSBYMEMBER": {
+		Arity:      -5,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"geo", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+			{
+				BeginSearch: BeginSearch{Keyword: "STORE", StartFrom: 5},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"OW", "UPDATE"},
+			},
+			{
+				BeginSearch: BeginSearch{Keyword: "STOREDIST", StartFrom: 5},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"OW", "UPDATE"},
+			},
+		},
+	},
+	"GEORADIUSBYMEMBER_RO": {
+		Arity:      -5,
+		Flags:      []string{"readonly"},
+		Categories: []string{"geo", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"GEORADIUS_RO": {
+		Arity:      -6,
+		Flags:      []string{"readonly"},
+		Categories: []string{"geo", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"GEOSEARCH": {
+		Arity:      -7,
+		Flags:      []string{"readonly"},
+		Categories: []string{"geo", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"GEOSEARCHSTORE": {
+		Arity:      -8,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"geo", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"OW", "UPDATE"},
+			},
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"GET": {
+		Arity:      2,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"string", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"GETBIT": {
+		Arity:      3,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"bitmap", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"GETDEL": {
+		Arity:      2,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"string", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+		},
+	},
+	"GETEX": {
+		Arity:      -2,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"string", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "UPDATE"},
+			},
+		},
+	},
+	"GETRANGE": {
+		Arity:      4,
+		Flags:      []string{"readonly"},
+		Categories: []string{"string", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"GETSET": {
+		Arity:      3,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"string", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "UPDATE"},
+			},
+		},
+	},
+	"HDEL": {
+		Arity:      -3,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"hash", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "DELETE"},
+			},
+		},
+	},
+	"HELLO": {
+		Arity:      -1,
+		Flags:      []string{"noscript", "loading", "stale", "fast", "no_auth", "sentinel", "allow_busy"},
+		Categories: []string{"connection", "fast"},
+	},
+	"HEXISTS": {
+		Arity:      3,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"hash", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"HGET": {
+		Arity:      3,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"hash", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"HGETALL": {
+		Arity:      2,
+		Flags:      []string{"readonly"},
+		Categories: []string{"hash", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"HINCRBY": {
+		Arity:      4,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"hash", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "UPDATE"},
+			},
+		},
+	},
+	"HINCRBYFLOAT": {
+		Arity:      4,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"hash", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "UPDATE"},
+			},
+		},
+	},
+	"HKEYS": {
+		Arity:      2,
+		Flags:      []string{"readonly"},
+		Categories: []string{"hash", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"HLEN": {
+		Arity:      2,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"hash", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"HMGET": {
+		Arity:      -3,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"hash", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"HMSET": {
+		Arity:      -4,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"hash", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "UPDATE"},
+			},
+		},
+	},
+	"HRANDFIELD": {
+		Arity:      -2,
+		Flags:      []string{"readonly"},
+		Categories: []string{"hash", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"HSCAN": {
+		Arity:      -3,
+		Flags:      []string{"readonly"},
+		Categories: []string{"hash", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"HSET": {
+		Arity:      -4,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"hash", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "UPDATE"},
+			},
+		},
+	},
+	"HSETNX": {
+		Arity:      4,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"hash", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "INSERT"},
+			},
+		},
+	},
+	"HSTRLEN": {
+		Arity:      3,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"hash", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"HVALS": {
+		Arity:      2,
+		Flags:      []string{"readonly"},
+		Categories: []string{"hash", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"INCR": {
+		Arity:      2,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"string", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "UPDATE"},
+			},
+		},
+	},
+	"INCRBY": {
+		Arity:      3,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"string", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "UPDATE"},
+			},
+		},
+	},
+	"INCRBYFLOAT": {
+		Arity:      3,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"string", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "UPDATE"},
+			},
+		},
+	},
+	"INFO": {
+		Arity:      -1,
+		Flags:      []string{"loading", "stale", "sentinel"},
+		Categories: []string{"dangerous", "slow"},
+	},
+	"KEYS": {
+		Arity:      2,
+		Flags:      []string{"readonly"},
+		Categories: []string{"keyspace", "dangerous", "read", "slow"},
+	},
+	"LASTSAVE": {
+		Arity:      1,
+		Flags:      []string{"loading", "stale", "fast"},
+		Categories: []string{"admin", "dangerous", "fast"},
+	},
+	"LATENCY": {
+		Arity:      -2,
+		Flags:      nil,
+		Categories: []string{"slow"},
+	},
+	"LATENCY DOCTOR": {
+		Arity:      2,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"LATENCY GRAPH": {
+		Arity:      3,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"LATENCY HELP": {
+		Arity:      2,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"slow"},
+	},
+	"LATENCY HISTOGRAM": {
+		Arity:      -2,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"LATENCY HISTORY": {
+		Arity:      3,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"LATENCY LATEST": {
+		Arity:      2,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"LATENCY RESET": {
+		Arity:      -2,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"LCS": {
+		Arity:      -3,
+		Flags:      []string{"readonly"},
+		Categories: []string{"string", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 1, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"LINDEX": {
+		Arity:      3,
+		Flags:      []string{"readonly"},
+		Categories: []string{"list", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"LINSERT": {
+		Arity:      5,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"list", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "INSERT"},
+			},
+		},
+	},
+	"LLEN": {
+		Arity:      2,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"list", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"LMOVE": {
+		Arity:      5,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"list", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "INSERT"},
+			},
+		},
+	},
+	"LMPOP": {
+		Arity:      -4,
+		Flags:      []string{"write"},
+		Categories: []string{"list", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Keynum: &KeyNum{KeyNumIdx: 0, FirstKey: 1, Step: 1}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+		},
+	},
+	"LOLWUT": {
+		Arity:      -1,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"read", "fast"},
+	},
+	"LPOP": {
+		Arity:      -2,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"list", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+		},
+	},
+	"LPOS": {
+		Arity:      -3,
+		Flags:      []string{"readonly"},
+		Categories: []string{"list", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"LPUSH": {
+		Arity:      -3,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"list", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "INSERT"},
+			},
+		},
+	},
+	"LPUSHX": {
+		Arity:      -3,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"list", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "INSERT"},
+			},
+		},
+	},
+	"LRANGE": {
+		Arity:      4,
+		Flags:      []string{"readonly"},
+		Categories: []string{"list", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"LREM": {
+		Arity:      4,
+		Flags:      []string{"write"},
+		Categories: []string{"list", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "DELETE"},
+			},
+		},
+	},
+	"LSET": {
+		Arity:      4,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"list", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "UPDATE"},
+			},
+		},
+	},
+	"LTRIM": {
+		Arity:      4,
+		Flags:      []string{"write"},
+		Categories: []string{"list", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "DELETE"},
+			},
+		},
+	},
+	"MEMORY": {
+		Arity:      -2,
+		Flags:      nil,
+		Categories: []string{"slow"},
+	},
+	"MEMORY DOCTOR": {
+		Arity:      2,
+		Flags:      nil,
+		Categories: []string{"slow"},
+	},
+	"MEMORY HELP": {
+		Arity:      2,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"slow"},
+	},
+	"MEMORY MALLOC-STATS": {
+		Arity:      2,
+		Flags:      nil,
+		Categories: []string{"slow"},
+	},
+	"MEMORY PURGE": {
+		Arity:      2,
+		Flags:      nil,
+		Categories: []string{"slow"},
+	},
+	"MEMORY STATS": {
+		Arity:      2,
+		Flags:      nil,
+		Categories: []string{"slow"},
+	},
+	"MEMORY USAGE": {
+		Arity:      -3,
+		Flags:      []string{"readonly"},
+		Categories: []string{"read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"MGET": {
+		Arity:      -2,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"string", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"MIGRATE": {
+		Arity:      -6,
+		Flags:      []string{"write", "may_replicate"},
+		Categories: []string{"keyspace", "dangerous", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 3},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+			{
+				BeginSearch: BeginSearch{Keyword: "KEYS", StartFrom: -2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "DELETE", "INCOMPLETE"},
+			},
+		},
+	},
+	"MODULE": {
+		Arity:      -2,
+		Flags:      nil,
+		Categories: []string{"slow"},
+	},
+	"MODULE HELP": {
+		Arity:      2,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"slow"},
+	},
+	"MODULE LIST": {
+		Arity:      2,
+		Flags:      []string{"admin", "noscript", "no_multi"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"MODULE LOAD": {
+		Arity:      -3,
+		Flags:      []string{"admin", "noscript", "no_multi"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"MODULE LOADEX": {
+		Arity:      -3,
+		Flags:      []string{"admin", "noscript", "no_multi"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"MODULE UNLOAD": {
+		Arity:      3,
+		Flags:      []string{"admin", "noscript", "no_multi"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"MONITOR": {
+		Arity:      1,
+		Flags:      []string{"admin", "noscript", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"MOVE": {
+		Arity:      3,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"keyspace", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "UPDATE"},
+			},
+		},
+	},
+	"MSET": {
+		Arity:      -3,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"string", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 2, Limit: 0}},
+				Flags:       []string{"OW", "UPDATE"},
+			},
+		},
+	},
+	"MSETNX": {
+		Arity:      -3,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"string", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 2, Limit: 0}},
+				Flags:       []string{"OW", "INSERT"},
+			},
+		},
+	},
+	"MULTI": {
+		Arity:      1,
+		Flags:      []string{"noscript", "loading", "stale", "fast", "allow_busy"},
+		Categories: []string{"transaction", "fast"},
+	},
+	"OBJECT": {
+		Arity:      -2,
+		Flags:      nil,
+		Categories: []string{"keyspace", "slow"},
+	},
+	"OBJECT ENCODING": {
+		Arity:      3,
+		Flags:      []string{"readonly"},
+		Categories: []string{"keyspace", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"OBJECT FREQ": {
+		Arity:      3,
+		Flags:      []string{"readonly"},
+		Categories: []string{"keyspace", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"OBJECT HELP": {
+		Arity:      2,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"keyspace", "slow"},
+	},
+	"OBJECT IDLETIME": {
+		Arity:      3,
+		Flags:      []string{"readonly"},
+		Categories: []string{"keyspace", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"OBJECT REFCOUNT": {
+		Arity:      3,
+		Flags:      []string{"readonly"},
+		Categories: []string{"keyspace", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"PERSIST": {
+		Arity:      2,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"keyspace", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "UPDATE"},
+			},
+		},
+	},
+	"PEXPIRE": {
+		Arity:      -3,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"keyspace", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "UPDATE"},
+			},
+		},
+	},
+	"PEXPIREAT": {
+		Arity:      -3,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"keyspace", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "UPDATE"},
+			},
+		},
+	},
+	"PEXPIRETIME": {
+		Arity:      2,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"keyspace", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"PFADD": {
+		Arity:      -2,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"hyperloglog", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "INSERT"},
+			},
+		},
+	},
+	"PFCOUNT": {
+		Arity:      -2,
+		Flags:      []string{"readonly", "may_replicate"},
+		Categories: []string{"hyperloglog", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS"},
+			},
+		},
+	},
+	"PFDEBUG": {
+		Arity:      3,
+		Flags:      []string{"write", "denyoom", "admin"},
+		Categories: []string{"hyperloglog", "write", "admin", "dangerous", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS"},
+			},
+		},
+	},
+	"PFMERGE": {
+		Arity:      -2,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"hyperloglog", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "INSERT"},
+			},
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"PFSELFTEST": {
+		Arity:      1,
+		Flags:      []string{"admin"},
+		Categories: []string{"hyperloglog", "admin", "dangerous", "slow"},
+	},
+	"PING": {
+		Arity:      -1,
+		Flags:      []string{"fast", "sentinel"},
+		Categories: []string{"connection", "fast"},
+	},
+	"PSETEX": {
+		Arity:      4,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"string", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"OW", "UPDATE"},
+			},
+		},
+	},
+	"PSUBSCRIBE": {
+		Arity:      -2,
+		Flags:      []string{"pubsub", "noscript", "loading", "stale"},
+		Categories: []string{"pubsub", "slow"},
+	},
+	"PSYNC": {
+		Arity:      -3,
+		Flags:      []string{"admin", "noscript", "no_multi", "no_async_loading"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"PTTL": {
+		Arity:      2,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"keyspace", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"PUBLISH": {
+		Arity:      3,
+		Flags:      []string{"pubsub", "loading", "stale", "fast", "may_replicate"},
+		Categories: []string{"pubsub", "fast"},
+	},
+	"PUBSUB": {
+		Arity:      -2,
+		Flags:      nil,
+		Categories: []string{"slow"},
+	},
+	"PUBSUB CHANNELS": {
+		Arity:      -2,
+		Flags:      []string{"pubsub", "loading", "stale"},
+		Categories: []string{"pubsub", "slow"},
+	},
+	"PUBSUB HELP": {
+		Arity:      2,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"slow"},
+	},
+	"PUBSUB NUMPAT": {
+		Arity:      2,
+		Flags:      []string{"pubsub", "loading", "stale"},
+		Categories: []string{"pubsub", "slow"},
+	},
+	"PUBSUB NUMSUB": {
+		Arity:      -2,
+		Flags:      []string{"pubsub", "loading", "stale"},
+		Categories: []string{"pubsub", "slow"},
+	},
+	"PUBSUB SHARDCHANNELS": {
+		Arity:      -2,
+		Flags:      []string{"pubsub", "loading", "stale"},
+		Categories: []string{"pubsub", "slow"},
+	},
+	"PUBSUB SHARDNUMSUB": {
+		Arity:      -2,
+		Flags:      []string{"pubsub", "loading", "stale"},
+		Categories: []string{"pubsub", "slow"},
+	},
+	"PUNSUBSCRIBE": {
+		Arity:      -1,
+		Flags:      []string{"pubsub", "noscript", "loading", "stale"},
+		Categories: []string{"pubsub", "slow"},
+	},
+	"QUIT": {
+		Arity:      -1,
+		Flags:      []string{"allow_busy", "no_auth", "noscript", "loading", "stale", "fast"},
+		Categories: []string{"connection", "fast"},
+	},
+	"RANDOMKEY": {
+		Arity:      1,
+		Flags:      []string{"readonly"},
+		Categories: []string{"keyspace", "read", "slow"},
+	},
+	"READONLY": {
+		Arity:      1,
+		Flags:      []string{"loading", "stale", "fast"},
+		Categories: []string{"connection", "fast"},
+	},
+	"READWRITE": {
+		Arity:      1,
+		Flags:      []string{"loading", "stale", "fast"},
+		Categories: []string{"connection", "fast"},
+	},
+	"RENAME": {
+		Arity:      3,
+		Flags:      []string{"write"},
+		Categories: []string{"keyspace", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"OW", "UPDATE"},
+			},
+		},
+	},
+	"RENAMENX": {
+		Arity:      3,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"keyspace", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"OW", "INSERT"},
+			},
+		},
+	},
+	"REPLCONF": {
+		Arity:      -1,
+		Flags:      []string{"admin", "noscript", "loading", "stale", "allow_busy"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"REPLICAOF": {
+		Arity:      3,
+		Flags:      []string{"admin", "noscript", "stale", "no_multi"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"RESET": {
+		Arity:      1,
+		Flags:      []string{"noscript", "loading", "stale", "fast", "no_auth", "allow_busy"},
+		Categories: []string{"connection", "fast"},
+	},
+	"RESTORE": {
+		Arity:      -4,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"keyspace", "dangerous", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"OW", "UPDATE"},
+			},
+		},
+	},
+	"RESTORE-ASKING": {
+		Arity:      -4,
+		Flags:      []string{"write", "denyoom", "asking"},
+		Categories: []string{"keyspace", "dangerous", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"OW", "UPDATE"},
+			},
+		},
+	},
+	"ROLE": {
+		Arity:      1,
+		Flags:      []string{"noscript", "loading", "stale", "fast", "sentinel"},
+		Categories: []string{"admin", "dangerous", "fast"},
+	},
+	"RPOP": {
+		Arity:      -2,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"list", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+		},
+	},
+	"RPOPLPUSH": {
+		Arity:      3,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"list", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "INSERT"},
+			},
+		},
+	},
+	"RPUSH": {
+		Arity:      -3,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"list", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "INSERT"},
+			},
+		},
+	},
+	"RPUSHX": {
+		Arity:      -3,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"list", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "INSERT"},
+			},
+		},
+	},
+	"SADD": {
+		Arity:      -3,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"set", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "INSERT"},
+			},
+		},
+	},
+	"SAVE": {
+		Arity:      1,
+		Flags:      []string{"admin", "noscript", "no_multi", "no_async_loading"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"SCAN": {
+		Arity:      -2,
+		Flags:      []string{"readonly"},
+		Categories: []string{"keyspace", "read", "slow"},
+	},
+	"SCARD": {
+		Arity:      2,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"set", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"SCRIPT": {
+		Arity:      -2,
+		Flags:      nil,
+		Categories: []string{"scripting", "slow"},
+	},
+	"SCRIPT DEBUG": {
+		Arity:      3,
+		Flags:      []string{"noscript"},
+		Categories: []string{"scripting", "slow"},
+	},
+	"SCRIPT EXISTS": {
+		Arity:      -3,
+		Flags:      []string{"noscript"},
+		Categories: []string{"scripting", "slow"},
+	},
+	"SCRIPT FLUSH": {
+		Arity:      -2,
+		Flags:      []string{"noscript"},
+		Categories: []string{"scripting", "slow"},
+	},
+	"SCRIPT HELP": {
+		Arity:      2,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"scripting", "slow"},
+	},
+	"SCRIPT KILL": {
+		Arity:      2,
+		Flags:      []string{"noscript", "allow_busy"},
+		Categories: []string{"scripting", "slow"},
+	},
+	"SCRIPT LOAD": {
+		Arity:      3,
+		Flags:      []string{"noscript", "stale"},
+		Categories: []string{"scripting", "slow"},
+	},
+	"SDIFF": {
+		Arity:      -2,
+		Flags:      []string{"readonly"},
+		Categories: []string{"set", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"SDIFFSTORE": {
+		Arity:      -3,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"set", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"OW", "UPDATE"},
+			},
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"SELECT": {
+		Arity:      2,
+		Flags:      []string{"loading", "stale", "fast"},
+		Categories: []string{"connection", "fast"},
+	},
+	"SET": {
+		Arity:      -3,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"string", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "UPDATE", "VARIABLE_FLAGS"},
+			},
+		},
+	},
+	"SETBIT": {
+		Arity:      4,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"bitmap", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "UPDATE"},
+			},
+		},
+	},
+	"SETEX": {
+		Arity:      4,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"string", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"OW", "UPDATE"},
+			},
+		},
+	},
+	"SETNX": {
+		Arity:      3,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"string", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"OW", "INSERT"},
+			},
+		},
+	},
+	"SETRANGE": {
+		Arity:      4,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"string", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "UPDATE"},
+			},
+		},
+	},
+	"SHUTDOWN": {
+		Arity:      -1,
+		Flags:      []string{"admin", "noscript", "loading", "stale", "no_multi", "sentinel"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"SINTER": {
+		Arity:      -2,
+		Flags:      []string{"readonly"},
+		Categories: []string{"set", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"SINTERCARD": {
+		Arity:      -3,
+		Flags:      []string{"readonly"},
+		Categories: []string{"set", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Keynum: &KeyNum{KeyNumIdx: 0, FirstKey: 1, Step: 1}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"SINTERSTORE": {
+		Arity:      -3,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"set", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"OW", "UPDATE"},
+			},
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"SISMEMBER": {
+		Arity:      3,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"set", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"SLAVEOF": {
+		Arity:      3,
+		Flags:      []string{"admin", "noscript", "stale", "no_multi"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"SLOWLOG": {
+		Arity:      -2,
+		Flags:      nil,
+		Categories: []string{"slow"},
+	},
+	"SLOWLOG GET": {
+		Arity:      -2,
+		Flags:      []string{"admin", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"SLOWLOG HELP": {
+		Arity:      2,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"slow"},
+	},
+	"SLOWLOG LEN": {
+		Arity:      2,
+		Flags:      []string{"admin", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"SLOWLOG RESET": {
+		Arity:      2,
+		Flags:      []string{"admin", "loading", "stale"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"SMEMBERS": {
+		Arity:      2,
+		Flags:      []string{"readonly"},
+		Categories: []string{"set", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"SMISMEMBER": {
+		Arity:      -3,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"set", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"SMOVE": {
+		Arity:      4,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"set", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "INSERT"},
+			},
+		},
+	},
+	"SORT": {
+		Arity:      -2,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"set", "sortedset", "list", "dangerous", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+			{
+				Flags: []string{"OW", "UPDATE"},
+			},
+		},
+	},
+	"SORT_RO": {
+		Arity:      -2,
+		Flags:      []string{"readonly"},
+		Categories: []string{"set", "sortedset", "list", "dangerous", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"SPOP": {
+		Arity:      -2,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"set", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+		},
+	},
+	"SPUBLISH": {
+		Arity:      3,
+		Flags:      []string{"pubsub", "loading", "stale", "fast", "may_replicate"},
+		Categories: []string{"pubsub", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"NOT_KEY"},
+			},
+		},
+	},
+	"SRANDMEMBER": {
+		Arity:      -2,
+		Flags:      []string{"readonly"},
+		Categories: []string{"set", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"SREM": {
+		Arity:      -3,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"set", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "DELETE"},
+			},
+		},
+	},
+	"SSCAN": {
+		Arity:      -3,
+		Flags:      []string{"readonly"},
+		Categories: []string{"set", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"SSUBSCRIBE": {
+		Arity:      -2,
+		Flags:      []string{"pubsub", "noscript", "loading", "stale"},
+		Categories: []string{"pubsub", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 1, Limit: 0}},
+				Flags:       []string{"NOT_KEY"},
+			},
+		},
+	},
+	"STRLEN": {
+		Arity:      2,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"string", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"SUBSCRIBE": {
+		Arity:      -2,
+		Flags:      []string{"pubsub", "noscript", "loading", "stale"},
+		Categories: []string{"pubsub", "slow"},
+	},
+	"SUBSTR": {
+		Arity:      4,
+		Flags:      []string{"readonly"},
+		Categories: []string{"string", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"SUNION": {
+		Arity:      -2,
+		Flags:      []string{"readonly"},
+		Categories: []string{"set", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"SUNIONSTORE": {
+		Arity:      -3,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"set", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"OW", "UPDATE"},
+			},
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"SUNSUBSCRIBE": {
+		Arity:      -1,
+		Flags:      []string{"pubsub", "noscript", "loading", "stale"},
+		Categories: []string{"pubsub", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 1, Limit: 0}},
+				Flags:       []string{"NOT_KEY"},
+			},
+		},
+	},
+	"SWAPDB": {
+		Arity:      3,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"keyspace", "dangerous", "write", "fast"},
+	},
+	"SYNC": {
+		Arity:      1,
+		Flags:      []string{"admin", "noscript", "no_multi", "no_async_loading"},
+		Categories: []string{"admin", "dangerous", "slow"},
+	},
+	"TIME": {
+		Arity:      1,
+		Flags:      []string{"loading", "stale", "fast"},
+		Categories: []string{"fast"},
+	},
+	"TOUCH": {
+		Arity:      -2,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"keyspace", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"TTL": {
+		Arity:      2,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"keyspace", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"TYPE": {
+		Arity:      2,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"keyspace", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"UNLINK": {
+		Arity:      -2,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"keyspace", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 1, Limit: 0}},
+				Flags:       []string{"RM", "DELETE"},
+			},
+		},
+	},
+	"UNSUBSCRIBE": {
+		Arity:      -1,
+		Flags:      []string{"pubsub", "noscript", "loading", "stale"},
+		Categories: []string{"pubsub", "slow"},
+	},
+	"UNWATCH": {
+		Arity:      1,
+		Flags:      []string{"noscript", "loading", "stale", "fast", "allow_busy"},
+		Categories: []string{"transaction", "fast"},
+	},
+	"WAIT": {
+		Arity:      3,
+		Flags:      nil,
+		Categories: []string{"connection", "slow"},
+	},
+	"WAITAOF": {
+		Arity:      4,
+		Flags:      []string{"noscript"},
+		Categories: []string{"connection", "slow"},
+	},
+	"WATCH": {
+		Arity:      -2,
+		Flags:      []string{"noscript", "loading", "stale", "fast", "allow_busy"},
+		Categories: []string{"transaction", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"XACK": {
+		Arity:      -4,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"stream", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "UPDATE"},
+			},
+		},
+	},
+	"XADD": {
+		Arity:      -5,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"stream", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "UPDATE"},
+			},
+		},
+	},
+	"XAUTOCLAIM": {
+		Arity:      -6,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"stream", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "UPDATE"},
+			},
+		},
+	},
+	"XCLAIM": {
+		Arity:      -6,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"stream", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "UPDATE"},
+			},
+		},
+	},
+	"XDEL": {
+		Arity:      -3,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"stream", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "DELETE"},
+			},
+		},
+	},
+	"XGROUP": {
+		Arity:      -2,
+		Flags:      nil,
+		Categories: []string{"stream", "slow"},
+	},
+	"XGROUP CREATE": {
+		Arity:      -5,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"stream", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "INSERT"},
+			},
+		},
+	},
+	"XGROUP CREATECONSUMER": {
+		Arity:      5,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"stream", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "INSERT"},
+			},
+		},
+	},
+	"XGROUP DELCONSUMER": {
+		Arity:      5,
+		Flags:      []string{"write"},
+		Categories: []string{"stream", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "DELETE"},
+			},
+		},
+	},
+	"XGROUP DESTROY": {
+		Arity:      4,
+		Flags:      []string{"write"},
+		Categories: []string{"stream", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "DELETE"},
+			},
+		},
+	},
+	"XGROUP HELP": {
+		Arity:      2,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"stream", "slow"},
+	},
+	"XGROUP SETID": {
+		Arity:      -5,
+		Flags:      []string{"write"},
+		Categories: []string{"stream", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "UPDATE"},
+			},
+		},
+	},
+	"XINFO": {
+		Arity:      -2,
+		Flags:      nil,
+		Categories: []string{"stream", "slow"},
+	},
+	"XINFO CONSUMERS": {
+		Arity:      4,
+		Flags:      []string{"readonly"},
+		Categories: []string{"stream", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"XINFO GROUPS": {
+		Arity:      3,
+		Flags:      []string{"readonly"},
+		Categories: []string{"stream", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"XINFO HELP": {
+		Arity:      2,
+		Flags:      []string{"loading", "stale"},
+		Categories: []string{"stream", "slow"},
+	},
+	"XINFO STREAM": {
+		Arity:      -3,
+		Flags:      []string{"readonly"},
+		Categories: []string{"stream", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"XLEN": {
+		Arity:      2,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"stream", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"XPENDING": {
+		Arity:      -3,
+		Flags:      []string{"readonly"},
+		Categories: []string{"stream", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"XRANGE": {
+		Arity:      -4,
+		Flags:      []string{"readonly"},
+		Categories: []string{"stream", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"XREAD": {
+		Arity:      -4,
+		Flags:      []string{"readonly", "blocking"},
+		Categories: []string{"stream", "read", "blocking", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Keyword: "STREAMS", StartFrom: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 1, Limit: 2}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"XREADGROUP": {
+		Arity:      -7,
+		Flags:      []string{"write", "blocking"},
+		Categories: []string{"stream", "write", "blocking", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Keyword: "STREAMS", StartFrom: 4},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 1, Limit: 2}},
+				Flags:       []string{"RW", "ACCESS"},
+			},
+		},
+	},
+	"XREVRANGE": {
+		Arity:      -4,
+		Flags:      []string{"readonly"},
+		Categories: []string{"stream", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"XSETID": {
+		Arity:      -3,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"stream", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "UPDATE"},
+			},
+		},
+	},
+	"XTRIM": {
+		Arity:      -4,
+		Flags:      []string{"write"},
+		Categories: []string{"stream", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "DELETE"},
+			},
+		},
+	},
+	"ZADD": {
+		Arity:      -4,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"sortedset", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "UPDATE"},
+			},
+		},
+	},
+	"ZCARD": {
+		Arity:      2,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"sortedset", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"ZCOUNT": {
+		Arity:      4,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"sortedset", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"ZDIFF": {
+		Arity:      -3,
+		Flags:      []string{"readonly"},
+		Categories: []string{"sortedset", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Keynum: &KeyNum{KeyNumIdx: 0, FirstKey: 1, Step: 1}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"ZDIFFSTORE": {
+		Arity:      -4,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"sortedset", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"OW", "UPDATE"},
+			},
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Keynum: &KeyNum{KeyNumIdx: 0, FirstKey: 1, Step: 1}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"ZINCRBY": {
+		Arity:      4,
+		Flags:      []string{"write", "denyoom", "fast"},
+		Categories: []string{"sortedset", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "UPDATE"},
+			},
+		},
+	},
+	"ZINTER": {
+		Arity:      -3,
+		Flags:      []string{"readonly"},
+		Categories: []string{"sortedset", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Keynum: &KeyNum{KeyNumIdx: 0, FirstKey: 1, Step: 1}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"ZINTERCARD": {
+		Arity:      -3,
+		Flags:      []string{"readonly"},
+		Categories: []string{"sortedset", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Keynum: &KeyNum{KeyNumIdx: 0, FirstKey: 1, Step: 1}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"ZINTERSTORE": {
+		Arity:      -4,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"sortedset", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"OW", "UPDATE"},
+			},
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Keynum: &KeyNum{KeyNumIdx: 0, FirstKey: 1, Step: 1}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"ZLEXCOUNT": {
+		Arity:      4,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"sortedset", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"ZMPOP": {
+		Arity:      -4,
+		Flags:      []string{"write"},
+		Categories: []string{"sortedset", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Keynum: &KeyNum{KeyNumIdx: 0, FirstKey: 1, Step: 1}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+		},
+	},
+	"ZMSCORE": {
+		Arity:      -3,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"sortedset", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"ZPOPMAX": {
+		Arity:      -2,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"sortedset", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+		},
+	},
+	"ZPOPMIN": {
+		Arity:      -2,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"sortedset", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "ACCESS", "DELETE"},
+			},
+		},
+	},
+	"ZRANDMEMBER": {
+		Arity:      -2,
+		Flags:      []string{"readonly"},
+		Categories: []string{"sortedset", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"ZRANGE": {
+		Arity:      -4,
+		Flags:      []string{"readonly"},
+		Categories: []string{"sortedset", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"ZRANGEBYLEX": {
+		Arity:      -4,
+		Flags:      []string{"readonly"},
+		Categories: []string{"sortedset", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"ZRANGEBYSCORE": {
+		Arity:      -4,
+		Flags:      []string{"readonly"},
+		Categories: []string{"sortedset", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"ZRANGESTORE": {
+		Arity:      -5,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"sortedset", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"OW", "UPDATE"},
+			},
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"ZRANK": {
+		Arity:      -3,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"sortedset", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"ZREM": {
+		Arity:      -3,
+		Flags:      []string{"write", "fast"},
+		Categories: []string{"sortedset", "write", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "DELETE"},
+			},
+		},
+	},
+	"ZREMRANGEBYLEX": {
+		Arity:      4,
+		Flags:      []string{"write"},
+		Categories: []string{"sortedset", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "DELETE"},
+			},
+		},
+	},
+	"ZREMRANGEBYRANK": {
+		Arity:      4,
+		Flags:      []string{"write"},
+		Categories: []string{"sortedset", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "DELETE"},
+			},
+		},
+	},
+	"ZREMRANGEBYSCORE": {
+		Arity:      4,
+		Flags:      []string{"write"},
+		Categories: []string{"sortedset", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RW", "DELETE"},
+			},
+		},
+	},
+	"ZREVRANGE": {
+		Arity:      -4,
+		Flags:      []string{"readonly"},
+		Categories: []string{"sortedset", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"ZREVRANGEBYLEX": {
+		Arity:      -4,
+		Flags:      []string{"readonly"},
+		Categories: []string{"sortedset", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"ZREVRANGEBYSCORE": {
+		Arity:      -4,
+		Flags:      []string{"readonly"},
+		Categories: []string{"sortedset", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"ZREVRANK": {
+		Arity:      -3,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"sortedset", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO"},
+			},
+		},
+	},
+	"ZSCAN": {
+		Arity:      -3,
+		Flags:      []string{"readonly"},
+		Categories: []string{"sortedset", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"ZSCORE": {
+		Arity:      3,
+		Flags:      []string{"readonly", "fast"},
+		Categories: []string{"sortedset", "read", "fast"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"ZUNION": {
+		Arity:      -3,
+		Flags:      []string{"readonly"},
+		Categories: []string{"sortedset", "read", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Keynum: &KeyNum{KeyNumIdx: 0, FirstKey: 1, Step: 1}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+	"ZUNIONSTORE": {
+		Arity:      -4,
+		Flags:      []string{"write", "denyoom"},
+		Categories: []string{"sortedset", "write", "slow"},
+		KeySpecs: []KeySpec{
+			{
+				BeginSearch: BeginSearch{Index: 1},
+				FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1, Limit: 0}},
+				Flags:       []string{"OW", "UPDATE"},
+			},
+			{
+				BeginSearch: BeginSearch{Index: 2},
+				FindKeys:    FindKeys{Keynum: &KeyNum{KeyNumIdx: 0, FirstKey: 1, Step: 1}},
+				Flags:       []string{"RO", "ACCESS"},
+			},
+		},
+	},
+}
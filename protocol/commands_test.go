@@ -0,0 +1,117 @@
+package protocol
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/awinterman/anarchoredis/protocol/message"
+	"gotest.tools/v3/assert"
+)
+
+func TestCmd(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		cmd   string
+		args  []string
+		keys  []string
+		write bool
+	}{
+		{"GET", "*2\r\n$3\r\nget\r\n$3\r\nfoo\r\n", "GET", []string{"foo"}, []string{"foo"}, false},
+		{"MSET", "*5\r\n$4\r\nMSET\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n$1\r\n2\r\n", "MSET",
+			[]string{"a", "1", "b", "2"}, []string{"a", "b"}, true},
+		{"LCS", "*3\r\n$3\r\nLCS\r\n$2\r\nk1\r\n$2\r\nk2\r\n", "LCS", []string{"k1", "k2"}, []string{"k1", "k2"}, false},
+		{"subcommand", "*3\r\n$6\r\nCONFIG\r\n$3\r\nGET\r\n$4\r\nsave\r\n", "CONFIG GET", []string{"save"}, nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encoder := message.Encoder{}
+			msg, err := encoder.Decode(strings.NewReader(test.input))
+			assert.NilError(t, err)
+
+			cmd, err := Cmd(msg)
+			assert.NilError(t, err)
+			assert.Equal(t, cmd.Name, test.cmd)
+
+			var args []string
+			for _, arg := range cmd.Args {
+				args = append(args, string(arg))
+			}
+			assert.DeepEqual(t, args, test.args)
+
+			// the arguments can be read any number of times
+			for range 2 {
+				keys, err := cmd.Keys()
+				assert.NilError(t, err)
+				assert.DeepEqual(t, keys, test.keys)
+			}
+			assert.Equal(t, cmd.IsWrite(), test.write)
+		})
+	}
+}
+
+func TestCmd_Trailer(t *testing.T) {
+	old := LazyArgLen
+	LazyArgLen = 8
+	defer func() { LazyArgLen = old }()
+
+	value := strings.Repeat("v", 16)
+	r := bufio.NewReader(strings.NewReader("*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$16\r\n" + value + "\r\n:1\r\n"))
+	encoder := message.Encoder{}
+	msg, err := encoder.Decode(r)
+	assert.NilError(t, err)
+
+	cmd, err := Cmd(msg)
+	assert.NilError(t, err)
+	assert.Equal(t, cmd.NArgs(), 2)
+	assert.Equal(t, cmd.String(), "SET key (16 bytes)")
+	keys, err := cmd.Keys()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, keys, []string{"key"})
+
+	all, err := cmd.Trailer.ReadAll()
+	assert.NilError(t, err)
+	assert.Equal(t, all, value)
+
+	next, err := encoder.Decode(r)
+	assert.NilError(t, err)
+	assert.Equal(t, next.Int, int64(1))
+}
+
+// TestCmd_Scripts tests that scripts and functions are locked on their declared keys unless they are read-only, and
+// that loading them is serialised against everything else.
+func TestCmd_Scripts(t *testing.T) {
+	tests := []struct {
+		argv        []string
+		keys        []string
+		write       bool
+		serializing bool
+	}{
+		{[]string{"EVAL", "return redis.call('SET', KEYS[1], ARGV[1])", "1", "k", "v"}, []string{"k"}, true, false},
+		{[]string{"EVALSHA", "e0e1f9fabfc9d4800c877a703b823ac0578ff8db", "2", "a", "b"}, []string{"a", "b"}, true, false},
+		{[]string{"EVAL", "return 1", "0"}, nil, true, false},
+		{[]string{"EVAL_RO", "return redis.call('GET', KEYS[1])", "1", "k"}, []string{"k"}, false, false},
+		{[]string{"EVALSHA_RO", "e0e1f9fabfc9d4800c877a703b823ac0578ff8db", "1", "k"}, []string{"k"}, false, false},
+		{[]string{"FCALL", "myfunc", "2", "a", "b", "arg"}, []string{"a", "b"}, true, false},
+		{[]string{"FCALL_RO", "myfunc", "1", "a"}, []string{"a"}, false, false},
+		{[]string{"SCRIPT", "LOAD", "return 1"}, nil, false, true},
+		{[]string{"FUNCTION", "LOAD", "#!lua name=mylib\n"}, nil, true, true},
+		{[]string{"FUNCTION", "DELETE", "mylib"}, nil, true, true},
+		{[]string{"FUNCTION", "LIST"}, nil, false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.argv[:2], " "), func(t *testing.T) {
+			cmd, err := Cmd(*NewOutgoingCommand(test.argv...))
+			assert.NilError(t, err)
+
+			keys, err := cmd.Keys()
+			assert.NilError(t, err)
+			assert.DeepEqual(t, keys, test.keys)
+			assert.Equal(t, cmd.IsWrite(), test.write)
+			assert.Equal(t, cmd.IsSerializing(), test.serializing)
+		})
+	}
+}
@@ -29,18 +29,73 @@ func NewConnection(conn io.ReadWriter) *Conn {
 }
 
 // Conn represents a thread-safe connection that provides read, write, and logging capabilities.
+//
+// Reads and writes are locked separately, so that one goroutine can write requests while another reads replies. The
+// embedded Mutex locks writes.
 type Conn struct {
 	sync.Mutex
+	readMu sync.Mutex
+
 	RW      *bufio.ReadWriter
 	Logger  *slog.Logger
 	Encoder message.Encoder
+	Parser  message.Parser
+
+	// frame is the message last returned by ReadFrame, which is released by the next read.
+	frame *Message
+}
+
+// Frame is a message exactly as it was read off the wire, along with its parsed form.
+type Frame struct {
+	// Raw is the encoded message.
+	Raw []byte
+	// Message is Raw decoded; its payloads point into Raw.
+	Message *Message
+}
+
+// Command parses the frame as a client request.
+func (f Frame) Command() (*Command, error) {
+	return Cmd(*f.Message)
 }
 
 // Read locks the connection, reads a message using the connection's SubStream, and returns the parsed message or an error.
 func (conn *Conn) Read() (Message, error) {
+	conn.readMu.Lock()
+	defer conn.readMu.Unlock()
+	return conn.read()
+}
+
+func (conn *Conn) read() (Message, error) {
+	conn.releaseFrame()
+	return conn.Encoder.Decode(conn.RW.Reader)
+}
+
+// ReadFrame locks the connection and reads a message without copying it, so that it can be forwarded verbatim with
+// WriteRaw. The frame is only valid until the next read from the connection.
+func (conn *Conn) ReadFrame() (Frame, error) {
+	conn.readMu.Lock()
+	defer conn.readMu.Unlock()
+	conn.releaseFrame()
+	msg, raw, err := conn.Parser.ParseFrame(conn.RW.Reader)
+	if err != nil {
+		return Frame{}, err
+	}
+	conn.frame = msg
+	return Frame{Raw: raw, Message: msg}, nil
+}
+
+func (conn *Conn) releaseFrame() {
+	if conn.frame != nil {
+		conn.Parser.Release(conn.frame)
+		conn.frame = nil
+	}
+}
+
+// WriteRaw writes already encoded messages to the connection's write buffer.
+func (conn *Conn) WriteRaw(raw []byte) (int, error) {
 	conn.Lock()
 	defer conn.Unlock()
-	return conn.Encoder.Decode(conn.RW.Reader)
+	return conn.RW.Write(raw)
 }
 
 // Write writes the provided Message to the connection's SubStream and returns the number of bytes written or an error.
@@ -59,6 +114,8 @@ func (conn *Conn) Flush() error {
 
 // RawRoundtrip sends raw byte data through the connection, flushes it, and reads the response as a Message.
 func (conn *Conn) RawRoundtrip(data []byte) (Message, error) {
+	conn.readMu.Lock()
+	defer conn.readMu.Unlock()
 	conn.Lock()
 	defer conn.Unlock()
 	_, err := conn.RW.Write(data)
@@ -70,7 +127,7 @@ func (conn *Conn) RawRoundtrip(data []byte) (Message, error) {
 		return Message{}, err
 	}
 
-	msg, err := conn.Read()
+	msg, err := conn.read()
 	if err != nil {
 		return Message{}, err
 	}
@@ -0,0 +1,82 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// TestConnReadFrame tests that frames can be forwarded verbatim while their command name and keys are inspected.
+func TestConnReadFrame(t *testing.T) {
+	large := strings.Repeat("x", 10000)
+	input := "*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$3\r\nbar\r\n" +
+		"*3\r\n$3\r\nset\r\n$5\r\nlarge\r\n$10000\r\n" + large + "\r\n" +
+		"*5\r\n$4\r\nMSET\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n$1\r\n2\r\n" +
+		"*2\r\n$3\r\nGET\r\n$3\r\nfoo\r\n"
+
+	out := &bytes.Buffer{}
+	conn := &Conn{RW: bufio.NewReadWriter(bufio.NewReader(strings.NewReader(input)), bufio.NewWriter(out))}
+
+	type header struct {
+		Name string
+		Keys []string
+	}
+	var headers []header
+	for {
+		frame, err := conn.ReadFrame()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		assert.NilError(t, err)
+
+		cmd, err := frame.Command()
+		assert.NilError(t, err)
+		keys, err := cmd.Keys()
+		assert.NilError(t, err)
+		headers = append(headers, header{cmd.Name, keys})
+
+		_, err = conn.WriteRaw(frame.Raw)
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, conn.Flush())
+
+	assert.Equal(t, out.String(), input)
+	assert.DeepEqual(t, headers, []header{
+		{"SET", []string{"foo"}},
+		{"SET", []string{"large"}},
+		{"MSET", []string{"a", "b"}},
+		{"GET", []string{"foo"}},
+	})
+}
+
+// TestConnRawRoundtrip tests sending raw bytes and reading the reply.
+func TestConnRawRoundtrip(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		upstream := NewConnection(server)
+		frame, err := upstream.ReadFrame()
+		if err != nil {
+			return
+		}
+		reply := "-ERR unexpected\r\n"
+		if bytes.Equal(frame.Raw, []byte("*1\r\n$4\r\nPING\r\n")) {
+			reply = "+PONG\r\n"
+		}
+		_, _ = upstream.WriteRaw([]byte(reply))
+		_ = upstream.Flush()
+	}()
+
+	conn := NewConnection(client)
+	msg, err := conn.RawRoundtrip([]byte("*1\r\n$4\r\nPING\r\n"))
+	assert.NilError(t, err)
+	assert.Equal(t, msg.SimpleString, "PONG")
+}
@@ -0,0 +1,208 @@
+package protocol
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"sync/atomic"
+
+	"github.com/awinterman/anarchoredis/protocol/message"
+)
+
+// registry is the set of commands known to Cmd and Command.Keys.
+type registry struct {
+	specs      map[string]CommandSpecification
+	containers map[string]bool
+}
+
+// commands is replaced rather than modified, so that it can be read without locking.
+var commands atomic.Pointer[registry]
+
+func init() {
+	commands.Store(&registry{specs: cmdSpec, containers: commandContainers})
+}
+
+// Specification returns the specification of the named command, e.g. GET or CONFIG GET.
+func Specification(name string) (CommandSpecification, bool) {
+	spec, ok := commands.Load().specs[name]
+	return spec, ok
+}
+
+// Register adds specs to the known commands, replacing any of the same name. Commands named with a space, e.g.
+// CONFIG GET, are subcommands of the container named before it.
+func Register(specs map[string]CommandSpecification) {
+	for {
+		old := commands.Load()
+		next := &registry{specs: maps.Clone(old.specs), containers: maps.Clone(old.containers)}
+		for name, spec := range specs {
+			next.specs[name] = spec
+			if container, _, ok := strings.Cut(name, " "); ok {
+				next.containers[container] = true
+			}
+		}
+		if commands.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+// Discover asks the server on conn for the specification of every command it supports using COMMAND INFO, so that
+// the keys can be found for commands missing from the generated table, such as those of modules, or those of a
+// newer server. The result can be passed to Register.
+func Discover(conn *Conn) (map[string]CommandSpecification, error) {
+	_, err := conn.Write(*NewOutgoingCommand("COMMAND", "INFO"))
+	if err != nil {
+		return nil, err
+	}
+	err = conn.Flush()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.Read()
+	if err != nil {
+		return nil, err
+	}
+	reply, err = message.Materialize(reply, -1)
+	if err != nil {
+		return nil, err
+	}
+	if reply.Kind == Error || reply.Kind == BulkError {
+		return nil, fmt.Errorf("COMMAND INFO: %s", reply)
+	}
+
+	specs := map[string]CommandSpecification{}
+	for _, info := range reply.Elems {
+		err := commandInfo(info, specs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return specs, nil
+}
+
+// commandInfo adds the command described by info, one element of the reply to COMMAND INFO, and its subcommands to
+// specs. See https://redis.io/docs/latest/commands/command/ for the format of the reply.
+func commandInfo(info Message, specs map[string]CommandSpecification) error {
+	if info.IsNull() {
+		return nil
+	}
+	if len(info.Elems) < 6 {
+		return fmt.Errorf("%w; expected at least 6 elements of command info, got %d", ErrInvalidCommand,
+			len(info.Elems))
+	}
+
+	// subcommands are named e.g. config|get
+	name := strings.ReplaceAll(strings.ToUpper(text(info.Elems[0])), "|", " ")
+	spec := CommandSpecification{Arity: int(info.Elems[1].Int)}
+	for _, flag := range info.Elems[2].Elems {
+		spec.Flags = append(spec.Flags, text(flag))
+	}
+	// categories are new in redis 6.
+	if len(info.Elems) > 6 {
+		for _, category := range info.Elems[6].Elems {
+			spec.Categories = append(spec.Categories, strings.TrimPrefix(text(category), "@"))
+		}
+	}
+
+	// key specs are new in redis 7, and before then the keys are only given as the first, last and step. Those can't
+	// describe keys that move, e.g. those of EVAL, so a command known to the generated table keeps its spec.
+	if len(info.Elems) > 8 && len(info.Elems[8].Elems) > 0 {
+		for _, ks := range info.Elems[8].Elems {
+			keySpec, err := keySpecInfo(ks)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			spec.KeySpecs = append(spec.KeySpecs, keySpec)
+		}
+	} else if generated, ok := cmdSpec[name]; ok {
+		spec = generated
+	} else if first := int(info.Elems[3].Int); first > 0 {
+		// the last key is counted from the command name, or if negative from the end of the arguments.
+		last := int(info.Elems[4].Int)
+		if last >= 0 {
+			last -= first
+		}
+		spec.KeySpecs = []KeySpec{{
+			BeginSearch: BeginSearch{Index: first},
+			FindKeys:    FindKeys{Range: &KeyRange{LastKey: last, Step: int(info.Elems[5].Int)}},
+		}}
+	}
+	specs[name] = spec
+
+	if len(info.Elems) > 9 {
+		for _, sub := range info.Elems[9].Elems {
+			err := commandInfo(sub, specs)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// keySpecInfo reads a key spec from COMMAND INFO, e.g.
+//
+//	{"flags": ["RO", "access"], "begin_search": {"type": "index", "spec": {"index": 1}},
+//	"find_keys": {"type": "range", "spec": {"lastkey": 0, "keystep": 1, "limit": 0}}}
+//
+// Maps are flattened into arrays when the connection speaks RESP2.
+func keySpecInfo(m Message) (KeySpec, error) {
+	var spec KeySpec
+	fields := pairs(m)
+
+	for _, flag := range fields["flags"].Elems {
+		spec.Flags = append(spec.Flags, strings.ToUpper(text(flag)))
+	}
+
+	begin := pairs(fields["begin_search"])
+	beginSpec := pairs(begin["spec"])
+	switch kind := text(begin["type"]); kind {
+	case "index":
+		spec.BeginSearch.Index = int(beginSpec["index"].Int)
+	case "keyword":
+		spec.BeginSearch.Keyword = text(beginSpec["keyword"])
+		spec.BeginSearch.StartFrom = int(beginSpec["startfrom"].Int)
+	case "unknown":
+	default:
+		return KeySpec{}, fmt.Errorf("%w; unexpected begin_search type %q", ErrInvalidCommand, kind)
+	}
+
+	find := pairs(fields["find_keys"])
+	findSpec := pairs(find["spec"])
+	switch kind := text(find["type"]); kind {
+	case "range":
+		spec.FindKeys.Range = &KeyRange{
+			LastKey: int(findSpec["lastkey"].Int),
+			Step:    int(findSpec["keystep"].Int),
+			Limit:   int(findSpec["limit"].Int),
+		}
+	case "keynum":
+		spec.FindKeys.Keynum = &KeyNum{
+			KeyNumIdx: int(findSpec["keynumidx"].Int),
+			FirstKey:  int(findSpec["firstkey"].Int),
+			Step:      int(findSpec["keystep"].Int),
+		}
+	case "unknown":
+	default:
+		return KeySpec{}, fmt.Errorf("%w; unexpected find_keys type %q", ErrInvalidCommand, kind)
+	}
+
+	return spec, nil
+}
+
+// pairs returns the fields of a map, or of an array of alternating keys and values.
+func pairs(m Message) map[string]Message {
+	fields := map[string]Message{}
+	for i := 0; i+1 < len(m.Elems); i += 2 {
+		fields[text(m.Elems[i])] = m.Elems[i+1]
+	}
+	return fields
+}
+
+// text returns the string in a simple or bulk string.
+func text(m Message) string {
+	if m.Kind == SimpleString {
+		return m.SimpleString
+	}
+	return string(m.Bytes)
+}
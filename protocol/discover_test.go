@@ -0,0 +1,133 @@
+package protocol
+
+import (
+	"net"
+	"testing"
+
+	"github.com/awinterman/anarchoredis/protocol/message"
+	"gotest.tools/v3/assert"
+)
+
+// TestDiscover tests reading the key specs of a module command and of a subcommand from COMMAND INFO, in both RESP2,
+// where maps are flattened into arrays, and RESP3, and falling back to the first, last and step of older servers.
+func TestDiscover(t *testing.T) {
+	for _, resp3 := range []bool{false, true} {
+		t.Run(map[bool]string{false: "RESP2", true: "RESP3"}[resp3], func(t *testing.T) {
+			m := message.Array
+			if resp3 {
+				m = message.Map
+			}
+			str := func(s string) Message {
+				return message.BulkBytes([]byte(s))
+			}
+			strs := func(ss ...string) Message {
+				var ms []Message
+				for _, s := range ss {
+					ms = append(ms, message.SimpleString(s))
+				}
+				return message.Array(ms...)
+			}
+			info := func(name string, arity int64, flags, categories Message, keySpecs []Message,
+				subcommands ...Message) Message {
+				return message.Array(str(name), message.Int(arity), flags, message.Int(0), message.Int(0),
+					message.Int(0), categories, message.Array(), message.Array(keySpecs...),
+					message.Array(subcommands...))
+			}
+
+			// before redis 7 the keys are only given as the first, last and step, and before redis 6 there are no
+			// categories either.
+			legacy := func(name string, first, last, step int64) Message {
+				return message.Array(str(name), message.Int(-2), strs("write"), message.Int(first),
+					message.Int(last), message.Int(step))
+			}
+
+			reply := message.Array(
+				info("json.set", -4, strs("write", "denyoom", "module"), strs("@write", "@slow"), []Message{
+					m(
+						str("flags"), strs("RW", "update"),
+						str("begin_search"), m(str("type"), str("index"), str("spec"), m(str("index"), message.Int(1))),
+						str("find_keys"), m(str("type"), str("range"), str("spec"), m(
+							str("lastkey"), message.Int(0), str("keystep"), message.Int(1), str("limit"), message.Int(0))),
+					),
+				}),
+				info("fake", -2, strs(), strs("@slow"), nil,
+					info("fake|sub", -3, strs("readonly"), strs("@read", "@slow"), []Message{
+						m(
+							str("flags"), strs("RO"),
+							str("begin_search"), m(str("type"), str("keyword"), str("spec"),
+								m(str("keyword"), str("KEYS"), str("startfrom"), message.Int(1))),
+							str("find_keys"), m(str("type"), str("keynum"), str("spec"), m(
+								str("keynumidx"), message.Int(0), str("firstkey"), message.Int(1),
+								str("keystep"), message.Int(1))),
+						),
+					}),
+				),
+				legacy("legacy.mset", 1, -1, 2),
+				legacy("get", 1, 1, 1),
+				message.NullArray(),
+			)
+
+			client, server := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+			go func() {
+				upstream := NewConnection(server)
+				_, err := upstream.Read()
+				if err != nil {
+					return
+				}
+				_, _ = upstream.Write(reply)
+				_ = upstream.Flush()
+			}()
+
+			specs, err := Discover(NewConnection(client))
+			assert.NilError(t, err)
+			assert.DeepEqual(t, specs, map[string]CommandSpecification{
+				"JSON.SET": {
+					Arity:      -4,
+					Flags:      []string{"write", "denyoom", "module"},
+					Categories: []string{"write", "slow"},
+					KeySpecs: []KeySpec{{
+						BeginSearch: BeginSearch{Index: 1},
+						FindKeys:    FindKeys{Range: &KeyRange{LastKey: 0, Step: 1}},
+						Flags:       []string{"RW", "UPDATE"},
+					}},
+				},
+				"FAKE": {Arity: -2, Categories: []string{"slow"}},
+				"FAKE SUB": {
+					Arity:      -3,
+					Flags:      []string{"readonly"},
+					Categories: []string{"read", "slow"},
+					KeySpecs: []KeySpec{{
+						BeginSearch: BeginSearch{Keyword: "KEYS", StartFrom: 1},
+						FindKeys:    FindKeys{Keynum: &KeyNum{KeyNumIdx: 0, FirstKey: 1, Step: 1}},
+						Flags:       []string{"RO"},
+					}},
+				},
+				"LEGACY.MSET": {
+					Arity: -2,
+					Flags: []string{"write"},
+					KeySpecs: []KeySpec{{
+						BeginSearch: BeginSearch{Index: 1},
+						FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 2}},
+					}},
+				},
+				"GET": cmdSpec["GET"],
+			})
+
+			Register(specs)
+			for argv, want := range map[*Message][]string{
+				NewOutgoingCommand("JSON.SET", "doc", "$", "{}"):              {"doc"},
+				NewOutgoingCommand("FAKE", "SUB", "x", "KEYS", "2", "a", "b"): {"a", "b"},
+				NewOutgoingCommand("LEGACY.MSET", "a", "1", "b", "2"):         {"a", "b"},
+				NewOutgoingCommand("GET", "a"):                                {"a"},
+			} {
+				cmd, err := Cmd(*argv)
+				assert.NilError(t, err)
+				keys, err := cmd.Keys()
+				assert.NilError(t, err)
+				assert.DeepEqual(t, keys, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+// Command cmdspecgen generates the command specification table of the protocol package from the JSON command files
+// that Redis and Valkey generate their own command tables from, found in src/commands of their source trees.
+//
+//	go run ./internal/cmdspecgen -o commands_gen.go $REDIS_SRC/src/commands
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// command is a command as described by a JSON file, e.g. src/commands/get.json. Fields that don't concern the
+// proxy, such as the arguments and reply schema, are ignored.
+type command struct {
+	Container     string    `json:"container"`
+	Arity         int       `json:"arity"`
+	CommandFlags  []string  `json:"command_flags"`
+	ACLCategories []string  `json:"acl_categories"`
+	KeySpecs      []keySpec `json:"key_specs"`
+}
+
+type keySpec struct {
+	Flags       []string `json:"flags"`
+	BeginSearch struct {
+		Index *struct {
+			Pos int `json:"pos"`
+		} `json:"index"`
+		Keyword *struct {
+			Keyword   string `json:"keyword"`
+			StartFrom int    `json:"startfrom"`
+		} `json:"keyword"`
+	} `json:"begin_search"`
+	FindKeys struct {
+		Range *struct {
+			LastKey int `json:"lastkey"`
+			Step    int `json:"step"`
+			Limit   int `json:"limit"`
+		} `json:"range"`
+		Keynum *struct {
+			KeyNumIdx int `json:"keynumidx"`
+			FirstKey  int `json:"firstkey"`
+			Step      int `json:"step"`
+		} `json:"keynum"`
+	} `json:"find_keys"`
+}
+
+// spec is a command ready to be written out.
+type spec struct {
+	Name string
+	command
+	Categories []string
+}
+
+func main() {
+	out := flag.String("o", "commands_gen.go", "the file to write")
+	pkg := flag.String("package", "protocol", "the package of the generated file")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		slog.Error("expected the directories containing the command JSON files")
+		os.Exit(2)
+	}
+
+	specs, err := load(flag.Args()...)
+	if err != nil {
+		slog.Error("loading", "error", err)
+		os.Exit(1)
+	}
+
+	src, err := generate(*pkg, specs)
+	if err != nil {
+		slog.Error("generating", "error", err)
+		os.Exit(1)
+	}
+
+	err = os.WriteFile(*out, src, 0o644)
+	if err != nil {
+		slog.Error("writing", "error", err)
+		os.Exit(1)
+	}
+}
+
+// load reads every command in the JSON files of dirs, sorted by name. Later directories take precedence.
+func load(dirs ...string) ([]spec, error) {
+	byName := map[string]spec{}
+	for _, dir := range dirs {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no command files in %s", dir)
+		}
+
+		for _, path := range paths {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			var commands map[string]command
+			err = json.Unmarshal(b, &commands)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+
+			for name, cmd := range commands {
+				name = strings.ToUpper(name)
+				if cmd.Container != "" {
+					name = strings.ToUpper(cmd.Container) + " " + name
+				}
+				byName[name] = spec{Name: name, command: cmd, Categories: categories(cmd)}
+			}
+		}
+	}
+
+	var specs []spec
+	for _, s := range byName {
+		specs = append(specs, s)
+	}
+	slices.SortFunc(specs, func(a, b spec) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return specs, nil
+}
+
+// categories returns the ACL categories of the command, including those Redis implies from its flags, see
+// setImplicitACLCategories in Redis' server.c.
+func categories(cmd command) []string {
+	var cats []string
+	add := func(cat string) {
+		if !slices.Contains(cats, cat) {
+			cats = append(cats, cat)
+		}
+	}
+
+	for _, cat := range cmd.ACLCategories {
+		add(strings.ToLower(cat))
+	}
+	for _, flag := range cmd.CommandFlags {
+		switch flag {
+		case "WRITE":
+			add("write")
+		case "READONLY":
+			if !slices.Contains(cmd.ACLCategories, "SCRIPTING") {
+				add("read")
+			}
+		case "ADMIN":
+			add("admin")
+			add("dangerous")
+		case "PUBSUB":
+			add("pubsub")
+		case "FAST":
+			add("fast")
+		case "BLOCKING":
+			add("blocking")
+		}
+	}
+	if !slices.Contains(cats, "fast") {
+		add("slow")
+	}
+	return cats
+}
+
+// generate renders and formats the specs as go source.
+func generate(pkg string, specs []spec) ([]byte, error) {
+	containers := map[string]bool{}
+	for _, s := range specs {
+		if s.Container != "" {
+			containers[strings.ToUpper(s.Container)] = true
+		}
+	}
+	var containerNames []string
+	for name := range containers {
+		containerNames = append(containerNames, name)
+	}
+	slices.Sort(containerNames)
+
+	buf := &bytes.Buffer{}
+	err := tmpl.Execute(buf, map[string]any{
+		"Package":    pkg,
+		"Specs":      specs,
+		"Containers": containerNames,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+var tmpl = template.Must(template.New("").Funcs(template.FuncMap{
+	"strings": func(s []string) string {
+		if len(s) == 0 {
+			return "nil"
+		}
+		return fmt.Sprintf("%#v", s)
+	},
+	"lower": func(s []string) []string {
+		lowered := make([]string, len(s))
+		for i := range s {
+			lowered[i] = strings.ToLower(s[i])
+		}
+		return lowered
+	},
+}).Parse(`// Code generated by cmdspecgen; DO NOT EDIT.
+
+package {{ .Package }}
+
+// commandContainers are the commands that only group subcommands, e.g. CONFIG.
+var commandContainers = map[string]bool{
+{{- range .Containers }}
+	{{ printf "%q" . }}: true,
+{{- end }}
+}
+
+var cmdSpec = map[string]CommandSpecification{
+{{- range .Specs }}
+	{{ printf "%q" .Name }}: {
+		Arity: {{ .Arity }},
+		Flags: {{ strings (lower .CommandFlags) }},
+		Categories: {{ strings .Categories }},
+		{{- if .KeySpecs }}
+		KeySpecs: []KeySpec{
+		{{- range .KeySpecs }}
+			{
+				{{- with .BeginSearch.Index }}
+				BeginSearch: BeginSearch{Index: {{ .Pos }}},
+				{{- end }}
+				{{- with .BeginSearch.Keyword }}
+				BeginSearch: BeginSearch{Keyword: {{ printf "%q" .Keyword }}, StartFrom: {{ .StartFrom }}},
+				{{- end }}
+				{{- with .FindKeys.Range }}
+				FindKeys: FindKeys{Range: &KeyRange{LastKey: {{ .LastKey }}, Step: {{ .Step }}, Limit: {{ .Limit }}}},
+				{{- end }}
+				{{- with .FindKeys.Keynum }}
+				FindKeys: FindKeys{Keynum: &KeyNum{KeyNumIdx: {{ .KeyNumIdx }}, FirstKey: {{ .FirstKey }}, Step: {{ .Step }}}},
+				{{- end }}
+				Flags: {{ strings .Flags }},
+			},
+		{{- end }}
+		},
+		{{- end }}
+	},
+{{- end }}
+}
+`))
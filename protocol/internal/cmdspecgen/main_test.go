@@ -0,0 +1,48 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// TestLoad tests reading command files, naming subcommands after their container and implying ACL categories.
+func TestLoad(t *testing.T) {
+	specs, err := load("testdata/commands")
+	assert.NilError(t, err)
+
+	var names []string
+	categories := map[string][]string{}
+	for _, s := range specs {
+		names = append(names, s.Name)
+		categories[s.Name] = s.Categories
+	}
+	assert.DeepEqual(t, names, []string{"CONFIG", "CONFIG GET", "GET", "MSET", "SORT", "XREAD"})
+	assert.DeepEqual(t, categories["GET"], []string{"string", "read", "fast"})
+	assert.DeepEqual(t, categories["MSET"], []string{"string", "write", "slow"})
+	assert.DeepEqual(t, categories["CONFIG GET"], []string{"admin", "dangerous", "slow"})
+}
+
+// TestGenerate tests that the generated table is valid go, and contains each kind of key spec.
+func TestGenerate(t *testing.T) {
+	specs, err := load("testdata/commands")
+	assert.NilError(t, err)
+
+	src, err := generate("protocol", specs)
+	assert.NilError(t, err)
+
+	_, err = parser.ParseFile(token.NewFileSet(), "commands_gen.go", src, 0)
+	assert.NilError(t, err)
+
+	for _, want := range []string{
+		`"CONFIG": true,`,
+		`BeginSearch: BeginSearch{Index: 1},`,
+		`FindKeys:    FindKeys{Range: &KeyRange{LastKey: -1, Step: 2, Limit: 0}},`,
+		`BeginSearch: BeginSearch{Keyword: "STREAMS", StartFrom: 1},`,
+	} {
+		assert.Assert(t, strings.Contains(string(src), want), "missing %s", want)
+	}
+}
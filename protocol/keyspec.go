@@ -0,0 +1,137 @@
+package protocol
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// KeySpec says where a command's keys are among its arguments, mirroring the key_specs of the Redis command table.
+// See https://redis.io/docs/latest/develop/reference/key-specs/
+//
+// Positions count the command name as 0, and for subcommands the subcommand name as 1, as they do in Redis.
+type KeySpec struct {
+	BeginSearch BeginSearch
+	FindKeys    FindKeys
+	// Flags describe how the keys are used, e.g. RW, ACCESS. Specs flagged NOT_KEY, such as shard channels, do not
+	// name keys.
+	Flags []string
+}
+
+// BeginSearch locates the first key, either at the fixed position Index, or after the first occurrence of Keyword.
+// When neither is set the spec is unknown, and no keys can be found with it.
+type BeginSearch struct {
+	Index int
+
+	Keyword string
+	// StartFrom is where the search for the Keyword begins. A negative value searches backwards from the end.
+	StartFrom int
+}
+
+// FindKeys finds the keys following the first, by Range or by Keynum. When neither is set the spec is unknown.
+type FindKeys struct {
+	Range  *KeyRange
+	Keynum *KeyNum
+}
+
+// KeyRange is a run of keys that ends LastKey positions after the first key, or, if negative, that many positions
+// from the end of the arguments. When Limit is set, only the first 1/Limit of the remaining arguments are keys.
+type KeyRange struct {
+	LastKey int
+	Step    int
+	Limit   int
+}
+
+// KeyNum is a run of keys whose length is given by the argument KeyNumIdx positions after the begin search. The
+// first key is FirstKey positions after the begin search.
+type KeyNum struct {
+	KeyNumIdx int
+	FirstKey  int
+	Step      int
+}
+
+// notKey says whether the spec names something other than keys.
+func (spec KeySpec) notKey() bool {
+	return slices.Contains(spec.Flags, "NOT_KEY")
+}
+
+// keys finds the keys of the spec in args, which are the arguments following the first offset elements of the
+// command, and of which there are size including any Trailer.
+func (spec KeySpec) keys(args [][]byte, size int, offset int) ([]string, error) {
+	argc := size + offset
+
+	var first int
+	switch {
+	case spec.BeginSearch.Index > 0:
+		first = spec.BeginSearch.Index
+	case spec.BeginSearch.Keyword != "":
+		first = spec.BeginSearch.search(args, argc, offset)
+		if first == 0 {
+			// the keyword is optional, e.g. the STORE of GEORADIUS
+			return nil, nil
+		}
+	default:
+		return nil, nil
+	}
+
+	var last, step int
+	switch {
+	case spec.FindKeys.Range != nil:
+		r := spec.FindKeys.Range
+		switch {
+		case r.LastKey >= 0:
+			last = first + r.LastKey
+		case r.Limit == 0:
+			last = argc + r.LastKey
+		default:
+			last = first + (argc-first)/r.Limit + r.LastKey
+		}
+		step = r.Step
+	case spec.FindKeys.Keynum != nil:
+		kn := spec.FindKeys.Keynum
+		i := first + kn.KeyNumIdx - offset
+		if i < 0 || i >= len(args) {
+			return nil, fmt.Errorf("%w; expected the number of keys at argument %d", ErrInvalidCommand, i)
+		}
+		numkeys, err := strconv.Atoi(string(args[i]))
+		if err != nil || numkeys < 0 {
+			return nil, fmt.Errorf("%w; expected the number of keys, got %q", ErrInvalidCommand, args[i])
+		}
+		first += kn.FirstKey
+		last = first + (numkeys-1)*max(kn.Step, 1)
+		step = kn.Step
+	default:
+		return nil, nil
+	}
+	step = max(step, 1)
+
+	var indices []int
+	for i := first; i <= last; i += step {
+		if i >= argc || i < offset {
+			return nil, fmt.Errorf("%w; expected a key at argument %d of %d", ErrInvalidCommand, i-offset, size)
+		}
+		indices = append(indices, i-offset)
+	}
+	return keys(args, indices...)
+}
+
+// search returns the position after the Keyword, or 0 if it was not found.
+func (b BeginSearch) search(args [][]byte, argc int, offset int) int {
+	start, end, dir := b.StartFrom, argc-1, 1
+	if b.StartFrom < 0 {
+		start, end, dir = argc+b.StartFrom, 0, -1
+	}
+	for i := start; i != end; i += dir {
+		if i >= argc || i < 1 {
+			break
+		}
+		if i < offset || i-offset >= len(args) {
+			continue
+		}
+		if strings.EqualFold(string(args[i-offset]), b.Keyword) {
+			return i + 1
+		}
+	}
+	return 0
+}
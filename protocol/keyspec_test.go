@@ -0,0 +1,87 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/awinterman/anarchoredis/protocol/message"
+	"gotest.tools/v3/assert"
+)
+
+// TestCommand_Keys tests finding keys with the generated key specs for each kind of begin search and find keys.
+func TestCommand_Keys(t *testing.T) {
+	tests := []struct {
+		name  string
+		argv  []string
+		keys  []string
+		write bool
+		err   error
+	}{
+		{"index range", []string{"DEL", "a", "b", "c"}, []string{"a", "b", "c"}, true, nil},
+		{"range step", []string{"MSET", "a", "1", "b", "2"}, []string{"a", "b"}, true, nil},
+		{"range from end", []string{"BLPOP", "a", "b", "0"}, []string{"a", "b"}, true, nil},
+		{"several specs", []string{"BITOP", "AND", "dest", "a", "b"}, []string{"dest", "a", "b"}, true, nil},
+		{"keynum", []string{"EVAL", "return 1", "2", "a", "b", "arg"}, []string{"a", "b"}, true, nil},
+		{"keynum and index", []string{"ZUNIONSTORE", "dest", "2", "a", "b", "WEIGHTS", "1", "2"},
+			[]string{"dest", "a", "b"}, true, nil},
+		{"keyword with limit", []string{"XREAD", "COUNT", "2", "STREAMS", "a", "b", "0", "0"},
+			[]string{"a", "b"}, false, nil},
+		// the empty key stands in for the keys that follow KEYS, and is found by the first spec all the same
+		{"keyword from end", []string{"MIGRATE", "host", "6379", "", "0", "1000", "KEYS", "a", "b"},
+			[]string{"", "a", "b"}, true, nil},
+		{"optional keyword", []string{"GEORADIUS", "a", "0", "0", "1", "km"}, []string{"a"}, true, nil},
+		{"optional keyword present", []string{"GEORADIUS", "a", "0", "0", "1", "km", "STORE", "b"},
+			[]string{"a", "b"}, true, nil},
+		{"subcommand", []string{"OBJECT", "ENCODING", "a"}, []string{"a"}, false, nil},
+		{"container", []string{"COMMAND"}, nil, false, nil},
+		{"not keys", []string{"SPUBLISH", "channel", "message"}, nil, false, nil},
+		{"no keys", []string{"FLUSHALL"}, nil, true, nil},
+		{"missing key", []string{"GET"}, nil, false, ErrInvalidCommand},
+		{"bad numkeys", []string{"EVAL", "return 1", "x"}, nil, true, ErrInvalidCommand},
+		{"too few keys", []string{"EVAL", "return 1", "3", "a"}, nil, true, ErrInvalidCommand},
+		{"unknown", []string{"NOTACOMMAND", "a"}, nil, false, ErrNotImplemented},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var elems []message.Message
+			for _, arg := range test.argv {
+				elems = append(elems, message.BulkBytes([]byte(arg)))
+			}
+			cmd, err := Cmd(message.Array(elems...))
+			assert.NilError(t, err)
+			assert.Equal(t, cmd.IsWrite(), test.write, strings.Join(test.argv, " "))
+
+			keys, err := cmd.Keys()
+			if test.err != nil {
+				assert.ErrorIs(t, err, test.err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, keys, test.keys)
+		})
+	}
+}
+
+// TestKeySpec_KeynumStep tests finding keys by number when they are spaced out, e.g. by a value after each key, as
+// there is no such command among those generated.
+func TestKeySpec_KeynumStep(t *testing.T) {
+	spec := KeySpec{
+		BeginSearch: BeginSearch{Index: 1},
+		FindKeys:    FindKeys{Keynum: &KeyNum{KeyNumIdx: 0, FirstKey: 1, Step: 2}},
+	}
+	args := func(s ...string) [][]byte {
+		var b [][]byte
+		for _, arg := range s {
+			b = append(b, []byte(arg))
+		}
+		return b
+	}
+
+	keys, err := spec.keys(args("2", "a", "1", "b", "2", "arg"), 6, 1)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, keys, []string{"a", "b"})
+
+	_, err = spec.keys(args("2", "a", "1"), 3, 1)
+	assert.ErrorIs(t, err, ErrInvalidCommand)
+}
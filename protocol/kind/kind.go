@@ -23,11 +23,19 @@ const (
 	BulkError      Kind = '!'
 	VerbatimString Kind = '='
 	Map            Kind = '%'
-	Attribute      Kind = '`'
+	Attribute      Kind = '|'
 	Set            Kind = '~'
 	Push           Kind = '>'
+
+	// StreamedChunk introduces a chunk of a streamed string, e.g. `$?\r\n;4\r\nHell\r\n;0\r\n`
+	StreamedChunk Kind = ';'
+	// StreamedEnd terminates a streamed aggregate, e.g. `*?\r\n:1\r\n.\r\n`
+	StreamedEnd Kind = '.'
 )
 
+// Streamed is the run length sent in place of a size for streamed strings and aggregates.
+const Streamed = "?"
+
 const (
 	CategorySimple int = iota
 	CategoryAggregate
@@ -44,6 +52,11 @@ func (i Kind) Category() int {
 	}
 }
 
+// IsBulk reports whether the kind carries a length-prefixed string payload rather than elements.
+func (i Kind) IsBulk() bool {
+	return i == BulkString || i == BulkError || i == VerbatimString
+}
+
 func (i Kind) String() string {
 	return Humanize(byte(i))
 }
@@ -69,6 +82,8 @@ func Humanize(indicator byte) string {
 		return "Double"
 	case BigNumber:
 		return "BigNumber"
+	case BulkError:
+		return "BulkError"
 	case VerbatimString:
 		return "VerbatimString"
 	case Map:
@@ -79,6 +94,10 @@ func Humanize(indicator byte) string {
 		return "Set"
 	case Push:
 		return "Push"
+	case StreamedChunk:
+		return "StreamedChunk"
+	case StreamedEnd:
+		return "StreamedEnd"
 	default:
 		return "Unknown"
 	}
@@ -1,15 +1,21 @@
 package message
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"iter"
+	"math"
 	"math/big"
 	"strconv"
 
 	"github.com/awinterman/anarchoredis/protocol/kind"
 )
 
+// ErrProtocol is returned when the input does not conform to RESP2 or RESP3.
+var ErrProtocol = errors.New("protocol error")
+
 func simpleUnmarshal(r io.Reader) (str string, size int64, err error) {
 	var (
 		b   = make([]byte, 1)
@@ -24,7 +30,7 @@ func simpleUnmarshal(r io.Reader) (str string, size int64, err error) {
 
 		buf = append(buf, b[:n]...)
 
-		if len(buf) > len(kind.EOL) {
+		if len(buf) >= len(kind.EOL) {
 			l := len(buf) - len(kind.EOL)
 			s := string(buf[l:])
 			if s == kind.EOL {
@@ -34,148 +40,443 @@ func simpleUnmarshal(r io.Reader) (str string, size int64, err error) {
 	}
 }
 
+// readEOL consumes the line feed that terminates bulk payloads.
+func readEOL(r io.Reader) error {
+	b := make([]byte, len(kind.EOL))
+	_, err := io.ReadFull(r, b)
+	if err != nil {
+		return err
+	}
+	if string(b) != kind.EOL {
+		return fmt.Errorf("%w: expected line feed, got %q", ErrProtocol, b)
+	}
+	return nil
+}
+
 type Encoder struct {
 	ChunkSize  int64
 	BufferSize int
 }
 
+// Iterate decodes successive messages from r.
+//
+// Messages are decoded lazily: bulk payloads are read through Message.Reader and elements of collections through
+// Message.Seq or Message.Assoc, straight from r. A yielded message is valid until the loop body returns, at which
+// point any part of it that was not consumed is discarded so that the next message can be decoded.
 func (e *Encoder) Iterate(r io.Reader) iter.Seq2[Message, error] {
-	return func(yield func(message Message, err error) bool) {
-		for {
-			kindSlice := make([]byte, 1)
-			_, err := r.Read(kindSlice)
-			if err != nil {
-				_ = yield(Message{}, err)
-				return
+	return e.seq(r, -1, false, "message")
+}
+
+// decode reads exactly one message header from r, including any attribute frame that precedes it.
+func (e *Encoder) decode(r io.Reader) (Message, error) {
+	kindSlice := make([]byte, 1)
+	_, err := io.ReadFull(r, kindSlice)
+	if err != nil {
+		return Message{}, err
+	}
+
+	k := kind.Kind(kindSlice[0])
+	if k == kind.StreamedEnd {
+		if err := readEOL(r); err != nil {
+			return Message{}, err
+		}
+		return Message{Kind: k, TotalMessageSize: 1 + int64(len(kind.EOL))}, nil
+	}
+
+	var m Message
+	switch k.Category() {
+	case kind.CategorySimple:
+		str, size, err := simpleUnmarshal(r)
+		if err != nil {
+			return Message{}, err
+		}
+		m, err = parseSimple(k, str)
+		if err != nil {
+			return Message{}, err
+		}
+		m.TotalMessageSize = 1 + size
+	case kind.CategoryAggregate:
+		// first unmarshal the first line, which has the run length
+		str, size, err := simpleUnmarshal(r)
+		if err != nil {
+			return Message{}, err
+		}
+		m.Kind = k
+		m.TotalMessageSize = 1 + size
+
+		if str == kind.Streamed {
+			return e.streamed(r, m)
+		}
+
+		runlength, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return Message{}, err
+		}
+		m.RunLength = runlength
+
+		if runlength == -1 && (k == kind.BulkString || k == kind.Array) {
+			// RESP2 null
+			return m, nil
+		}
+		if runlength < 0 {
+			return Message{}, fmt.Errorf("%w: invalid length %d for %s", ErrProtocol, runlength, k)
+		}
+
+		switch k {
+		case kind.VerbatimString:
+			// =15\r\ntxt:Some string\r\n
+			if runlength < 4 {
+				return Message{}, fmt.Errorf("%w: verbatim string too short for encoding: %d", ErrProtocol, runlength)
 			}
+			var enc [4]byte
+			if _, err := io.ReadFull(r, enc[:]); err != nil {
+				return Message{}, err
+			}
+			if enc[3] != ':' {
+				return Message{}, fmt.Errorf("%w: invalid verbatim string encoding %q", ErrProtocol, enc)
+			}
+			copy(m.Encoding[:], enc[:3])
+			m.RunLength = runlength - 4
+			m.Reader = &bulkReader{r: r, left: m.RunLength}
+			m.TotalMessageSize += runlength + int64(len(kind.EOL))
+		case kind.BulkString, kind.BulkError:
+			m.Reader = &bulkReader{r: r, left: runlength}
+			m.TotalMessageSize += runlength + int64(len(kind.EOL))
+		case kind.Array, kind.Set, kind.Push:
+			m.Seq = e.seq(r, runlength, false, k.String())
+		case kind.Map:
+			m.Assoc = e.assoc(r, runlength, false)
+		case kind.Attribute:
+			return e.attribute(r, m)
+		}
+	default:
+		return Message{}, fmt.Errorf("%w: unknown kind %q", ErrProtocol, kindSlice[0])
+	}
 
-			k := kind.Kind(kindSlice[0])
-			var m Message
-			switch k.Category() {
-			case kind.CategorySimple:
-				str, size, err := simpleUnmarshal(r)
-				if err != nil {
-					_ = yield(Message{}, err)
-					return
-				}
-				switch k {
-				case kind.SimpleString:
-					m = SimpleString(str)
-				case kind.Error:
-					m = Error(str)
-				case kind.Int:
-					i, err := strconv.ParseInt(str, 10, 64)
-					if err != nil {
-						_ = yield(Message{}, err)
-						return
-					}
-					m = Int(i)
-				case kind.Null:
-					m = Null()
-				case kind.Bool:
-					b, err := strconv.ParseBool(str)
-					if err != nil {
-						_ = yield(Message{}, err)
-						return
-					}
-					m = Bool(b)
-				case kind.Double:
-					f, err := strconv.ParseFloat(str, 64)
-					if err != nil {
-						_ = yield(Message{}, err)
-						return
-					}
-					m = Double(f)
-				case kind.BigNumber:
-					i := &big.Int{}
-					i, ok := i.SetString(str, 0)
-					if !ok {
-						_ = yield(Message{}, err)
-						return
-					}
-					m = BigNumber(i)
-				}
-				m.TotalMessageSize = 1 + size
-			case kind.CategoryAggregate:
-				// first unmarshal the first line, which has the run length
-				str, size, err := simpleUnmarshal(r)
-				if err != nil {
-					_ = yield(Message{}, err)
-					return
-				}
-				runlength, err := strconv.ParseInt(str, 10, 64)
-				if err != nil {
-					_ = yield(Message{}, err)
-					return
-				}
+	return m, nil
+}
 
-				m.TotalMessageSize = 1 + size + runlength
-
-				var encoding [3]byte
-				if k == kind.VerbatimString {
-					enc, size, err := simpleUnmarshal(r)
-					if err != nil {
-						_ = yield(Message{}, err)
-						return
-					}
-					if len(enc) != 3 {
-						err = fmt.Errorf("invalid string size: %d", size)
-						_ = yield(Message{}, err)
-						return
-					}
-					copy(encoding[:], enc)
-					m.TotalMessageSize += 3
-				}
+// streamed fills in m, whose header was `?`, as a RESP3 streamed string or aggregate.
+func (e *Encoder) streamed(r io.Reader, m Message) (Message, error) {
+	m.Streamed = true
+	switch m.Kind {
+	case kind.BulkString:
+		m.Reader = &chunkReader{r: r}
+	case kind.Array, kind.Set, kind.Push:
+		m.Seq = e.seq(r, -1, true, m.Kind.String())
+	case kind.Map:
+		m.Assoc = e.assoc(r, -1, true)
+	case kind.Attribute:
+		return e.attribute(r, m)
+	default:
+		return Message{}, fmt.Errorf("%w: %s cannot be streamed", ErrProtocol, m.Kind)
+	}
+	return m, nil
+}
+
+// attribute reads the attribute frame described by header fully into memory, and then decodes the message it
+// annotates.
+func (e *Encoder) attribute(r io.Reader, header Message) (Message, error) {
+	var kvs []Message
+	for kv, err := range e.assoc(r, header.RunLength, header.Streamed) {
+		if err != nil {
+			return Message{}, err
+		}
+		v, err := Materialize(kv[1], -1)
+		if err != nil {
+			return Message{}, err
+		}
+		kvs = append(kvs, kv[0], v)
+	}
+	attr := Attribute(kvs...)
+	attr.TotalMessageSize = header.TotalMessageSize
+	attr.Streamed = header.Streamed
+
+	m, err := e.decode(r)
+	if err != nil {
+		return Message{}, err
+	}
+	if m.Kind == kind.Attribute || m.Kind == kind.StreamedEnd {
+		return Message{}, fmt.Errorf("%w: attribute must be followed by a reply, got %s", ErrProtocol, m.Kind)
+	}
+	m.Attribute = &attr
+	return m, nil
+}
+
+// seq lazily decodes n messages from r. If streamed is true, or n is negative, messages are decoded until a
+// StreamedEnd marker or the end of r, respectively.
+//
+// The returned sequence is stateful: breaking out of a loop over it and ranging over it again resumes where the
+// previous loop stopped.
+func (e *Encoder) seq(r io.Reader, n int64, streamed bool, name string) iter.Seq2[Message, error] {
+	var (
+		i       int64
+		pending Message
+		done    bool
+	)
 
-				switch k {
-				case kind.VerbatimString, kind.BulkString, kind.BulkError:
-					m.Reader = io.LimitReader(r, runlength)
-				case kind.Array, kind.Set, kind.Push:
-					for i := 0; i < int(runlength); i++ {
-						m.Seq = e.Iterate(r)
-					}
-				case kind.Map, kind.Attribute:
-					for i := 0; i < int(runlength); i++ {
-						kvs := e.Iterate(r)
-						m.Assoc = chunk(kvs)
-					}
+	return func(yield func(Message, error) bool) {
+		// the previous loop was broken out of; the message it stopped at must be consumed first.
+		if err := discard(pending); err != nil {
+			done = true
+			yield(Message{}, err)
+			return
+		}
+		pending = Message{}
+
+		for !done && (n < 0 || i < n) {
+			m, err := e.decode(r)
+			if err != nil {
+				done = true
+				if streamed && errors.Is(err, io.EOF) {
+					err = fmt.Errorf("%w: streamed %s ended without terminator", io.ErrUnexpectedEOF, name)
+				} else if n > 0 && errors.Is(err, io.EOF) {
+					err = fmt.Errorf("%w: %s ended after %d of %d elements", io.ErrUnexpectedEOF, name, i, n)
 				}
+				yield(Message{}, err)
+				return
+			}
+			if m.Kind == kind.StreamedEnd {
+				done = true
+				if !streamed {
+					yield(Message{}, fmt.Errorf("%w: unexpected end of stream marker in %s", ErrProtocol, name))
+				}
+				return
+			}
+			i++
 
-				m.RunLength = runlength
-				m.Encoding = encoding
+			pending = m
+			if !yield(m, nil) {
+				return
+			}
+			pending = Message{}
+
+			if err := discard(m); err != nil {
+				done = true
+				yield(Message{}, err)
+				return
 			}
 		}
+		done = true
 	}
 }
 
-func chunk(seq iter.Seq2[Message, error]) iter.Seq2[[2]Message, error] {
+// assoc lazily decodes n pairs from r, or pairs until a StreamedEnd marker if streamed is true. Keys are read fully
+// into memory, so that the value can be decoded directly from r.
+func (e *Encoder) assoc(r io.Reader, n int64, streamed bool) iter.Seq2[[2]Message, error] {
+	count := 2 * n
+	if streamed {
+		count = -1
+	}
+	elements := e.seq(r, count, streamed, "pairs")
+
 	return func(yield func([2]Message, error) bool) {
-		var kv [2]Message = [2]Message{}
-		var i = 0
-		for msg, err := range seq {
+		var (
+			kv [2]Message
+			i  int
+		)
+		for msg, err := range elements {
 			if err != nil {
 				yield(kv, err)
+				return
+			}
+			if i%2 == 0 {
+				msg, err = Materialize(msg, -1)
+				if err != nil {
+					yield(kv, err)
+					return
+				}
 			}
 			kv[i%2] = msg
 			i++
-			if i%2 == 0 {
-				yield(kv, nil)
+			if i%2 == 0 && !yield(kv, nil) {
+				return
 			}
 		}
+		if i%2 != 0 {
+			yield(kv, fmt.Errorf("%w: map with a key but no value", ErrProtocol))
+		}
 	}
 }
 
+// parseSimple converts the line following a simple kind into a message.
+func parseSimple(k kind.Kind, str string) (Message, error) {
+	switch k {
+	case kind.SimpleString:
+		return SimpleString(str), nil
+	case kind.Error:
+		return Error(str), nil
+	case kind.Int:
+		i, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return Message{}, err
+		}
+		return Int(i), nil
+	case kind.Null:
+		return Null(), nil
+	case kind.Bool:
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return Message{}, err
+		}
+		return Bool(b), nil
+	case kind.Double:
+		f, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return Message{}, err
+		}
+		return Double(f), nil
+	case kind.BigNumber:
+		i, ok := (&big.Int{}).SetString(str, 10)
+		if !ok {
+			return Message{}, fmt.Errorf("%w: invalid big number %q", ErrProtocol, str)
+		}
+		return BigNumber(i), nil
+	default:
+		return Message{}, fmt.Errorf("%w: unknown simple kind %s", ErrProtocol, k)
+	}
+}
+
+// discard consumes whatever is left of m on the wire.
+func discard(m Message) error {
+	switch {
+	case m.Kind.IsBulk() && m.Reader != nil:
+		_, err := io.Copy(io.Discard, m.Reader)
+		return err
+	case m.Seq != nil:
+		for _, err := range m.Seq {
+			if err != nil {
+				return err
+			}
+		}
+	case m.Assoc != nil:
+		for _, err := range m.Assoc {
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// bulkReader reads a length-prefixed payload and consumes the line feed that follows it.
+type bulkReader struct {
+	r    io.Reader
+	left int64
+	err  error
+}
+
+func (b *bulkReader) Read(p []byte) (int, error) {
+	if b.left == 0 {
+		if b.err == nil {
+			b.err = readEOL(b.r)
+			if b.err == nil {
+				b.err = io.EOF
+			}
+		}
+		return 0, b.err
+	}
+
+	if int64(len(p)) > b.left {
+		p = p[:b.left]
+	}
+	n, err := b.r.Read(p)
+	b.left -= int64(n)
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		b.left = 0
+		b.err = err
+		return n, err
+	}
+	if b.left == 0 {
+		b.err = readEOL(b.r)
+		if b.err != nil {
+			return n, b.err
+		}
+		b.err = io.EOF
+	}
+	return n, nil
+}
+
+// chunkReader reads the chunks of a streamed string, `;4\r\nHell\r\n;5\r\no wor\r\n;0\r\n`.
+type chunkReader struct {
+	r     io.Reader
+	chunk io.Reader
+	err   error
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for c.err == nil {
+		if c.chunk != nil {
+			n, err := c.chunk.Read(p)
+			if errors.Is(err, io.EOF) {
+				c.chunk = nil
+				err = nil
+			}
+			if err != nil {
+				c.err = err
+			}
+			if n > 0 || err != nil {
+				return n, err
+			}
+			continue
+		}
+
+		k := make([]byte, 1)
+		if _, err := io.ReadFull(c.r, k); err != nil {
+			c.err = err
+			break
+		}
+		if kind.Kind(k[0]) != kind.StreamedChunk {
+			c.err = fmt.Errorf("%w: expected streamed string chunk, got %q", ErrProtocol, k)
+			break
+		}
+		str, _, err := simpleUnmarshal(c.r)
+		if err != nil {
+			c.err = err
+			break
+		}
+		size, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			c.err = err
+			break
+		}
+		if size == 0 {
+			c.err = io.EOF
+			break
+		}
+		c.chunk = &bulkReader{r: c.r, left: size}
+	}
+	if errors.Is(c.err, io.EOF) {
+		return 0, io.EOF
+	}
+	return 0, c.err
+}
+
 func (e *Encoder) Decode(r io.Reader) (Message, error) {
-	iterate := e.Iterate(r)
-	for msg, err := range iterate {
-		return msg, err
+	m, err := e.decode(r)
+	if err != nil {
+		return Message{}, err
 	}
-	return Message{}, fmt.Errorf("reader closed before message could be read")
+	if m.Kind == kind.StreamedEnd {
+		return Message{}, fmt.Errorf("%w: unexpected end of stream marker", ErrProtocol)
+	}
+	return m, nil
 }
 
 // Encode the message into the Writer
 func (e *Encoder) Encode(m Message, w io.Writer) (total int, err error) {
 	var n int
+	if m.Attribute != nil {
+		n, err = e.Encode(*m.Attribute, w)
+		total += n
+		if err != nil {
+			return
+		}
+	}
+
 	n, err = w.Write([]byte{byte(m.Kind)})
 	total += n
 	if err != nil {
@@ -195,24 +496,23 @@ func (e *Encoder) Encode(m Message, w io.Writer) (total int, err error) {
 			return
 		}
 	case kind.CategoryAggregate:
-		n, err = w.Write([]byte(strconv.FormatInt(m.RunLength, 10)))
-		total += n
-		if err != nil {
-			return
+		header := strconv.FormatInt(m.RunLength, 10)
+		if m.Streamed {
+			header = kind.Streamed
+		} else if m.Kind == kind.VerbatimString {
+			header = strconv.FormatInt(m.RunLength+4, 10)
 		}
-		n, err = w.Write([]byte(kind.EOL))
+		n, err = w.Write([]byte(header + kind.EOL))
 		total += n
 		if err != nil {
 			return
 		}
+		if m.IsNull() {
+			return total, nil
+		}
 
 		if m.Kind == kind.VerbatimString {
-			n, err = w.Write(m.Encoding[:])
-			total += n
-			if err != nil {
-				return
-			}
-			n, err = w.Write([]byte(kind.EOL))
+			n, err = w.Write(append(m.Encoding[:], ':'))
 			total += n
 			if err != nil {
 				return
@@ -221,13 +521,23 @@ func (e *Encoder) Encode(m Message, w io.Writer) (total int, err error) {
 
 		switch m.Kind {
 		case kind.BulkString, kind.BulkError, kind.VerbatimString:
-			n, err = e.writeBulk(m, w)
+			switch {
+			case m.Streamed:
+				if m.Reader == nil {
+					m.Reader = bytes.NewReader(m.Bytes)
+				}
+				n, err = e.writeChunks(m, w)
+			case m.Reader == nil:
+				n, err = writeBytes(m.Bytes, w)
+			default:
+				n, err = e.writeBulk(m, w)
+			}
 			total += n
 			if err != nil {
 				return total, err
 			}
 		case kind.Array, kind.Push, kind.Set:
-			for msg, err := range m.Seq {
+			for msg, err := range m.Elements() {
 				if err != nil {
 					return total, err
 				}
@@ -238,7 +548,10 @@ func (e *Encoder) Encode(m Message, w io.Writer) (total int, err error) {
 				}
 			}
 		case kind.Map, kind.Attribute:
-			for kv, err := range m.Assoc {
+			for kv, err := range m.Pairs() {
+				if err != nil {
+					return total, err
+				}
 				n, err = e.Encode(kv[0], w)
 				total += n
 				if err != nil {
@@ -251,6 +564,16 @@ func (e *Encoder) Encode(m Message, w io.Writer) (total int, err error) {
 				}
 			}
 		}
+
+		if m.Streamed && m.Kind != kind.BulkString {
+			n, err = w.Write([]byte(string(kind.StreamedEnd) + kind.EOL))
+			total += n
+			if err != nil {
+				return total, err
+			}
+		}
+	default:
+		return total, fmt.Errorf("%w: cannot encode unknown kind %q", ErrProtocol, byte(m.Kind))
 	}
 
 	return total, err
@@ -265,15 +588,20 @@ func (e *Encoder) writeBulk(m Message, w io.Writer) (int, error) {
 	for left > 0 {
 		b := emptyBuffer(e.ChunkSize, left)
 		n, err = m.Reader.Read(b)
-		if err != nil {
-			return total, err
+		if n > 0 {
+			written, werr := w.Write(b[:n])
+			total += written
+			if werr != nil {
+				return total, werr
+			}
+			left = left - int64(n)
 		}
-		n, err = w.Write(b[:n])
-		total += n
-		if err != nil {
+		if errors.Is(err, io.EOF) && left > 0 {
+			return total, fmt.Errorf("%w: bulk string ended %d bytes early", io.ErrUnexpectedEOF, left)
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
 			return total, err
 		}
-		left = left - int64(n)
 	}
 	n, err = w.Write([]byte(kind.EOL))
 	total += n
@@ -281,6 +609,48 @@ func (e *Encoder) writeBulk(m Message, w io.Writer) (int, error) {
 	return total, err
 }
 
+// writeBytes writes an in-memory bulk payload and its line feed to the writer
+func writeBytes(b []byte, w io.Writer) (int, error) {
+	n, err := w.Write(b)
+	if err != nil {
+		return n, err
+	}
+	m, err := io.WriteString(w, kind.EOL)
+	return n + m, err
+}
+
+// writeChunks writes the payload of a streamed string as chunks of at most ChunkSize bytes, followed by the
+// terminating empty chunk.
+func (e *Encoder) writeChunks(m Message, w io.Writer) (int, error) {
+	var total int
+	b := emptyBuffer(e.ChunkSize, math.MaxInt64)
+	for {
+		n, err := m.Reader.Read(b)
+		if n > 0 {
+			chunk := fmt.Sprintf("%c%d%s", kind.StreamedChunk, n, kind.EOL)
+			written, werr := io.WriteString(w, chunk)
+			total += written
+			if werr != nil {
+				return total, werr
+			}
+			written, werr = w.Write(append(b[:n:n], kind.EOL...))
+			total += written
+			if werr != nil {
+				return total, werr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+	n, err := io.WriteString(w, fmt.Sprintf("%c0%s", kind.StreamedChunk, kind.EOL))
+	total += n
+	return total, err
+}
+
 // simpleToByte converts simple types to bytes.
 func simpleToByte(m Message) ([]byte, error) {
 	// for simpleToByte types, we just need to string format the relevant type.
@@ -291,15 +661,30 @@ func simpleToByte(m Message) ([]byte, error) {
 	case kind.Int:
 		body = []byte(strconv.FormatInt(m.Int, 10))
 	case kind.Bool:
-		body = []byte(fmt.Sprintf("%t", m.Bool))
+		body = []byte("f")
+		if m.Bool {
+			body = []byte("t")
+		}
 	case kind.Error:
-		if m.Error != nil {
-			return nil, fmt.Errorf("empty Error field for Error type message %s", m)
+		if m.Error == nil {
+			return nil, fmt.Errorf("empty Error field for Error type message %s", string(m.Kind))
 		}
 		body = []byte(m.Error.Error())
 	case kind.Double:
-		body = []byte(strconv.FormatFloat(m.Double, 'f', -1, 64))
+		switch {
+		case math.IsInf(m.Double, 1):
+			body = []byte("inf")
+		case math.IsInf(m.Double, -1):
+			body = []byte("-inf")
+		case math.IsNaN(m.Double):
+			body = []byte("nan")
+		default:
+			body = []byte(strconv.FormatFloat(m.Double, 'f', -1, 64))
+		}
 	case kind.BigNumber:
+		if m.BigNumber == nil {
+			return nil, fmt.Errorf("empty BigNumber field for BigNumber type message")
+		}
 		body = []byte(m.BigNumber.String())
 	}
 
@@ -2,6 +2,7 @@ package message
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
@@ -80,7 +81,7 @@ func TestDecode_AggregateMessages(t *testing.T) {
 	}{
 		{
 			name:              "Decode Verbatim SimpleString",
-			input:             string([]byte{byte(kind.VerbatimString)}) + "11" + EOL + "txt" + EOL + "hello world",
+			input:             string([]byte{byte(kind.VerbatimString)}) + "15" + EOL + "txt:hello world" + EOL,
 			expectedRunLength: 11,
 			expectedEncoding:  "txt",
 			expectErr:         false,
@@ -185,3 +186,150 @@ func TestDecode_BigNumber(t *testing.T) {
 		t.Errorf("expected big number %v, got %v", bigNum, msg.BigNumber)
 	}
 }
+
+// roundTripTests has an example of every kind of message.
+var roundTripTests = []struct {
+	name  string
+	input string
+}{
+	{"SimpleString", "+OK\r\n"},
+	{"Error", "-ERR unknown command 'foobar'\r\n"},
+	{"Int", ":-42\r\n"},
+	{"BulkString", "$5\r\nhello\r\n"},
+	{"Empty BulkString", "$0\r\n\r\n"},
+	{"Null BulkString", "$-1\r\n"},
+	{"Array", "*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n"},
+	{"Nested Array", "*3\r\n*2\r\n:1\r\n:2\r\n*1\r\n$1\r\na\r\n+done\r\n"},
+	{"Empty Array", "*0\r\n"},
+	{"Null Array", "*-1\r\n"},
+	{"Null", "_\r\n"},
+	{"Bool true", "#t\r\n"},
+	{"Bool false", "#f\r\n"},
+	{"Double", ",1.5\r\n"},
+	{"Double inf", ",inf\r\n"},
+	{"Double -inf", ",-inf\r\n"},
+	{"Double nan", ",nan\r\n"},
+	{"BigNumber", "(3492890328409238509324850943850943825024385\r\n"},
+	{"BulkError", "!21\r\nSYNTAX invalid syntax\r\n"},
+	{"VerbatimString", "=15\r\ntxt:Some string\r\n"},
+	{"Map", "%2\r\n+first\r\n:1\r\n$6\r\nsecond\r\n*1\r\n:2\r\n"},
+	{"Attribute", "|1\r\n+key-popularity\r\n%2\r\n$1\r\na\r\n,0.1923\r\n$1\r\nb\r\n,0.0012\r\n*2\r\n:2039123\r\n:9543892\r\n"},
+	{"Attribute in Array", "*2\r\n|1\r\n+ttl\r\n:3600\r\n:1\r\n:2\r\n"},
+	{"Set", "~3\r\n+orange\r\n+apple\r\n#t\r\n"},
+	{"Push", ">3\r\n$7\r\nmessage\r\n$7\r\nchannel\r\n$5\r\nhello\r\n"},
+	{"Streamed String", "$?\r\n;4\r\nHell\r\n;5\r\no wor\r\n;2\r\nld\r\n;0\r\n"},
+	{"Streamed Array", "*?\r\n:1\r\n$2\r\nab\r\n*?\r\n:3\r\n.\r\n.\r\n"},
+	{"Streamed Set", "~?\r\n+a\r\n.\r\n"},
+	{"Streamed Map", "%?\r\n+a\r\n:1\r\n+b\r\n:2\r\n.\r\n"},
+	{"Streamed Attribute", "|?\r\n+ttl\r\n:3600\r\n.\r\n:1\r\n"},
+}
+
+// TestRoundTrip decodes every kind of message and checks that encoding it again reproduces the input.
+func TestRoundTrip(t *testing.T) {
+	for _, test := range roundTripTests {
+		t.Run(test.name, func(t *testing.T) {
+			encoder := Encoder{ChunkSize: 5}
+			msg, err := encoder.Decode(strings.NewReader(test.input))
+			assert.NilError(t, err)
+
+			buffer := &bytes.Buffer{}
+			total, err := encoder.Encode(msg, buffer)
+			assert.NilError(t, err)
+			assert.Equal(t, buffer.String(), test.input)
+			assert.Equal(t, total, len(test.input))
+		})
+	}
+}
+
+// TestIterate_Sequence tests that consecutive messages decode correctly, whether or not earlier ones were consumed.
+func TestIterate_Sequence(t *testing.T) {
+	input := "*2\r\n*2\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n" +
+		"$5\r\nhello\r\n" +
+		"%1\r\n+k\r\n*1\r\n:1\r\n" +
+		"$?\r\n;2\r\nhi\r\n;0\r\n" +
+		"|1\r\n+meta\r\n:1\r\n#t\r\n" +
+		":7\r\n"
+
+	encoder := Encoder{}
+
+	var kinds []kind.Kind
+	for msg, err := range encoder.Iterate(strings.NewReader(input)) {
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		assert.NilError(t, err)
+		kinds = append(kinds, msg.Kind)
+
+		// only consume the first element of the first array, leaving the rest to be discarded.
+		if msg.Kind == kind.Array {
+			for el, err := range msg.Seq {
+				assert.NilError(t, err)
+				assert.Equal(t, el.Kind, kind.Array)
+				for s, err := range el.Seq {
+					assert.NilError(t, err)
+					all, err := s.ReadAll()
+					assert.NilError(t, err)
+					assert.Equal(t, all, "a")
+					break
+				}
+				break
+			}
+		}
+		if msg.Kind == kind.Bool {
+			assert.Assert(t, msg.Attribute != nil)
+			assert.Equal(t, msg.Attribute.String(), "|{+meta: :1}")
+		}
+		if msg.Kind == kind.Int {
+			assert.Equal(t, msg.Int, int64(7))
+		}
+	}
+
+	assert.DeepEqual(t, kinds, []kind.Kind{kind.Array, kind.BulkString, kind.Map, kind.BulkString, kind.Bool, kind.Int})
+}
+
+// TestDecode_Resp3Strings tests the payloads of streamed strings and null bulk strings.
+func TestDecode_Resp3Strings(t *testing.T) {
+	encoder := Encoder{}
+
+	msg, err := encoder.Decode(strings.NewReader("$?\r\n;4\r\nHell\r\n;5\r\no wor\r\n;2\r\nld\r\n;0\r\n"))
+	assert.NilError(t, err)
+	assert.Assert(t, msg.Streamed)
+	all, err := msg.ReadAll()
+	assert.NilError(t, err)
+	assert.Equal(t, all, "Hello world")
+
+	msg, err = encoder.Decode(strings.NewReader("$-1\r\n"))
+	assert.NilError(t, err)
+	assert.Assert(t, msg.IsNull())
+	all, err = msg.ReadAll()
+	assert.NilError(t, err)
+	assert.Equal(t, all, "")
+
+	msg, err = encoder.Decode(strings.NewReader("*-1\r\n"))
+	assert.NilError(t, err)
+	assert.Assert(t, msg.IsNull())
+	assert.Assert(t, msg.Seq == nil)
+}
+
+// TestDecode_Truncated tests that incomplete aggregates are reported as unexpected EOFs.
+func TestDecode_Truncated(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"Array", "*2\r\n:1\r\n"},
+		{"Streamed Array", "*?\r\n:1\r\n"},
+		{"BulkString", "*1\r\n$5\r\nhel"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encoder := Encoder{}
+			msg, err := encoder.Decode(strings.NewReader(test.input))
+			assert.NilError(t, err)
+
+			_, err = encoder.Encode(msg, io.Discard)
+			assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package message
+
+import (
+	"io"
+)
+
+// Materialize reads m into memory, filling Bytes and Elems in place of Reader, Seq and Assoc, so that its elements
+// can be accessed by index and read more than once.
+//
+// A bulk string longer than lazyLen is left to be read through its Reader if it is the last element of m, since
+// nothing after it has to be read first. A negative lazyLen reads everything into memory.
+//
+// A message that is already in memory, e.g. one read by Parser, is returned as it is.
+func Materialize(m Message, lazyLen int64) (Message, error) {
+	if inMemory(&m) {
+		return m, nil
+	}
+	return materialize(m, lazyLen, true)
+}
+
+// inMemory says whether m and everything in it has been read into memory.
+func inMemory(m *Message) bool {
+	if m.Reader != nil || m.Seq != nil || m.Assoc != nil {
+		return false
+	}
+	if m.Attribute != nil && !inMemory(m.Attribute) {
+		return false
+	}
+	for i := range m.Elems {
+		if !inMemory(&m.Elems[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// materialize reads m into memory; last says whether m is the last thing to be read, so that it can be left lazy.
+func materialize(m Message, lazyLen int64, last bool) (Message, error) {
+	if m.Attribute != nil {
+		attr, err := materialize(*m.Attribute, -1, false)
+		if err != nil {
+			return Message{}, err
+		}
+		m.Attribute = &attr
+	}
+
+	switch {
+	case m.Kind.IsBulk() && m.Reader != nil:
+		if last && isLazy(m, lazyLen) {
+			return m, nil
+		}
+		var b []byte
+		var err error
+		if m.Streamed {
+			b, err = io.ReadAll(m.Reader)
+		} else {
+			b = make([]byte, m.RunLength)
+			_, err = io.ReadFull(m.Reader, b)
+		}
+		if err != nil {
+			return Message{}, err
+		}
+		m.Reader = nil
+		m.Bytes = b
+	case m.Seq != nil:
+		var elems []Message
+		for el, err := range m.Seq {
+			if err != nil {
+				return Message{}, err
+			}
+			isLast := last && !m.Streamed && int64(len(elems)) == m.RunLength-1
+			if isLast && isLazy(el, lazyLen) {
+				// breaking out of the sequence leaves the element to be read.
+				elems = append(elems, el)
+				break
+			}
+			el, err = materialize(el, lazyLen, false)
+			if err != nil {
+				return Message{}, err
+			}
+			elems = append(elems, el)
+		}
+		m.Seq = nil
+		m.Elems = elems
+	case m.Assoc != nil:
+		var kvs []Message
+		for kv, err := range m.Assoc {
+			if err != nil {
+				return Message{}, err
+			}
+			k, err := materialize(kv[0], -1, false)
+			if err != nil {
+				return Message{}, err
+			}
+			v, err := materialize(kv[1], -1, false)
+			if err != nil {
+				return Message{}, err
+			}
+			kvs = append(kvs, k, v)
+		}
+		m.Assoc = nil
+		m.Elems = kvs
+	case m.Elems != nil:
+		elems := make([]Message, len(m.Elems))
+		for i, el := range m.Elems {
+			el, err := materialize(el, lazyLen, last && i == len(m.Elems)-1)
+			if err != nil {
+				return Message{}, err
+			}
+			elems[i] = el
+		}
+		m.Elems = elems
+	}
+	return m, nil
+}
+
+// isLazy says whether m is a bulk string that is too large to be read into memory.
+func isLazy(m Message, lazyLen int64) bool {
+	return lazyLen >= 0 && m.Kind.IsBulk() && m.Reader != nil && !m.Streamed && m.RunLength > lazyLen
+}
@@ -0,0 +1,77 @@
+package message
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestMaterialize(t *testing.T) {
+	value := strings.Repeat("v", 20)
+	input := "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$20\r\n" + value + "\r\n" +
+		"*2\r\n%1\r\n+k\r\n$20\r\n" + value + "\r\n$20\r\n" + value + "\r\n" +
+		":1\r\n"
+	r := bufio.NewReader(strings.NewReader(input))
+	encoder := Encoder{}
+
+	t.Run("the last large bulk string is left lazy", func(t *testing.T) {
+		msg, err := encoder.Decode(r)
+		assert.NilError(t, err)
+		msg, err = Materialize(msg, 10)
+		assert.NilError(t, err)
+
+		assert.Equal(t, len(msg.Elems), 3)
+		for range 2 {
+			assert.Equal(t, string(msg.Elems[0].Bytes), "SET")
+			assert.Equal(t, string(msg.Elems[1].Bytes), "key")
+		}
+		assert.Assert(t, msg.Elems[2].Reader != nil)
+		all, err := msg.Elems[2].ReadAll()
+		assert.NilError(t, err)
+		assert.Equal(t, all, value)
+	})
+
+	t.Run("large bulk strings that are not last are read", func(t *testing.T) {
+		msg, err := encoder.Decode(r)
+		assert.NilError(t, err)
+		msg, err = Materialize(msg, 10)
+		assert.NilError(t, err)
+
+		assert.Equal(t, len(msg.Elems), 2)
+		assert.Equal(t, msg.Elems[0].Kind.String(), "Assoc")
+		assert.Equal(t, string(msg.Elems[0].Elems[1].Bytes), value)
+		all, err := msg.Elems[1].ReadAll()
+		assert.NilError(t, err)
+		assert.Equal(t, all, value)
+	})
+
+	t.Run("the next message can be read", func(t *testing.T) {
+		msg, err := encoder.Decode(r)
+		assert.NilError(t, err)
+		assert.Equal(t, msg.Int, int64(1))
+	})
+}
+
+// TestMaterialize_InMemory tests that a message already in memory is returned without copying it.
+func TestMaterialize_InMemory(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+	parser := Parser{}
+	msg, err := parser.Parse(bufio.NewReader(strings.NewReader("*2\r\n$3\r\nGET\r\n|1\r\n+a\r\n:1\r\n$3\r\nkey\r\n")))
+	assert.NilError(t, err)
+	defer parser.Release(msg)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		m, err := Materialize(*msg, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if &m.Elems[0] != &msg.Elems[0] {
+			t.Fatal("elements were copied")
+		}
+	})
+	assert.Equal(t, allocs, float64(0))
+}
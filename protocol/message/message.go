@@ -34,29 +34,82 @@ type Message struct {
 	BigNumber    *big.Int
 
 	// including indicator bytes, line feeds, encoded run length, etc.
+	// For collection types only the header is counted, since elements are decoded lazily.
 	TotalMessageSize int64
 
 	// Aggregate types
+	//
+	// RunLength is -1 for the RESP2 null bulk string and null array ($-1 and *-1).
 	RunLength int64
+	// Streamed is set for RESP3 streamed strings and aggregates, whose RunLength is not known up front.
+	Streamed bool
 	// Reader is for large strings, e.g. BulkError, String, VerbatimString
 	Reader io.Reader
+	// Bytes holds the payload of strings that are in memory, in place of Reader.
+	Bytes []byte
 
 	Encoding [3]byte
 
 	// Collection Types
 	Seq   iter.Seq2[Message, error]    // Sequence
 	Assoc iter.Seq2[[2]Message, error] // Association of pairs
+	// Elems holds the elements of collections that are in memory, in place of Seq or Assoc. For Map and Attribute,
+	// keys and values alternate.
+	Elems []Message
+
+	// Attribute holds the RESP3 attribute frame (|) that preceded this message on the wire, if any.
+	Attribute *Message
+}
+
+// IsNull reports whether the message is a RESP3 null or a RESP2 null bulk string or null array.
+func (m Message) IsNull() bool {
+	return m.Kind == kind.Null || (m.RunLength == -1 && !m.Streamed)
 }
 
 func (m Message) ReadAll() (string, error) {
+	if m.Reader == nil || m.IsNull() {
+		return string(m.Bytes), nil
+	}
+	if m.Streamed {
+		b, err := io.ReadAll(m.Reader)
+		return string(b), err
+	}
 	b := make([]byte, m.RunLength)
 	n, err := io.ReadFull(m.Reader, b)
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 	return string(b[:n]), nil
 }
 
+// Elements iterates over the elements of a collection, whether they are decoded lazily or held in memory.
+func (m Message) Elements() iter.Seq2[Message, error] {
+	if m.Seq != nil {
+		return m.Seq
+	}
+	return func(yield func(Message, error) bool) {
+		for i := range m.Elems {
+			if !yield(m.Elems[i], nil) {
+				return
+			}
+		}
+	}
+}
+
+// Pairs iterates over the key/value pairs of a Map or Attribute, whether they are decoded lazily or held in memory.
+func (m Message) Pairs() iter.Seq2[[2]Message, error] {
+	if m.Assoc != nil {
+		return m.Assoc
+	}
+	return func(yield func([2]Message, error) bool) {
+		for i := 0; i+1 < len(m.Elems); i += 2 {
+			if !yield([2]Message{m.Elems[i], m.Elems[i+1]}, nil) {
+				return
+			}
+		}
+	}
+}
+
 func SimpleString(s string) Message {
 	return Message{Kind: kind.SimpleString, SimpleString: s}
 }
@@ -85,6 +138,11 @@ func BigNumber(b *big.Int) Message {
 	return Message{Kind: kind.BigNumber, BigNumber: b}
 }
 
+// BulkBytes is a bulk string held in memory.
+func BulkBytes(b []byte) Message {
+	return Message{Kind: kind.BulkString, Bytes: b, RunLength: int64(len(b))}
+}
+
 func BulkString(s io.Reader, runLength int64) Message {
 	return Message{Kind: kind.BulkString, Reader: s, RunLength: runLength}
 }
@@ -93,32 +151,35 @@ func BulkError(s io.Reader, runlength int64) Message {
 	return Message{Kind: kind.BulkError, Reader: s, RunLength: runlength}
 }
 
+// NullBulkString is the RESP2 null bulk string, $-1
+func NullBulkString() Message {
+	return Message{Kind: kind.BulkString, RunLength: -1}
+}
+
+// StreamedBulkString is a RESP3 streamed string, which is written in chunks until s is exhausted.
+func StreamedBulkString(s io.Reader) Message {
+	return Message{Kind: kind.BulkString, Reader: s, Streamed: true}
+}
+
 func VerbatimString(s io.Reader, runlength int64, encoding [3]byte) Message {
 	return Message{Kind: kind.VerbatimString, Reader: s, RunLength: runlength, Encoding: encoding}
 }
 
 func Array(arrs ...Message) Message {
-	i := iter.Seq2[Message, error](func(yield func(Message, error) bool) {
-		for i := 0; i < len(arrs); i += 1 {
-			ok := yield(arrs[i], nil)
-			if !ok {
-				return
-			}
-		}
-	})
-	return Message{Kind: kind.Array, Seq: i, RunLength: int64(len(arrs))}
+	return Message{Kind: kind.Array, Elems: arrs, RunLength: int64(len(arrs))}
+}
+
+// NullArray is the RESP2 null array, *-1
+func NullArray() Message {
+	return Message{Kind: kind.Array, RunLength: -1}
 }
 
 func Set(arrs ...Message) Message {
-	i := iter.Seq2[Message, error](func(yield func(Message, error) bool) {
-		for i := 0; i < len(arrs); i += 1 {
-			ok := yield(arrs[i], nil)
-			if !ok {
-				return
-			}
-		}
-	})
-	return Message{Kind: kind.Set, Seq: i, RunLength: int64(len(arrs))}
+	return Message{Kind: kind.Set, Elems: arrs, RunLength: int64(len(arrs))}
+}
+
+func Push(arrs ...Message) Message {
+	return Message{Kind: kind.Push, Elems: arrs, RunLength: int64(len(arrs))}
 }
 
 func Map(kvs ...Message) Message {
@@ -126,18 +187,14 @@ func Map(kvs ...Message) Message {
 		panic("must have even number of key/value pairs")
 	}
 
-	i := iter.Seq2[[2]Message, error](func(yield func([2]Message, error) bool) {
-		for i := 0; i < len(kvs); i += 2 {
-			k := kvs[i]
-			v := kvs[i+1]
-			ok := yield([2]Message{k, v}, nil)
-			if !ok {
-				return
-			}
-		}
-	})
+	return Message{Kind: kind.Map, Elems: kvs, RunLength: int64(len(kvs) / 2)}
+}
 
-	return Message{Kind: kind.Map, Assoc: i, RunLength: int64(len(kvs) / 2)}
+// Attribute creates an attribute frame, which is attached to a reply via Message.Attribute.
+func Attribute(kvs ...Message) Message {
+	m := Map(kvs...)
+	m.Kind = kind.Attribute
+	return m
 }
 
 func (m Message) String() string {
@@ -152,9 +209,11 @@ func (m Message) string() string {
 		return m.Error.Error()
 	case kind.Int:
 		return fmt.Sprintf("%d", m.Int)
-	case kind.Array:
+	case kind.Null:
+		return ""
+	case kind.Array, kind.Set, kind.Push:
 		var s []string
-		for msg, err := range m.Seq {
+		for msg, err := range m.Elements() {
 			if err == nil {
 				s = append(s, msg.String())
 			} else {
@@ -162,10 +221,10 @@ func (m Message) string() string {
 			}
 		}
 		return fmt.Sprintf("%s", strings.Join(s, " "))
-	case kind.Map:
+	case kind.Map, kind.Attribute:
 		var s []string
 
-		for pair, err := range m.Assoc {
+		for pair, err := range m.Pairs() {
 			if err == nil {
 				s = append(s, pair[0].String()+": "+pair[1].String())
 			} else {
@@ -179,8 +238,11 @@ func (m Message) string() string {
 		return fmt.Sprintf("%v", m.Double)
 	case kind.BigNumber:
 		return fmt.Sprintf("%v", m.BigNumber)
-	case kind.VerbatimString:
-		return m.SimpleString
+	case kind.BulkString, kind.BulkError, kind.VerbatimString:
+		if m.Reader != nil {
+			return fmt.Sprintf("(%d bytes)", m.RunLength)
+		}
+		return string(m.Bytes)
 	default:
 		return fmt.Sprintf("Unknown %s", m.Kind)
 	}
@@ -104,6 +104,10 @@ func TestEncode_ErrorMessage(t *testing.T) {
 	message := Error("this is an error")
 
 	_, err := encoder.Encode(message, buffer)
+	assert.NilError(t, err)
+	assert.Equal(t, buffer.String(), "-this is an error"+kind.EOL)
+
+	_, err = encoder.Encode(Message{Kind: kind.Error}, buffer)
 	if err == nil {
 		t.Fatal("expected error but got none")
 	}
@@ -0,0 +1,580 @@
+package message
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/awinterman/anarchoredis/protocol/kind"
+)
+
+// Parser decodes whole messages from a *bufio.Reader into memory, filling Message.Bytes and Message.Elems rather
+// than Reader, Seq and Assoc. It is an alternative to Encoder for the proxy hot path.
+//
+// When a message fits in the reader's buffer, bulk payloads are sliced out of the buffer without copying. Those
+// slices are only valid until the next read from the reader; callers that keep payloads for longer must copy them.
+// Messages that do not fit in the buffer are copied out of it instead.
+//
+// Messages are reused: pass them to Release once they are no longer needed.
+type Parser struct {
+	// MaxBulkLen is the largest bulk string that will be read, like proto-max-bulk-len. Defaults to 512MB.
+	MaxBulkLen int64
+	// MaxAggregateLen is the largest number of elements of an aggregate that will be read, like the multibulk limit
+	// of redis. Defaults to 1024*1024.
+	MaxAggregateLen int64
+	// MaxDepth is how deeply aggregates will be read nested in one another. Defaults to 512.
+	MaxDepth int
+
+	pool sync.Pool
+}
+
+// errShort is returned while parsing in place when the buffer does not yet hold the whole message.
+var errShort = errors.New("short buffer")
+
+// Parse reads the next message from br.
+func (p *Parser) Parse(br *bufio.Reader) (*Message, error) {
+	m, _, err := p.parseFrom(br, false)
+	return m, err
+}
+
+// ParseFrame reads the next message from br, and also returns the exact bytes it was read from. Like the payloads
+// of the message, the bytes are only valid until the next read from br when the message fit in its buffer.
+func (p *Parser) ParseFrame(br *bufio.Reader) (*Message, []byte, error) {
+	return p.parseFrom(br, true)
+}
+
+func (p *Parser) parseFrom(br *bufio.Reader, capture bool) (*Message, []byte, error) {
+	m := p.get()
+
+	need := max(br.Buffered(), 1)
+	for need <= br.Size() {
+		b, err := br.Peek(need)
+		if err == nil {
+			// more may have arrived than was asked for; peeking what is buffered does not block.
+			b, _ = br.Peek(br.Buffered())
+		}
+
+		src := source{b: b}
+		perr := p.parse(&src, m, 0)
+		switch {
+		case perr == nil:
+			_, err = br.Discard(src.off)
+			if err != nil {
+				p.Release(m)
+				return nil, nil, err
+			}
+			return m, b[:src.off], nil
+		case !errors.Is(perr, errShort):
+			p.Release(m)
+			return nil, nil, perr
+		case err != nil:
+			p.Release(m)
+			if errors.Is(err, io.EOF) && len(b) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, nil, err
+		}
+		need = src.need
+	}
+
+	// the message is larger than the buffer, so it has to be copied out.
+	src := source{br: br, capture: capture}
+	err := p.parse(&src, m, 0)
+	if err != nil {
+		p.Release(m)
+		return nil, nil, err
+	}
+	return m, src.raw, nil
+}
+
+// Release returns m to the pool. m must not be used afterward.
+func (p *Parser) Release(m *Message) {
+	if m == nil {
+		return
+	}
+	p.reset(m)
+	p.pool.Put(m)
+}
+
+func (p *Parser) get() *Message {
+	m, ok := p.pool.Get().(*Message)
+	if !ok {
+		return &Message{}
+	}
+	return m
+}
+
+func (p *Parser) maxBulkLen() int64 {
+	if p.MaxBulkLen == 0 {
+		return 512 * 1000000
+	}
+	return p.MaxBulkLen
+}
+
+func (p *Parser) maxAggregateLen() int64 {
+	if p.MaxAggregateLen == 0 {
+		return 1024 * 1024
+	}
+	return p.MaxAggregateLen
+}
+
+func (p *Parser) maxDepth() int {
+	if p.MaxDepth == 0 {
+		return 512
+	}
+	return p.MaxDepth
+}
+
+// reset clears m, keeping the capacity of its elements so that they can be reused.
+func (p *Parser) reset(m *Message) {
+	elems := m.Elems[:cap(m.Elems)]
+	for i := range elems {
+		p.reset(&elems[i])
+	}
+	if m.Attribute != nil {
+		p.Release(m.Attribute)
+	}
+	*m = Message{Elems: m.Elems[:0]}
+}
+
+// parse reads one message from src into m, nested in depth aggregates.
+func (p *Parser) parse(src *source, m *Message, depth int) error {
+	*m = Message{Elems: m.Elems[:0]}
+	start := src.offset()
+
+	k, err := src.readByte()
+	if err != nil {
+		return err
+	}
+	line, err := src.line()
+	if err != nil {
+		return err
+	}
+
+	m.Kind = kind.Kind(k)
+	switch m.Kind.Category() {
+	case kind.CategorySimple:
+		err = parseSimpleBytes(m, line)
+		if err != nil {
+			return err
+		}
+	case kind.CategoryAggregate:
+		if !m.Kind.IsBulk() && depth >= p.maxDepth() {
+			return fmt.Errorf("%w: %s nested deeper than %d", ErrProtocol, m.Kind, p.maxDepth())
+		}
+		if string(line) == kind.Streamed {
+			if m.Kind == kind.Attribute {
+				return p.parseAttribute(src, m, -1, start, depth)
+			}
+			err = p.parseStreamed(src, m, depth)
+			if err != nil {
+				return err
+			}
+			break
+		}
+
+		n, err := parseInt(line)
+		if err != nil {
+			return err
+		}
+		m.RunLength = n
+		if n == -1 && (m.Kind == kind.BulkString || m.Kind == kind.Array) {
+			// RESP2 null
+			break
+		}
+		if n < 0 {
+			return fmt.Errorf("%w: invalid length %d for %s", ErrProtocol, n, m.Kind)
+		}
+		limit := p.maxAggregateLen()
+		switch m.Kind {
+		case kind.BulkString, kind.BulkError, kind.VerbatimString:
+			limit = p.maxBulkLen()
+		}
+		if n > limit {
+			return fmt.Errorf("%w: length %d for %s exceeds %d", ErrProtocol, n, m.Kind, limit)
+		}
+
+		switch m.Kind {
+		case kind.BulkString, kind.BulkError:
+			m.Bytes, err = src.body(n)
+			if err != nil {
+				return err
+			}
+		case kind.VerbatimString:
+			b, err := src.body(n)
+			if err != nil {
+				return err
+			}
+			if n < 4 || b[3] != ':' {
+				return fmt.Errorf("%w: invalid verbatim string %q", ErrProtocol, b)
+			}
+			copy(m.Encoding[:], b[:3])
+			m.Bytes = b[4:]
+			m.RunLength = n - 4
+		case kind.Array, kind.Set, kind.Push:
+			err = p.parseElems(src, m, n, depth)
+			if err != nil {
+				return err
+			}
+		case kind.Map:
+			err = p.parseElems(src, m, 2*n, depth)
+			if err != nil {
+				return err
+			}
+		case kind.Attribute:
+			return p.parseAttribute(src, m, n, start, depth)
+		}
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrProtocol, k)
+	}
+
+	m.TotalMessageSize = src.offset() - start
+	return nil
+}
+
+// parseAttribute reads the n pairs of an attribute, or pairs until the end if n is negative, whose header started at
+// start, and then the reply it annotates into m.
+func (p *Parser) parseAttribute(src *source, m *Message, n int64, start int64, depth int) error {
+	attr := p.get()
+	attr.Kind = kind.Attribute
+	var err error
+	if n < 0 {
+		err = p.parseStreamed(src, attr, depth)
+	} else {
+		attr.RunLength = n
+		err = p.parseElems(src, attr, 2*n, depth)
+	}
+	if err != nil {
+		p.Release(attr)
+		return err
+	}
+	attr.TotalMessageSize = src.offset() - start
+
+	// the reply counts as nested in the attribute, so that attributes can't be chained without limit.
+	err = p.parse(src, m, depth+1)
+	if err != nil {
+		p.Release(attr)
+		return err
+	}
+	if m.Kind == kind.Attribute {
+		p.Release(attr)
+		return fmt.Errorf("%w: attribute must be followed by a reply", ErrProtocol)
+	}
+	m.Attribute = attr
+	m.TotalMessageSize = src.offset() - start
+	return nil
+}
+
+// parseElems reads n elements from src into m.Elems, which is nested in depth aggregates. They are added as they are
+// read rather than allocated up front, so that a header claiming many elements costs nothing until they arrive.
+func (p *Parser) parseElems(src *source, m *Message, n int64, depth int) error {
+	for range n {
+		err := p.parse(src, nextElem(m), depth+1)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextElem adds an element to m.Elems, reusing the capacity left by earlier messages, and returns it.
+func nextElem(m *Message) *Message {
+	if len(m.Elems) < cap(m.Elems) {
+		m.Elems = m.Elems[:len(m.Elems)+1]
+	} else {
+		m.Elems = append(m.Elems, Message{})
+	}
+	return &m.Elems[len(m.Elems)-1]
+}
+
+// parseStreamed reads a streamed string or aggregate, whose `?` header has already been read, into m, which is nested
+// in depth aggregates. Its chunks or elements are held to the same limits as those of a string or aggregate whose
+// length is known up front.
+func (p *Parser) parseStreamed(src *source, m *Message, depth int) error {
+	m.Streamed = true
+	switch m.Kind {
+	case kind.BulkString:
+		m.Bytes = []byte{}
+		var total int64
+		for {
+			k, err := src.readByte()
+			if err != nil {
+				return err
+			}
+			if kind.Kind(k) != kind.StreamedChunk {
+				return fmt.Errorf("%w: expected streamed string chunk, got %q", ErrProtocol, k)
+			}
+			line, err := src.line()
+			if err != nil {
+				return err
+			}
+			n, err := parseInt(line)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return nil
+			}
+			if n < 0 {
+				return fmt.Errorf("%w: invalid chunk length %d for %s", ErrProtocol, n, m.Kind)
+			}
+			total += n
+			if total > p.maxBulkLen() {
+				return fmt.Errorf("%w: streamed %s exceeds %d bytes", ErrProtocol, m.Kind, p.maxBulkLen())
+			}
+			chunk, err := src.body(n)
+			if err != nil {
+				return err
+			}
+			m.Bytes = append(m.Bytes, chunk...)
+		}
+	case kind.Array, kind.Set, kind.Push, kind.Map, kind.Attribute:
+		// the elements of a map are counted in pairs.
+		limit, pairs := p.maxAggregateLen(), m.Kind == kind.Map || m.Kind == kind.Attribute
+		if pairs {
+			limit *= 2
+		}
+		for {
+			k, err := src.peekByte()
+			if err != nil {
+				return err
+			}
+			if kind.Kind(k) == kind.StreamedEnd {
+				_, _ = src.readByte()
+				_, err = src.line()
+				if err == nil && pairs && len(m.Elems)%2 != 0 {
+					err = fmt.Errorf("%w: streamed %s has a key without a value", ErrProtocol, m.Kind)
+				}
+				return err
+			}
+			if int64(len(m.Elems)) >= limit {
+				return fmt.Errorf("%w: streamed %s exceeds %d elements", ErrProtocol, m.Kind, limit)
+			}
+			err = p.parse(src, nextElem(m), depth+1)
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%w: %s cannot be streamed", ErrProtocol, m.Kind)
+	}
+}
+
+// parseSimpleBytes fills in m, whose kind is simple, from line.
+func parseSimpleBytes(m *Message, line []byte) error {
+	switch m.Kind {
+	case kind.SimpleString:
+		m.SimpleString = internString(line)
+	case kind.Error:
+		m.Error = errors.New(string(line))
+	case kind.Int:
+		i, err := parseInt(line)
+		if err != nil {
+			return err
+		}
+		m.Int = i
+	case kind.Null:
+	case kind.Bool:
+		switch string(line) {
+		case "t":
+			m.Bool = true
+		case "f":
+		default:
+			b, err := strconv.ParseBool(string(line))
+			if err != nil {
+				return err
+			}
+			m.Bool = b
+		}
+	case kind.Double:
+		f, err := strconv.ParseFloat(string(line), 64)
+		if err != nil {
+			return err
+		}
+		m.Double = f
+	case kind.BigNumber:
+		i, ok := (&big.Int{}).SetString(string(line), 10)
+		if !ok {
+			return fmt.Errorf("%w: invalid big number %q", ErrProtocol, line)
+		}
+		m.BigNumber = i
+	}
+	return nil
+}
+
+// internString avoids allocating for the most common simple string replies.
+func internString(b []byte) string {
+	switch string(b) {
+	case "OK":
+		return "OK"
+	case "PONG":
+		return "PONG"
+	case "QUEUED":
+		return "QUEUED"
+	default:
+		return string(b)
+	}
+}
+
+// parseInt parses a decimal integer without allocating.
+func parseInt(b []byte) (int64, error) {
+	neg := len(b) > 0 && b[0] == '-'
+	digits := b
+	if neg {
+		digits = b[1:]
+	}
+	if len(digits) == 0 || len(digits) > 18 {
+		return strconv.ParseInt(string(b), 10, 64)
+	}
+
+	var n int64
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return strconv.ParseInt(string(b), 10, 64)
+		}
+		n = n*10 + int64(c-'0')
+	}
+	if neg {
+		n = -n
+	}
+	return n, nil
+}
+
+// source is what the Parser reads from: either a buffer expected to hold a whole message, in which case results
+// alias it, or a bufio.Reader, in which case payloads are copied.
+type source struct {
+	b    []byte
+	off  int
+	need int
+
+	br   *bufio.Reader
+	read int64
+	// capture keeps everything read from br in raw, and payloads are sliced from raw.
+	capture bool
+	raw     []byte
+}
+
+func (s *source) offset() int64 {
+	if s.br != nil {
+		return s.read
+	}
+	return int64(s.off)
+}
+
+// short records that at least n bytes are needed to parse the message.
+func (s *source) short(n int) error {
+	s.need = n
+	return errShort
+}
+
+func (s *source) readByte() (byte, error) {
+	if s.br != nil {
+		b, err := s.br.ReadByte()
+		if err == nil {
+			s.read++
+			if s.capture {
+				s.raw = append(s.raw, b)
+			}
+		}
+		return b, err
+	}
+	if s.off >= len(s.b) {
+		return 0, s.short(s.off + 1)
+	}
+	s.off++
+	return s.b[s.off-1], nil
+}
+
+func (s *source) peekByte() (byte, error) {
+	if s.br != nil {
+		b, err := s.br.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		return b[0], nil
+	}
+	if s.off >= len(s.b) {
+		return 0, s.short(s.off + 1)
+	}
+	return s.b[s.off], nil
+}
+
+// line returns the rest of the current line, without its line feed.
+func (s *source) line() ([]byte, error) {
+	var line []byte
+	if s.br != nil {
+		var err error
+		line, err = s.br.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			// the slice is overwritten by the next read.
+			line = bytes.Clone(line)
+			var rest []byte
+			rest, err = s.br.ReadBytes('\n')
+			line = append(line, rest...)
+		}
+		s.read += int64(len(line))
+		if s.capture {
+			s.raw = append(s.raw, line...)
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		i := bytes.IndexByte(s.b[s.off:], '\n')
+		if i < 0 {
+			return nil, s.short(len(s.b) + 1)
+		}
+		line = s.b[s.off : s.off+i+1]
+		s.off += i + 1
+	}
+
+	if len(line) < len(kind.EOL) || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("%w: line not terminated by CRLF: %q", ErrProtocol, line)
+	}
+	return line[:len(line)-2], nil
+}
+
+// body returns the n byte payload of a bulk string and consumes the line feed that follows it.
+func (s *source) body(n int64) ([]byte, error) {
+	var b []byte
+	if s.br != nil {
+		if s.capture {
+			// payloads from earlier appends keep pointing at the old array if raw has to grow, which is fine.
+			s.raw = slices.Grow(s.raw, int(n)+len(kind.EOL))
+			b = s.raw[len(s.raw) : len(s.raw)+int(n)]
+		} else {
+			b = make([]byte, n)
+		}
+		read, err := io.ReadFull(s.br, b)
+		s.read += int64(read)
+		if err != nil {
+			return nil, err
+		}
+		err = readEOL(s.br)
+		if err != nil {
+			return nil, err
+		}
+		s.read += int64(len(kind.EOL))
+		if s.capture {
+			s.raw = append(s.raw[:len(s.raw)+int(n)], kind.EOL...)
+		}
+		return b, nil
+	}
+
+	end := s.off + int(n) + len(kind.EOL)
+	if end > len(s.b) {
+		return nil, s.short(end)
+	}
+	b = s.b[s.off : s.off+int(n)]
+	if string(s.b[s.off+int(n):end]) != kind.EOL {
+		return nil, fmt.Errorf("%w: bulk string not terminated by CRLF", ErrProtocol)
+	}
+	s.off = end
+	return b, nil
+}
@@ -0,0 +1,232 @@
+package message
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// TestParser_RoundTrip parses every kind of message, both in place and copied out of a buffer too small to hold
+// it, and checks that encoding it again reproduces the input.
+func TestParser_RoundTrip(t *testing.T) {
+	for _, size := range []int{16, 4096} {
+		for _, test := range roundTripTests {
+			t.Run(fmt.Sprintf("%s/%d", test.name, size), func(t *testing.T) {
+				parser := Parser{}
+				br := bufio.NewReaderSize(strings.NewReader(test.input), size)
+
+				msg, err := parser.Parse(br)
+				assert.NilError(t, err)
+				defer parser.Release(msg)
+
+				if msg.Streamed && msg.Kind.IsBulk() {
+					// chunk boundaries are not kept
+					assert.Equal(t, string(msg.Bytes), "Hello world")
+					return
+				}
+
+				buffer := &bytes.Buffer{}
+				encoder := Encoder{}
+				total, err := encoder.Encode(*msg, buffer)
+				assert.NilError(t, err)
+				assert.Equal(t, buffer.String(), test.input)
+				assert.Equal(t, total, len(test.input))
+				assert.Equal(t, msg.TotalMessageSize, int64(len(test.input)))
+
+				_, err = parser.Parse(br)
+				assert.ErrorIs(t, err, io.EOF)
+			})
+		}
+	}
+}
+
+// TestParser_ParseFrame tests that the raw bytes of every kind of message are returned, whether or not the message
+// fit in the buffer.
+func TestParser_ParseFrame(t *testing.T) {
+	for _, size := range []int{16, 4096} {
+		for _, test := range roundTripTests {
+			t.Run(fmt.Sprintf("%s/%d", test.name, size), func(t *testing.T) {
+				parser := Parser{}
+				input := test.input + ":1\r\n"
+				br := bufio.NewReaderSize(strings.NewReader(input), size)
+
+				msg, raw, err := parser.ParseFrame(br)
+				assert.NilError(t, err)
+				assert.Equal(t, string(raw), test.input)
+				parser.Release(msg)
+
+				msg, raw, err = parser.ParseFrame(br)
+				assert.NilError(t, err)
+				assert.Equal(t, string(raw), ":1\r\n")
+				assert.Equal(t, msg.Int, int64(1))
+			})
+		}
+	}
+}
+
+// TestParser_Sequence tests parsing consecutive messages that arrive in pieces, reusing released messages.
+func TestParser_Sequence(t *testing.T) {
+	var input string
+	for i := range 100 {
+		input += fmt.Sprintf("*3\r\n$3\r\nSET\r\n$%d\r\nkey:%d\r\n$%d\r\n%s\r\n",
+			len(fmt.Sprint(i))+4, i, i*10, strings.Repeat("v", i*10))
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		// write in small pieces so that messages straddle reads.
+		for i := 0; i < len(input); i += 7 {
+			_, _ = pw.Write([]byte(input[i:min(i+7, len(input))]))
+		}
+		_ = pw.Close()
+	}()
+
+	parser := Parser{}
+	br := bufio.NewReaderSize(pr, 256)
+	for i := range 100 {
+		msg, err := parser.Parse(br)
+		assert.NilError(t, err)
+		assert.Equal(t, len(msg.Elems), 3)
+		assert.Equal(t, string(msg.Elems[0].Bytes), "SET")
+		assert.Equal(t, string(msg.Elems[1].Bytes), fmt.Sprintf("key:%d", i))
+		assert.Equal(t, string(msg.Elems[2].Bytes), strings.Repeat("v", i*10))
+		parser.Release(msg)
+	}
+
+	_, err := parser.Parse(br)
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+// TestParser_Errors tests malformed and truncated input.
+func TestParser_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   error
+	}{
+		{"Unknown Kind", "\xffjunk\r\n", ErrProtocol},
+		{"Missing CR", "+OK\n", ErrProtocol},
+		{"Bad Terminator", "$2\r\nhixx", ErrProtocol},
+		{"Truncated", "*2\r\n$3\r\nGET\r\n", io.ErrUnexpectedEOF},
+		{"Too Large", "$1000\r\n", ErrProtocol},
+		{"Too Many Elements", "*5000000\r\n", ErrProtocol},
+		{"Too Many Pairs", "%2000000\r\n", ErrProtocol},
+		{"Truncated Attribute", "|1\r\n+a\r\n", io.ErrUnexpectedEOF},
+		{"Streamed String Too Large", "$?\r\n;60\r\n" + strings.Repeat("x", 60) + "\r\n;60\r\n", ErrProtocol},
+		{"Negative Chunk", "$?\r\n;-1\r\n", ErrProtocol},
+		{"Too Many Streamed Elements", "*?\r\n" + strings.Repeat(":1\r\n", 11), ErrProtocol},
+		{"Too Many Streamed Pairs", "%?\r\n" + strings.Repeat(":1\r\n", 21), ErrProtocol},
+		{"Odd Streamed Map", "%?\r\n+a\r\n.\r\n", ErrProtocol},
+		{"Too Deep", strings.Repeat("*1\r\n", 9) + ":1\r\n", ErrProtocol},
+		{"Too Deep Streamed", strings.Repeat("*?\r\n", 9), ErrProtocol},
+		{"Chained Attributes", strings.Repeat("|0\r\n", 9) + ":1\r\n", ErrProtocol},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parser := Parser{MaxBulkLen: 100, MaxAggregateLen: 10, MaxDepth: 8}
+			_, err := parser.Parse(bufio.NewReader(strings.NewReader(test.input)))
+			assert.ErrorIs(t, err, test.err)
+		})
+	}
+}
+
+// TestParser_Allocations tests that parsing a command in place does not allocate once the pool is warm.
+func TestParser_Allocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+	frame := []byte("*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n")
+	r := &repeatReader{data: frame}
+	br := bufio.NewReader(r)
+	parser := Parser{}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		msg, err := parser.Parse(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parser.Release(msg)
+	})
+	assert.Equal(t, allocs, float64(0))
+}
+
+// repeatReader endlessly repeats data.
+type repeatReader struct {
+	data []byte
+	off  int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		c := copy(p[n:], r.data[r.off:])
+		n += c
+		r.off = (r.off + c) % len(r.data)
+	}
+	return n, nil
+}
+
+var benchmarkCommands = map[string]string{
+	"GET": "*2\r\n$3\r\nGET\r\n$16\r\nkey:000000000001\r\n",
+	"SET": "*3\r\n$3\r\nSET\r\n$16\r\nkey:000000000001\r\n$64\r\n" + strings.Repeat("x", 64) + "\r\n",
+}
+
+// BenchmarkEncoder_Decode decodes commands with the lazy Encoder, reading every argument.
+func BenchmarkEncoder_Decode(b *testing.B) {
+	for name, frame := range benchmarkCommands {
+		b.Run(name, func(b *testing.B) {
+			br := bufio.NewReader(&repeatReader{data: []byte(frame)})
+			encoder := Encoder{}
+			b.ReportAllocs()
+			b.SetBytes(int64(len(frame)))
+			for range b.N {
+				msg, err := encoder.Decode(br)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for arg, err := range msg.Seq {
+					if err != nil {
+						b.Fatal(err)
+					}
+					_, err = arg.ReadAll()
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkParser_Parse parses commands with the pooled Parser, touching every argument.
+func BenchmarkParser_Parse(b *testing.B) {
+	for name, frame := range benchmarkCommands {
+		b.Run(name, func(b *testing.B) {
+			br := bufio.NewReader(&repeatReader{data: []byte(frame)})
+			parser := Parser{}
+			b.ReportAllocs()
+			b.SetBytes(int64(len(frame)))
+			var n int
+			for range b.N {
+				msg, err := parser.Parse(br)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for _, arg := range msg.Elems {
+					n += len(arg.Bytes)
+				}
+				parser.Release(msg)
+			}
+			if n == 0 {
+				b.Fatal(errors.New("no arguments parsed"))
+			}
+		})
+	}
+}
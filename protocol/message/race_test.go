@@ -0,0 +1,6 @@
+//go:build race
+
+package message
+
+// raceEnabled says whether the race detector is on, which allocates where the code under test doesn't.
+const raceEnabled = true
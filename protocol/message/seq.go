@@ -9,26 +9,40 @@ import (
 	"iter"
 )
 
+// CachedSeq2 wraps seq so that it can be ranged over more than once. The first loop over the result ranges over seq,
+// keeping every pair, and later loops replay the pairs from memory. If the first loop stops early, the rest of seq is
+// still read, so that every replay is complete.
+//
+// Note that caching the pairs of a lazily decoded message's Seq does not cache the content of its elements; use
+// Materialize for that.
 func CachedSeq2[K, V any](seq iter.Seq2[K, V]) iter.Seq2[K, V] {
 	type pair struct {
 		k K
 		v V
 	}
 
-	pairs := make([]pair, 0, 8)
-	index := 0
+	var (
+		pairs  []pair
+		cached bool
+	)
+
+	return func(yield func(K, V) bool) {
+		if cached {
+			for _, p := range pairs {
+				if !yield(p.k, p.v) {
+					return
+				}
+			}
+			return
+		}
 
-	s := func(yield func(K, V) bool) {
+		cached = true
+		more := true
 		for k, v := range seq {
-			index += 1
 			pairs = append(pairs, pair{k, v})
-			ok := yield(k, v)
-			if !ok {
-				return
+			if more {
+				more = yield(k, v)
 			}
 		}
 	}
-
-	return s
-
 }
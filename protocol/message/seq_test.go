@@ -1,10 +1,38 @@
 package message
 
 import (
+	"iter"
 	"testing"
+
+	"gotest.tools/v3/assert"
 )
 
 func TestCachedSeq2(t *testing.T) {
-	io.
+	var calls int
+	var seq iter.Seq2[int, string] = func(yield func(int, string) bool) {
+		calls++
+		for i, s := range []string{"a", "b", "c"} {
+			if !yield(i, s) {
+				return
+			}
+		}
+	}
+
+	cached := CachedSeq2(seq)
+
+	// stop early; the rest should still be cached.
+	for i, s := range cached {
+		assert.Equal(t, i, 0)
+		assert.Equal(t, s, "a")
+		break
+	}
 
+	for range 2 {
+		var got []string
+		for _, s := range cached {
+			got = append(got, s)
+		}
+		assert.DeepEqual(t, got, []string{"a", "b", "c"})
+	}
+	assert.Equal(t, calls, 1)
 }
@@ -6,8 +6,6 @@
 package protocol
 
 import (
-	"bytes"
-
 	"github.com/awinterman/anarchoredis/protocol/message"
 )
 
@@ -22,7 +20,7 @@ func NewArray(messages ...*Message) *Message {
 }
 
 func NewBulkString(s string) *Message {
-	bulkString := message.BulkString(bytes.NewBufferString(s), int64(len(s)))
+	bulkString := message.BulkBytes([]byte(s))
 	return &bulkString
 }
 